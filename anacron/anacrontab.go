@@ -0,0 +1,142 @@
+package anacron
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Path of the anacrontab of the host
+var Anacrontab = "/etc/anacrontab"
+
+// Period macros of anacron, in days
+var periodMacros = map[string]int{
+	"@daily":    1,
+	"@weekly":   7,
+	"@monthly":  31,
+	"@yearly":   365,
+	"@annually": 365,
+}
+
+// Table holds the jobs and the settings of an anacrontab
+type Table struct {
+	Jobs []Job
+
+	// RANDOM_DELAY, the most minutes added to the delay of every job
+	RandomDelay int
+	// Hours of the START_HOURS_RANGE setting, jobs only start between them
+	StartHour, EndHour int
+}
+
+// Job is a line of an anacrontab: period delay identifier command
+type Job struct {
+	Period     string `json:"period"`
+	Days       int    `json:"days"`
+	Delay      int    `json:"delay"`
+	Identifier string `json:"identifier"`
+	Command    string `json:"command"`
+}
+
+// Function to read an anacrontab, a missing file giving an empty table
+func ReadAnacrontab(path string) (*Table, error) {
+	table := &Table{EndHour: 24}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return table, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anacrontab: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if name, value, ok := strings.Cut(line, "="); ok && !strings.ContainsAny(name, " \t") {
+			if err := table.setVariable(name, strings.Trim(strings.TrimSpace(value), `"'`)); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+			}
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("%s:%d: expected period, delay, identifier and command", path, number)
+		}
+
+		job := Job{Period: fields[0], Identifier: fields[2]}
+		if job.Days, err = periodDays(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+		}
+		if job.Delay, err = strconv.Atoi(fields[1]); err != nil || job.Delay < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid delay '%s'", path, number, fields[1])
+		}
+		// The command is the rest of the line, spaces included
+		rest := line
+		for _, field := range fields[:3] {
+			rest = strings.TrimSpace(rest)[len(field):]
+		}
+		job.Command = strings.TrimSpace(rest)
+		table.Jobs = append(table.Jobs, job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read anacrontab: %w", err)
+	}
+	return table, nil
+}
+
+// helper function to apply the variables of an anacrontab read by beatify
+func (t *Table) setVariable(name, value string) error {
+	switch name {
+	case "RANDOM_DELAY":
+		delay, err := strconv.Atoi(value)
+		if err != nil || delay < 0 {
+			return fmt.Errorf("invalid RANDOM_DELAY '%s'", value)
+		}
+		t.RandomDelay = delay
+	case "START_HOURS_RANGE":
+		from, to, ok := strings.Cut(value, "-")
+		start, err := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if !ok || err != nil || err2 != nil || start < 0 || end > 24 || start >= end {
+			return fmt.Errorf("invalid START_HOURS_RANGE '%s'", value)
+		}
+		t.StartHour, t.EndHour = start, end
+	}
+	return nil
+}
+
+// helper function to get the days of an anacron period, a number or a macro
+func periodDays(period string) (int, error) {
+	if days, ok := periodMacros[period]; ok {
+		return days, nil
+	}
+	days, err := strconv.Atoi(period)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("invalid period '%s'", period)
+	}
+	return days, nil
+}
+
+// Function to get the directory a job runs with run-parts, empty when the
+// job runs another command
+func (j Job) RunPartsDir() string {
+	fields := strings.Fields(j.Command)
+	for i, field := range fields {
+		if filepath.Base(field) != "run-parts" {
+			continue
+		}
+		for _, arg := range fields[i+1:] {
+			if !strings.HasPrefix(arg, "-") {
+				return filepath.Clean(arg)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package anacron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+)
+
+// Directory holding the cron.hourly, cron.daily, cron.weekly and
+// cron.monthly directories
+var CronDir = "/etc"
+
+// Directories run by run-parts from the system crontab, with their period
+// when anacron does not run them
+var runPartsDirs = []struct {
+	Name   string
+	Period int
+}{
+	{"cron.hourly", 3600},
+	{"cron.daily", 86400},
+	{"cron.weekly", 7 * 86400},
+	{"cron.monthly", 31 * 86400},
+}
+
+// Names of the scripts run-parts runs, others such as backups left by the
+// package manager are skipped
+var scriptNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Script is a script of a run-parts directory, or an anacrontab job running
+// another command
+type Script struct {
+	// Identifier of the anacrontab job running the script, "cron" when the
+	// system crontab runs it
+	Job     string `json:"job"`
+	Name    string `json:"name"`
+	Path    string `json:"path,omitempty"`
+	Command string `json:"command,omitempty"`
+	Period  int    `json:"period"`
+	Grace   int    `json:"grace"`
+
+	// Heartbeat pinged by the wrapper of the script, empty when not monitored
+	HeartbeatURL string `json:"heartbeat_url,omitempty"`
+	// Original kept in .beatify while the wrapper was replaced, e.g. by a
+	// package upgrade installing a new version of the script
+	StaleOriginal string `json:"stale_original,omitempty"`
+}
+
+// helper type naming a run-parts directory and when it runs
+type runPartsDir struct {
+	Path   string
+	Job    string
+	Period int
+	Grace  int
+}
+
+// Function to list the scripts of the run-parts directories and the other
+// jobs of the anacrontab, in the order they run
+func DiscoverScripts(table *Table) ([]Script, error) {
+	var dirs []*runPartsDir
+	byPath := map[string]*runPartsDir{}
+	for _, dir := range runPartsDirs {
+		d := &runPartsDir{
+			Path:   filepath.Join(CronDir, dir.Name),
+			Job:    "cron",
+			Period: dir.Period,
+			Grace:  heartbeat.DefaultGrace(dir.Period),
+		}
+		dirs = append(dirs, d)
+		byPath[d.Path] = d
+	}
+
+	var scripts []Script
+	for _, job := range table.Jobs {
+		period, grace := table.Period(job)
+		path := job.RunPartsDir()
+		if path == "" {
+			scripts = append(scripts, Script{
+				Job:     job.Identifier,
+				Name:    job.Identifier,
+				Command: job.Command,
+				Period:  period,
+				Grace:   grace,
+			})
+			continue
+		}
+
+		// anacron runs the directory instead of the system crontab
+		d, ok := byPath[path]
+		if !ok {
+			d = &runPartsDir{Path: path}
+			dirs = append(dirs, d)
+			byPath[path] = d
+		}
+		d.Job, d.Period, d.Grace = job.Identifier, period, grace
+	}
+
+	var found []Script
+	for _, d := range dirs {
+		dirScripts, err := listScripts(d)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, dirScripts...)
+	}
+	return append(found, scripts...), nil
+}
+
+// Function to compute the period and grace of the heartbeat of an anacron
+// job: anacron starts it after its delay plus a random delay, and only
+// within the start hours
+func (t *Table) Period(job Job) (period int, grace int) {
+	period = job.Days * 86400
+	grace = heartbeat.DefaultGrace(period)
+	grace += (job.Delay + t.RandomDelay) * 60
+	grace += (24 - (t.EndHour - t.StartHour)) * 3600
+	return period, grace
+}
+
+// helper function to list the scripts run-parts runs in a directory, in
+// name order as ReadDir sorts them
+func listScripts(d *runPartsDir) ([]Script, error) {
+	entries, err := ioutil.ReadDir(d.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run-parts directory: %w", err)
+	}
+
+	var scripts []Script
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Mode()&0111 == 0 || !scriptNameRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(d.Path, entry.Name())
+		script := Script{
+			Job:          d.Job,
+			Name:         entry.Name(),
+			Path:         path,
+			Period:       d.Period,
+			Grace:        d.Grace,
+			HeartbeatURL: readWrapperURL(path),
+		}
+		if script.HeartbeatURL == "" {
+			if _, err := os.Lstat(originalPath(script)); err == nil {
+				script.StaleOriginal = originalPath(script)
+			}
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
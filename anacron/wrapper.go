@@ -0,0 +1,135 @@
+package anacron
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory of a run-parts directory the original scripts are moved to;
+// run-parts skips it, its name holding a dot
+const originalsDir = ".beatify"
+
+// Second line of the wrappers written by beatify, naming the heartbeat
+const headerPrefix = "# Written by beatify, pings heartbeat "
+
+// Wrapper replacing a script: it runs the original script with the same
+// arguments, pings the heartbeat with the exit code and exits with that code
+const wrapperFormat = `#!/bin/sh
+%s%s
+# The original script is kept in %s
+%s "$@"
+code=$?
+url=%s
+[ "$code" -eq 0 ] || url="$url/$code"
+curl -fs --retry 3 "$url" > /dev/null 2>&1
+exit "$code"
+`
+
+// Function to make a script ping a heartbeat: the script is moved to the
+// .beatify directory of its run-parts directory and replaced by a wrapper
+// running it, the crontab and anacrontab lines running the directory being
+// left as they are
+func Instrument(script Script, heartbeatURL string) error {
+	if script.Path == "" {
+		return fmt.Errorf("%s does not run from a run-parts directory", script.Name)
+	}
+	if strings.ContainsAny(heartbeatURL, "'\"$`\\ \n") {
+		return fmt.Errorf("invalid heartbeat URL '%s'", heartbeatURL)
+	}
+
+	original, err := filepath.Abs(originalPath(script))
+	if err != nil {
+		return fmt.Errorf("failed to locate %s: %w", script.Path, err)
+	}
+	info, err := os.Stat(script.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", script.Path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(original), err)
+	}
+	if _, err := os.Lstat(original); err == nil {
+		return fmt.Errorf("%s already exists", original)
+	}
+	if err := os.Rename(script.Path, original); err != nil {
+		return fmt.Errorf("failed to move %s: %w", script.Path, err)
+	}
+
+	wrapper := fmt.Sprintf(wrapperFormat, headerPrefix, heartbeatURL, original, shellQuote(original), heartbeatURL)
+	if err := writeScript(script.Path, wrapper, info.Mode().Perm()|0111); err != nil {
+		// Put the script back rather than leave the directory without it
+		os.Rename(original, script.Path)
+		return err
+	}
+	return nil
+}
+
+// Function to put back the original script of a wrapper written by beatify
+func Uninstrument(script Script) error {
+	if readWrapperURL(script.Path) == "" {
+		return fmt.Errorf("%s is not a wrapper written by beatify", script.Path)
+	}
+	if err := os.Rename(originalPath(script), script.Path); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", script.Path, err)
+	}
+	// The directory of the originals is only removed when beatify left it empty
+	os.Remove(filepath.Dir(originalPath(script)))
+	return nil
+}
+
+// helper function to get the path the original of a script is kept at
+func originalPath(script Script) string {
+	return filepath.Join(filepath.Dir(script.Path), originalsDir, script.Name)
+}
+
+// helper function to read the heartbeat URL of a wrapper, empty when the
+// script is not a wrapper written by beatify
+func readWrapperURL(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	// The header is on the second line, right after the shebang
+	head := make([]byte, 512)
+	n, _ := file.Read(head)
+	lines := strings.SplitN(string(head[:n]), "\n", 3)
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], headerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(lines[1], headerPrefix))
+}
+
+// helper function to quote a path for sh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// helper function to write a script in one step
+func writeScript(path, content string, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/anacron"
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	anacronApply  bool
+	anacronRemove bool
+)
+
+var anacronCmd = &cobra.Command{
+	Use:   "anacron",
+	Short: "List the scripts run by run-parts and anacron and make them ping heartbeats",
+	Long: `List the scripts of the cron.hourly, cron.daily, cron.weekly and cron.monthly
+directories of --cron-dir and of the other run-parts directories of the
+anacrontab, and the other jobs of the anacrontab, with the period and grace
+of the heartbeat each would get and the heartbeat it already pings.
+
+A directory anacron runs gets the period of its anacrontab job, and a grace
+also covering the delay of the job, RANDOM_DELAY and the hours outside
+START_HOURS_RANGE. The other directories get the period of their name.
+
+With --apply, each script without a heartbeat is presented for approval, a
+heartbeat is created for each approved script and the script is replaced by
+a wrapper running it and pinging the heartbeat with its exit code. The
+original script is kept in the .beatify directory of its run-parts
+directory, which run-parts skips; the crontab and anacrontab lines running
+the directory are left as they are. Anacrontab jobs running another command
+than run-parts are only listed, their command can be run through beatify
+exec.
+
+With --remove, each script with a heartbeat is presented for approval and
+the approved ones get their original script back; their heartbeats are kept
+unless --delete-heartbeat is given.
+
+The package manager does not know of the wrappers: an upgrade of the package
+of a wrapped script installs the new script over the wrapper, which stops
+pinging, and leaves the former original in .beatify. Such scripts are listed
+with a warning and skipped by --apply; once the stale original is removed,
+--apply wraps the new script again.`,
+	Example: `  beatify anacron
+  beatify anacron --apply -g web-01
+  beatify anacron --remove --delete-heartbeat`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if anacronApply && anacronRemove {
+			finish(ExitUsage, fmt.Errorf("--apply and --remove cannot be combined"))
+		}
+
+		table, err := anacron.ReadAnacrontab(anacron.Anacrontab)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading anacrontab: %w", err))
+		}
+		scripts, err := anacron.DiscoverScripts(table)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error listing scripts: %w", err))
+		}
+
+		switch {
+		case anacronApply:
+			applyScripts(scripts)
+		case anacronRemove:
+			removeScripts(scripts)
+		case outputFormat == "json":
+			printJSON(scripts)
+		default:
+			printScriptsTable(scripts)
+		}
+	},
+}
+
+func init() {
+	anacronCmd.Flags().StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group to add the heartbeats to, created if missing")
+	anacronCmd.Flags().StringVar(&anacron.Anacrontab, "anacrontab", anacron.Anacrontab, "Path of the anacrontab")
+	anacronCmd.Flags().StringVar(&anacron.CronDir, "cron-dir", anacron.CronDir, "Directory holding the cron.hourly, cron.daily, cron.weekly and cron.monthly directories")
+	anacronCmd.Flags().BoolVar(&anacronApply, "apply", false, "Create heartbeats for the approved scripts and wrap them")
+	anacronCmd.Flags().BoolVar(&anacronRemove, "remove", false, "Put back the original of the approved scripts")
+	anacronCmd.Flags().BoolVar(&deleteHeartbeats, "delete-heartbeat", false, "With --remove, also delete the heartbeats of the approved scripts")
+}
+
+// Function to create a heartbeat and install the wrapper of each approved
+// script without one
+func applyScripts(scripts []anacron.Script) {
+	requireToken()
+
+	var heartbeatGroupID string
+	if heartbeatGroupName != "" {
+		var err error
+		if heartbeatGroupID, err = heartbeatGroupIDFor(heartbeatGroupName); err != nil {
+			finish(ExitAPI, err)
+		}
+	}
+
+	failed := 0
+	for _, script := range scripts {
+		if script.HeartbeatURL != "" {
+			fmt.Printf("Skipping script already pinging a heartbeat: %s\n", script.Path)
+			continue
+		}
+		if script.Path == "" {
+			fmt.Printf("Skipping anacron job %s, not run-parts: wrap its command with beatify exec\n", script.Name)
+			continue
+		}
+		if script.StaleOriginal != "" {
+			fmt.Printf("Skipping script replaced since it was wrapped: %s, remove the stale original %s first\n", script.Path, script.StaleOriginal)
+			failed++
+			continue
+		}
+
+		fmt.Printf("Script: %s, run by %s\n", script.Path, script.Job)
+		fmt.Printf("  Heartbeat: period %s (%ds), grace %s (%ds)\n",
+			tui.FormatSeconds(script.Period), script.Period,
+			tui.FormatSeconds(script.Grace), script.Grace)
+		approved, skipRest, err := crontab.PromptApproval()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading approval: %w", err))
+		}
+		if skipRest {
+			break
+		}
+		if !approved {
+			continue
+		}
+		name, err := crontab.PromptHeartbeatName(crontab.DefaultHeartbeatName("root", script.Path))
+		if err != nil {
+			finish(ExitError, err)
+		}
+
+		data, err := heartbeat.PreparePeriodJson(name, heartbeatGroupID, script.Period, script.Grace, "")
+		if err != nil {
+			fmt.Println("Error preparing config JSON:", err)
+			failed++
+			continue
+		}
+		hb, err := heartbeat.CreateHeartbeat(authToken, data)
+		if err != nil {
+			fmt.Println("Error creating heartbeat:", err)
+			failed++
+			continue
+		}
+		fmt.Println("Heartbeat created successfully:", hb.Attributes.URL)
+
+		if err := anacron.Instrument(script, hb.Attributes.URL); err != nil {
+			fmt.Println("Error installing wrapper:", err)
+			deleteUnusedHeartbeat(hb)
+			failed++
+			continue
+		}
+		fmt.Println("Wrapper installed:", script.Path)
+	}
+
+	if failed > 0 {
+		finish(ExitPartial, fmt.Errorf("%d scripts could not be instrumented", failed))
+	}
+}
+
+// Function to put back the original of each approved script with a heartbeat
+func removeScripts(scripts []anacron.Script) {
+	if deleteHeartbeats {
+		requireToken()
+	}
+
+	var removed []crontab.CronTask
+	for _, script := range scripts {
+		if script.HeartbeatURL == "" {
+			continue
+		}
+
+		fmt.Printf("Monitored script: %s, pings %s\n", script.Path, script.HeartbeatURL)
+		approved, skipRest, err := crontab.PromptApproval()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading approval: %w", err))
+		}
+		if skipRest {
+			break
+		}
+		if !approved {
+			continue
+		}
+
+		if err := anacron.Uninstrument(script); err != nil {
+			finish(ExitError, fmt.Errorf("Error removing wrapper: %w", err))
+		}
+		fmt.Println("Original script restored:", script.Path)
+		removed = append(removed, crontab.CronTask{HeartbeatURL: script.HeartbeatURL})
+	}
+
+	if deleteHeartbeats && len(removed) > 0 {
+		deleteTaskHeartbeats(removed)
+	}
+}
+
+// helper function to print scripts as an aligned table
+func printScriptsTable(scripts []anacron.Script) {
+	if len(scripts) == 0 {
+		fmt.Println("No scripts found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSCRIPT\tPERIOD\tGRACE\tHEARTBEAT")
+	for _, script := range scripts {
+		target := script.Path
+		if target == "" {
+			target = script.Command
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			script.Job,
+			target,
+			tui.FormatSeconds(script.Period),
+			tui.FormatSeconds(script.Grace),
+			valueOrDash(script.HeartbeatURL),
+		)
+	}
+	w.Flush()
+
+	for _, script := range scripts {
+		if script.StaleOriginal != "" {
+			fmt.Printf("Warning: %s was replaced since it was wrapped, its stale original is kept in %s\n", script.Path, script.StaleOriginal)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/tui"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create heartbeats for approved cron tasks and make the tasks ping them",
+	Long: `Present each cron task of the crontab for approval, create a heartbeat for
+each approved task with the BetterUptime API, and append a curl request
+pinging the heartbeat to each of these tasks.
+
+The heartbeat name prompt defaults to the user and the command of the task,
+such as "www-data: /usr/local/bin/cleanup".
+
+A timestamped backup of the crontab is taken before the review. The crontab
+is locked against other beatify runs for the whole session. If it was edited
+by anyone between the review and the install, beatify aborts without
+installing and shows what changed: the crontab is checked before any
+heartbeat is created, and the heartbeats created are deleted again when it
+changes while they are created.
+
+With --output json, a report of every cron task is printed on stdout once
+done, while prompts and messages go to stderr. A job status is "skipped"
+(with a reason: already monitored, invalid cron task, declined or not
+reviewed), "approved", "created" or "failed" (with the error).`,
+	Example: `  BEATIFY_TOKEN=YOUR_AUTH_TOKEN beatify apply -u www-data
+  beatify apply --token-file /etc/beatify/token --all-users -g web-01
+  beatify apply --store file --crontab-file ops/crontabs/www-data
+  beatify apply --store stream < crontab.in > crontab.out`,
+	Args: cobra.NoArgs,
+	Run:  runApply,
+}
+
+func init() {
+	addApplyFlags(applyCmd.Flags())
+}
+
+// Function to run the apply command
+func runApply(cmd *cobra.Command, args []string) {
+	var heartbeatGroupID string
+	requireToken()
+
+	// Check if heartbeatGroup is set
+	if heartbeatGroupName != "" {
+		var err error
+		heartbeatGroupID, err = heartbeatGroupIDFor(heartbeatGroupName)
+		if err != nil {
+			finish(ExitAPI, err)
+		}
+	}
+
+	// The heartbeat group and the API rate limit are shared by every crontab
+	limiter := rate.NewLimiter(3, 1) // 3 requests per second, no burst
+
+	if allUsers {
+		handleAllUsers(heartbeatGroupID, limiter)
+		return
+	}
+
+	summary, jobs := applyCrontab(mustResolveCrontabUser(), heartbeatGroupID, limiter, reviewCrontab)
+	addToReport(summary, jobs)
+	finish(reportExitCode(), summary.Err)
+}
+
+// Function to review a crontab with the approval prompts or in the terminal UI
+func reviewCrontab(crontabUser string) ([]crontab.CronTask, error) {
+	if useTUI {
+		return crontab.ReviewCronTasks(crontabUser, selectWithTUI(crontabUser))
+	}
+	return crontab.ParseAndApproveCronTasks(crontabUser)
+}
+
+// Function to approve with review, create heartbeats for and rewrite one
+// crontab, returning its summary and the report of each of its cron tasks
+func applyCrontab(crontabUser string, heartbeatGroupID string, limiter *rate.Limiter, review func(string) ([]crontab.CronTask, error)) (*CrontabReport, []*JobReport) {
+	summary := &CrontabReport{Name: crontabUser}
+	jobs := []*JobReport{}
+
+	fail := func(code int, err error) (*CrontabReport, []*JobReport) {
+		summary.Err = err
+		summary.Error = err.Error()
+		summary.ExitCode = code
+		return summary, jobs
+	}
+
+	// Record the tasks left out during the review
+	crontab.ReportSkipped = func(line, reason string) {
+		jobs = append(jobs, &JobReport{
+			Crontab: crontabUser,
+			Line:    line,
+			Status:  JobSkipped,
+			Reason:  reason,
+		})
+	}
+
+	// Explain each schedule before asking for its approval
+	crontab.ExplainSchedule = explainApprovedSchedule
+
+	// Lock the crontab until the updated one is installed
+	lock, err := crontab.LockCrontab(crontabUser)
+	if err != nil {
+		return fail(ExitCrontab, err)
+	}
+	defer lock.Unlock()
+
+	// Parse and approve cron tasks
+	cronTasks, err := review(crontabUser)
+	if errors.Is(err, tui.ErrAborted) {
+		fmt.Println("Selection aborted, the crontab was left unchanged.")
+		return summary, jobs
+	}
+	if err != nil {
+		return fail(ExitCrontab, fmt.Errorf("Error parsing crontab: %w", err))
+	}
+	summary.Approved = len(cronTasks)
+
+	// No heartbeat is created for a crontab edited during the review
+	if len(cronTasks) > 0 {
+		if err := crontab.CheckUnchanged(crontabUser); err != nil {
+			return fail(ExitCrontab, fmt.Errorf("Error checking crontab: %w", err))
+		}
+	}
+
+	// Iterate over cronTasks and call PrepareConfigJson for each task
+	var createdTasks []crontab.CronTask
+	var createdJobs []*JobReport
+	for _, cronTask := range cronTasks {
+		job := &JobReport{
+			Crontab: crontabUser,
+			Spec:    cronTask.Spec,
+			Task:    cronTask.Task,
+			Name:    cronTask.Name,
+			Status:  JobApproved,
+		}
+		jobs = append(jobs, job)
+
+		ctx := limiter.ReserveN(time.Now(), 1)
+		if !ctx.OK() {
+			return fail(ExitAPI, fmt.Errorf("Waiting for API."))
+		}
+
+		delay := ctx.Delay()
+		time.Sleep(delay)
+
+		// A group chosen for this task replaces the group of the run
+		groupID := heartbeatGroupID
+		if cronTask.Group != "" {
+			var err error
+			if groupID, err = heartbeatGroupIDFor(cronTask.Group); err != nil {
+				fmt.Println(err)
+				job.Status, job.Error = JobFailed, err.Error()
+				summary.Failed++
+				continue
+			}
+		}
+
+		data, err := heartbeat.PrepareConfigJson(cronTask.Spec, cronTask.Name, groupID, cronTask.Grace)
+		if err != nil {
+			fmt.Println("Error preparing config JSON:", err)
+			job.Status, job.Error = JobFailed, err.Error()
+			summary.Failed++
+			continue // Skip to the next iteration of the loop
+		}
+
+		// Create the Heartbeat
+		created, err := heartbeat.CreateHeartbeat(authToken, data)
+		if err != nil {
+			fmt.Println("Error creating heartbeat:", err)
+			job.Status, job.Error = JobFailed, err.Error()
+			summary.Failed++
+			if errors.Is(err, heartbeat.ErrUnauthorized) {
+				// Every other request would fail the same way
+				return fail(ExitAuth, err)
+			}
+			continue // Skip to the next iteration of the loop
+		}
+		fmt.Println("Heartbeat created successfully:", created.Attributes.URL)
+		if cronTask.Grace != crontab.NoGrace {
+			// sync and watch keep the grace chosen for the task
+			if err := heartbeat.RecordGrace(created.Attributes.URL, cronTask.Grace); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning:", err)
+			}
+		}
+		job.Status = JobCreated
+		job.HeartbeatID = created.ID
+		job.HeartbeatURL = created.Attributes.URL
+		summary.Created++
+
+		// Set cronTask.HeartbeatURL to the response URL
+		cronTask.HeartbeatURL = created.Attributes.URL
+		createdTasks = append(createdTasks, cronTask)
+		createdJobs = append(createdJobs, job)
+	}
+
+	// Only the tasks with a heartbeat get the curl command
+	err = crontab.AppendCronsCommand(createdTasks, crontabUser)
+	if err != nil {
+		code := ExitInstall
+		if errors.Is(err, crontab.ErrCrontabChanged) {
+			code = ExitCrontab
+			// The tasks were not instrumented, their heartbeats would only
+			// report missed runs
+			deleteCreatedHeartbeats(summary, createdJobs)
+		}
+		return fail(code, fmt.Errorf("Error appending curl command to cron tasks: %w", err))
+	}
+	fmt.Println("Curl commands appended to cron tasks successfully.")
+	summary.Installed = true
+
+	return summary, jobs
+}
+
+// helper function to add the reports of a crontab to the report of the run
+func addToReport(summary *CrontabReport, jobs []*JobReport) {
+	report.Crontabs = append(report.Crontabs, summary)
+	report.Jobs = append(report.Jobs, jobs...)
+}
+
+// Function to instrument every crontab of the host in one session
+func handleAllUsers(heartbeatGroupID string, limiter *rate.Limiter) {
+	targets, err := crontab.DiscoverCrontabs()
+	if err != nil {
+		finish(ExitCrontab, fmt.Errorf("Error listing crontabs: %w", err))
+	}
+
+	for _, target := range targets {
+		fmt.Printf("\n=== %s (%s)\n", target.Name, target.Path)
+		crontab.UseTarget(target)
+		summary, jobs := applyCrontab(target.Name, heartbeatGroupID, limiter, reviewCrontab)
+		addToReport(summary, jobs)
+		if summary.Err != nil {
+			fmt.Println(summary.Err)
+			if summary.ExitCode == ExitAuth {
+				break
+			}
+		}
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CRONTAB\tAPPROVED\tCREATED\tFAILED\tINSTALLED")
+	for _, summary := range report.Crontabs {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%t\n", summary.Name, summary.Approved, summary.Created, summary.Failed, summary.Installed)
+	}
+	w.Flush()
+
+	finish(reportExitCode(), nil)
+}
+
+// IDs of the heartbeat groups already looked up or created, by name
+var heartbeatGroupIDs = map[string]string{}
+
+// Helper function to get the ID of a heartbeat group, creating the group if missing
+func heartbeatGroupIDFor(name string) (string, error) {
+	if id, ok := heartbeatGroupIDs[name]; ok {
+		return id, nil
+	}
+
+	// Get the ID of the heartbeat group if it exists
+	id, err := heartbeat.GetHeartbeatGroupID(authToken, name)
+	if err != nil {
+		return "", fmt.Errorf("Error whilst checking heartbeat group: %w", err)
+	}
+	if id == "" {
+		// If heartbeatGroup does not exist, create it
+		id, err = heartbeat.CreateHeartbeatGroup(authToken, name)
+		if err != nil {
+			return "", fmt.Errorf("Error creating heartbeat group: %w", err)
+		}
+	}
+
+	heartbeatGroupIDs[name] = id
+	return id, nil
+}
+
+// helper function to delete the heartbeats created for a crontab that could
+// not be updated
+func deleteCreatedHeartbeats(summary *CrontabReport, jobs []*JobReport) {
+	for _, job := range jobs {
+		if err := heartbeat.DeleteHeartbeat(authToken, job.HeartbeatID); err != nil {
+			fmt.Println("Error deleting heartbeat:", err)
+			continue
+		}
+		fmt.Println("Heartbeat deleted:", job.HeartbeatURL)
+		job.Status, job.Error = JobFailed, "crontab changed, heartbeat deleted"
+		job.HeartbeatID, job.HeartbeatURL = "", ""
+		summary.Created--
+	}
+}
@@ -1,191 +1,257 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
-	"time"
 
 	"github.com/IT-JONCTION/beatify/config"
 	"github.com/IT-JONCTION/beatify/crontab"
 	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"golang.org/x/time/rate"
 )
 
 var (
 	authToken          string
 	crontabUser        string
-	showHelp           bool
 	heartbeatGroupName string
+	outputFormat       string
+	backupDir          string
+	backupRetention    int
+	restoreList        bool
+	restoreAt          string
+	crontabStore       string
+	spoolDir           string
+	crontabBinary      string
+	crontabFile        string
+	allUsers           bool
+	profileName        string
+	configFile         string
+	tokenFile          string
+	tokenCommand       string
+	useTUI             bool
 )
 
-var manpageTemplate = `
-BEATIFY(1)              User Commands             BEATIFY(1)
-
-NAME
-    beatify - Automate heartbeats for monitoring cron tasks with BetterUptime
-
-SYNOPSIS
-    beatify [OPTIONS]
-
-DESCRIPTION
-    The beatify is a command-line tool that automates the creation of heartbeats
-    for monitoring cron tasks using the BetterUptime service. It reads the user's
-    crontab, presents each cron task for approval to create a heartbeat, calls
-    the BetterUptime API to create the approved heartbeats, and updates the
-    crontab to append a curl request to each approved cron task.
-
-OPTIONS
-    -a, --auth-token AUTH_TOKEN
-        Provide the authentication token for the BetterUptime API. If not
-        provided, the tool will prompt for it during runtime.
-
-    -u, --user USER
-        Optional. The crontab user to edit. If not provided, the tool will
-        default to the current user's crontab.
-
-    -h, --help
-        Display the help message and exit.
-
-    -g, --heartbeat-group HEARTBEAT_GROUP
-        Optional. The heartbeat group to add the heartbeat to. If not provided,
-        the tool will default to creating the heartbeats without a group.
-
-EXAMPLES
-    To run Beatify and create heartbeats for cron tasks:
-        beatify -a YOUR_AUTH_TOKEN -u www-data
-
-EXIT STATUS
-    0 if successful, or an error code if an error occurs.
+var rootCmd = &cobra.Command{
+	Use:   "beatify",
+	Short: "Automate heartbeats for monitoring cron tasks with BetterUptime",
+	Long: `Beatify is a command-line tool that automates the creation of heartbeats
+for monitoring cron tasks using the BetterUptime service. It reads the user's
+crontab, presents each cron task for approval to create a heartbeat, calls
+the BetterUptime API to create the approved heartbeats, and updates the
+crontab to append a curl request to each approved cron task.
+
+Run without a command, beatify runs the apply command.
+
+Settings can be kept as named profiles in /etc/beatify/config.yaml and
+~/.config/beatify/config.yaml. Both files are read, the user file
+overriding the system file field by field, and options given on the
+command line override the selected profile.
+
+    default_profile: prod-eu
+    profiles:
+      prod-eu:
+        provider: betterstack            # or local, for beatify serve
+        api_base_url: https://uptime.betterstack.com/api/v2
+        token: env:BETTERSTACK_PROD_EU   # or file:PATH or command:CMD
+        heartbeat_group: web-eu
+        user: www-data
+        grace: 20%                       # or seconds, or a duration like 5m
+        email: true
+        call: false
+        sms: false
+        push: true
+        team_wait: 180
+        name_template: "{{.User}}@{{.Host}}: {{.Command}}"
+
+The name template sets the default heartbeat name offered by the prompt.
+It is a Go template with the fields User, Host and Command.
+
+The authentication token is taken from the first source set among -a,
+--token-file, --token-command, the BEATIFY_TOKEN environment variable and
+the profile. If none is set, beatify prompts for it without echoing. The
+token is checked against the API before any crontab is read.`,
+	Example: `  BEATIFY_TOKEN=YOUR_AUTH_TOKEN beatify apply -u www-data
+  beatify --profile prod-eu apply --all-users`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Run:           runApply,
+}
 
-REPORTING BUGS
-    Report bugs to the GitHub repository: https://github.com/IT-JONCTION/beatify
+// Exit codes listed in the generated manpages
+const exitStatusDoc = `0   every approved cron task was instrumented
+1   generic error, e.g. an invalid configuration
+2   unknown command or option
+3   no authentication token, or the token was rejected by the API
+4   some heartbeats could not be created, the crontab was installed with the others
+5   the updated crontab could not be installed
+6   a crontab could not be read or locked, or it changed during the review
+7   the API could not be reached or returned an error
+8   lint found mistakes in the crontab`
 
-AUTHOR
-    Your Name <wayne@it-jonction-lab.com>
+func init() {
+	// Every command starts by applying the profile and the crontab store
+	rootCmd.PersistentPreRun = setup
+
+	// Define the options shared by every command
+	flags := rootCmd.PersistentFlags()
+	flags.StringVarP(&authToken, "auth-token", "a", "", "Authentication token for the BetterUptime API, visible in the process list: prefer --token-file, --token-command or BEATIFY_TOKEN")
+	flags.StringVar(&tokenFile, "token-file", "", "File holding the authentication token, owned by the current user or root and not accessible by group or others")
+	flags.StringVar(&tokenCommand, "token-command", "", "Command run with sh whose first line of output is the authentication token, e.g. \"pass show betterstack/prod\"")
+	flags.StringVarP(&crontabUser, "user", "u", "", "Crontab user, defaults to the current user; any account known to the host is accepted")
+	flags.StringVarP(&outputFormat, "output", "o", "table", "Output format (table or json)")
+	flags.StringVarP(&profileName, "profile", "p", "", "Configuration profile to use, defaults to BEATIFY_PROFILE then to default_profile")
+	flags.StringVar(&configFile, "config", "", "Configuration file to read instead of /etc/beatify/config.yaml and ~/.config/beatify/config.yaml")
+	flags.StringVar(&crontabStore, "store", "auto", "Where crontabs are read and installed: auto, debian (/var/spool/cron/crontabs), rhel (/var/spool/cron), binary (crontab -l/-), file (--crontab-file) or stream (stdin to stdout)")
+	flags.StringVar(&spoolDir, "spool-dir", "", "Cron spool directory of the auto, debian and rhel stores")
+	flags.StringVar(&crontabBinary, "crontab-bin", "crontab", "Path of the crontab binary")
+	flags.StringVar(&crontabFile, "crontab-file", "", "Crontab file of the file store")
+	flags.StringVar(&backupDir, "backup-dir", "", "Directory holding the crontab backups, defaults to ~/.beatify/backups")
+	flags.IntVar(&backupRetention, "backup-keep", 10, "Number of crontab backups to keep per user, 0 keeps every backup")
+	flags.StringVar(&crontab.LockDir, "lock-dir", "", "Directory holding the crontab lock files, only accessible by the current user, defaults to /run/beatify for root and ~/.beatify/locks for other users")
+
+	// Running beatify without a command runs apply
+	addApplyFlags(rootCmd.Flags())
+
+	rootCmd.AddCommand(
+		scanCmd,
+		lintCmd,
+		applyCmd,
+		listCmd,
+		removeCmd,
+		syncCmd,
+		statusCmd,
+		restoreCmd,
+		explainCmd,
+		pingCmd,
+		execCmd,
+		metricsCmd,
+		historyCmd,
+		logsCmd,
+		serveCmd,
+		watchCmd,
+		timersCmd,
+		cronJobsCmd,
+		anacronCmd,
+		manCmd,
+	)
+}
 
-COPYRIGHT
-    Copyright © 2023 IT Jonction Lab. This is free software; see the source
-    code for copying conditions. There is NO warranty; not even for MERCHANTABILITY
-    or FITNESS FOR A PARTICULAR PURPOSE.
+// helper function to register the options of the apply command
+func addApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group to add the heartbeats to, created if missing")
+	flags.BoolVar(&allUsers, "all-users", false, "Edit every user crontab and the system crontabs of the host, needs a spool store")
+	flags.BoolVar(&useTUI, "tui", false, "Select and configure the cron tasks in a full-screen list instead of one prompt per task")
+	addSystemCrontabFlags(flags)
+}
 
-SEE ALSO
-    The BetterUptime API documentation: https://docs.betteruptime.com/api/
-`
+// Function to add the options giving the paths of the system crontabs
+func addSystemCrontabFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&crontab.SystemCrontabFile, "system-crontab", crontab.SystemCrontabFile, "Path of the system crontab")
+	flags.StringVar(&crontab.SystemCrontabDir, "system-crontab-dir", crontab.SystemCrontabDir, "Directory of the system crontabs of packages")
+}
 
-func init() {
-	// Define command-line flags
-	pflag.StringVarP(&authToken, "auth-token", "a", "", "Authentication token for the BetterUptime API")
-	pflag.StringVarP(&crontabUser, "user", "u", "", "Crontab user to edit")
-	pflag.StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group to add the heartbeat to")
-	pflag.BoolVarP(&showHelp, "help", "h", false, "Show help message")
-
-	// Customize usage message
-	pflag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "%s\n", manpageTemplate)
+func HandleCommandLineOptions() {
+	err := rootCmd.Execute()
+	closeStreamStore()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(ExitUsage)
 	}
 }
 
-func HandleCommandLineOptions() {
-	pflag.Parse()
-	var heartbeatGroupID string
+// Function run before every command to apply the profile and the crontab store
+func setup(cmd *cobra.Command, args []string) {
+	// exec and ping run from cron jobs and use neither the profile nor the
+	// store: an invalid configuration must not stop the job they wrap
+	if cmd == execCmd || cmd == pingCmd {
+		return
+	}
+	if crontabStore == "stream" {
+		// Before any message, so none of them ends up in the crontab
+		useStreamStore()
+	}
 
-	if showHelp {
-		// Display the help message and exit
-		pflag.Usage()
-		os.Exit(0)
+	switch outputFormat {
+	case "table":
+	case "json":
+		if crontabStore == "stream" {
+			finish(ExitUsage, fmt.Errorf("The stream store writes the crontab to stdout, it cannot be combined with --output json"))
+		}
+		// Only the instrumenting run prints the report, other commands print their own JSON
+		reportEnabled = cmd == rootCmd || cmd == applyCmd
+		useJSONOutput()
+	default:
+		finish(ExitUsage, fmt.Errorf("Unknown output format '%s': expected 'table' or 'json'", outputFormat))
 	}
 
-	// Check if authToken is set
-	if authToken == "" {
-		// Prompt the user to enter the authToken
-		authToken = config.PromptAuthToken()
+	// Apply the configuration profile
+	if err := applyProfile(cmd); err != nil {
+		finish(ExitError, fmt.Errorf("Error loading configuration: %w", err))
 	}
 
-	// Check if heartbeatGroup is set
-	if heartbeatGroupName != "" {
-		// Get the ID of the heartbeat group if it exists
-		var err error
-		heartbeatGroupID, err = heartbeat.GetHeartbeatGroupID(authToken, heartbeatGroupName)
-		if err != nil {
-			fmt.Println("Error whilst checking heartbeat group:", err)
-			os.Exit(1)
-		}
-		if heartbeatGroupID == "" {
-			// If heartbeatGroup does not exist, create it
-			var err error
-			heartbeatGroupID, err = heartbeat.CreateHeartbeatGroup(authToken, heartbeatGroupName)
-			if err != nil {
-				fmt.Println("Error creating heartbeat group:", err)
-				os.Exit(1)
-			}
+	// Configure where crontabs are read and installed
+	if err := configureStore(); err != nil {
+		finish(ExitError, err)
+	}
+
+	// Apply the backup settings
+	crontab.BackupDir = backupDir
+	crontab.BackupRetention = backupRetention
+}
+
+// Function to resolve the authToken from the flags, the environment, the
+// profile or a prompt, and check it before touching any crontab
+func requireToken() {
+	var err error
+	authToken, err = config.ResolveToken(config.TokenSources{
+		Flag:      authToken,
+		File:      tokenFile,
+		Command:   tokenCommand,
+		Reference: profileToken,
+	})
+	if err != nil {
+		finish(ExitAuth, fmt.Errorf("Error reading the authentication token: %w", err))
+	}
+	if err := heartbeat.ValidateToken(authToken); err != nil {
+		code := ExitAPI
+		if errors.Is(err, heartbeat.ErrUnauthorized) {
+			code = ExitAuth
 		}
+		finish(code, fmt.Errorf("Error checking the authentication token: %w", err))
 	}
+}
+
+// Helper function to get the crontab user from the flags or the current user
+func resolveCrontabUser() (string, error) {
+	username := crontabUser
 
 	// Check if crontabUser option is set
-	if crontabUser == "" {
+	if username == "" {
 		// If not set, obtain currently logged-in user
 		currentUser, err := user.Current()
 		if err != nil {
-			fmt.Println("Error obtaining current user:", err)
-			os.Exit(1)
+			return "", fmt.Errorf("Error obtaining current user: %w", err)
 		}
-		crontabUser = currentUser.Username
+		username = currentUser.Username
 	}
 
 	// check if crontabUser is valid
-	if err := crontab.IsValidUsername(crontabUser); err != nil {
-		// Handle the error: show a user-friendly message, log the error, etc.
-		fmt.Println(err)
-		return
+	if err := crontab.IsValidUsername(username); err != nil {
+		return "", err
 	}
 
-	if crontabUser != "" {
-		// Parse and approve cron tasks
-		cronTasks, err := crontab.ParseAndApproveCronTasks(crontabUser)
-		if err != nil {
-			fmt.Println("Error parsing crontab:", err)
-			os.Exit(1)
-		}
-
-		limiter := rate.NewLimiter(3, 1) // 3 requests per second, no burst
-
-		// Iterate over cronTasks and call PrepareConfigJson for each task
-		for i, cronTask := range cronTasks {
-
-			ctx := limiter.ReserveN(time.Now(), 1)
-			if !ctx.OK() {
-				fmt.Println("Waiting for API.")
-				return
-			}
-
-			delay := ctx.Delay()
-			time.Sleep(delay)
-
-			data, err := heartbeat.PrepareConfigJson(cronTask.Spec, cronTask.Name, heartbeatGroupID)
-			if err != nil {
-				fmt.Println("Error preparing config JSON:", err)
-				continue // Skip to the next iteration of the loop
-			}
-
-			// Create the Heartbeat
-			responseBodyURL, err := heartbeat.CreateHeartbeat(authToken, data)
-			if err != nil {
-				fmt.Println("Error creating heartbeat:", err)
-				continue // Skip to the next iteration of the loop
-			}
-			fmt.Println("Heartbeat created successfully:", responseBodyURL)
-
-			// Set cronTask.HeartbeatURL to the response URL
-			cronTasks[i].HeartbeatURL = responseBodyURL
-		}
+	return username, nil
+}
 
-		err = crontab.AppendCronsCommand(cronTasks, crontabUser)
-		if err != nil {
-			fmt.Println("Error appending curl command to cron tasks:", err)
-			return
-		}
-		fmt.Println("Curl commands appended to cron tasks successfully.")
+// Helper function to resolve the crontab user or end the run
+func mustResolveCrontabUser() string {
+	username, err := resolveCrontabUser()
+	if err != nil {
+		finish(ExitError, err)
 	}
+	return username
 }
@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/kube"
+	"github.com/IT-JONCTION/beatify/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cronJobApply   bool
+	cronJobInPlace bool
+	cronJobWrap    bool
+)
+
+// CronJobReport describes a Kubernetes CronJob and the heartbeat it would get
+type CronJobReport struct {
+	*kube.CronJob
+	Spec   string `json:"cron_spec,omitempty"`
+	Period int    `json:"period"`
+	Grace  int    `json:"grace"`
+	Error  string `json:"error,omitempty"`
+}
+
+var cronJobsCmd = &cobra.Command{
+	Use:   "cronjobs [FILE...]",
+	Short: "List Kubernetes CronJobs of manifests and make their jobs ping heartbeats",
+	Long: `List the CronJobs of Kubernetes manifest files, or of stdin when no file or
+"-" is given, with the period and grace of the heartbeat each would get and
+the heartbeat it already pings. Only the manifests are read, no cluster is
+needed.
+
+The period comes from spec.schedule, a CRON_TZ= prefix or spec.timeZone
+giving its timezone; the @hourly, @daily and other macros are accepted. A
+CronJob without either runs in the timezone of the kube-controller-manager,
+taken as --time-zone, UTC by default, rather than the timezone of this host.
+The period is computed in that timezone, and the schedule sent to a receiver
+that evaluates it, such as beatify serve, gets a CRON_TZ= prefix naming it.
+
+With --apply, each CronJob without a heartbeat is presented for approval, a
+heartbeat is created for each approved CronJob and the manifests are written
+to stdout with the approved CronJobs patched, or back to their files with
+--in-place. A patched CronJob names its heartbeat in the
+beatify/heartbeat-url annotation and its container, the first one or the
+one named by --container, gets the BEATIFY_HEARTBEAT_URL environment
+variable, which the image has to ping after each run; distroless and
+scratch images have no shell to do it for them. With --wrap, a container
+setting a command gets it wrapped in a shell pinging the URL with its exit
+code after each run instead, which needs sh and curl in the image. Messages
+go to stderr when the manifests go to stdout, and the prompts then read
+from the terminal.`,
+	Example: `  beatify cronjobs k8s/*.yaml
+  beatify cronjobs --apply --in-place -g k8s-prod k8s/backup.yaml
+  kustomize build overlays/prod | beatify cronjobs --apply > cronjobs.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+		for _, arg := range args {
+			if arg == "-" && cronJobInPlace {
+				finish(ExitUsage, fmt.Errorf("--in-place needs manifest files, not stdin"))
+			}
+		}
+
+		if _, err := time.LoadLocation(kube.DefaultTimeZone); err != nil {
+			finish(ExitUsage, fmt.Errorf("unknown timezone '%s': %w", kube.DefaultTimeZone, err))
+		}
+
+		manifests, reports := readCronJobManifests(args)
+
+		switch {
+		case cronJobApply:
+			applyCronJobs(manifests, reports, args)
+		case outputFormat == "json":
+			printJSON(reports)
+		default:
+			printCronJobsTable(reports)
+		}
+	},
+}
+
+func init() {
+	cronJobsCmd.Flags().StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group to add the heartbeats to, created if missing")
+	cronJobsCmd.Flags().BoolVar(&cronJobApply, "apply", false, "Create heartbeats for the approved CronJobs and write the patched manifests")
+	cronJobsCmd.Flags().BoolVar(&cronJobInPlace, "in-place", false, "With --apply, write the patched manifests back to their files instead of stdout")
+	cronJobsCmd.Flags().BoolVar(&cronJobWrap, "wrap", false, "Wrap the container command in a shell pinging the heartbeat, the image needing sh and curl")
+	cronJobsCmd.Flags().StringVar(&kube.ContainerName, "container", "", "Container to instrument, the first container of the job when empty")
+	cronJobsCmd.Flags().StringVar(&kube.DefaultTimeZone, "time-zone", "UTC", "Timezone of the CronJobs without spec.timeZone or a CRON_TZ= prefix, that of the kube-controller-manager")
+}
+
+// helper function to read the manifests and report on their CronJobs
+func readCronJobManifests(paths []string) ([]*kube.Manifest, []*CronJobReport) {
+	var manifests []*kube.Manifest
+	var reports []*CronJobReport
+	for _, path := range paths {
+		var manifest *kube.Manifest
+		var err error
+		if path == "-" {
+			manifest, err = kube.ReadManifest("stdin", os.Stdin)
+		} else {
+			manifest, err = readManifestFile(path)
+		}
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading manifest: %w", err))
+		}
+
+		cronJobs, err := manifest.CronJobs()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading manifest: %w", err))
+		}
+		for _, cronJob := range cronJobs {
+			reports = append(reports, cronJobReport(cronJob))
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, reports
+}
+
+// helper function to read a manifest file
+func readManifestFile(path string) (*kube.Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return kube.ReadManifest(path, file)
+}
+
+// helper function to compute the heartbeat settings of a CronJob
+func cronJobReport(cronJob *kube.CronJob) *CronJobReport {
+	report := &CronJobReport{CronJob: cronJob}
+	if err := cronJob.Validate(); err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	period, grace, spec, err := cronJob.Period()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Period, report.Grace, report.Spec = period, grace, spec
+	return report
+}
+
+// Function to create a heartbeat for each approved CronJob without one and
+// write the patched manifests
+func applyCronJobs(manifests []*kube.Manifest, reports []*CronJobReport, paths []string) {
+	// stdout carries the manifests, so every message goes to stderr and the
+	// prompts read from the terminal when stdin carried a manifest
+	var manifestOut io.Writer = os.Stdout
+	if !cronJobInPlace {
+		os.Stdout = os.Stderr
+	}
+	for _, path := range paths {
+		if path != "-" {
+			continue
+		}
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			finish(ExitUsage, fmt.Errorf("Reading manifests from stdin needs a terminal for the prompts: %w", err))
+		}
+		crontab.PromptInput = tty
+		break
+	}
+
+	requireToken()
+
+	var heartbeatGroupID string
+	if heartbeatGroupName != "" {
+		var err error
+		if heartbeatGroupID, err = heartbeatGroupIDFor(heartbeatGroupName); err != nil {
+			finish(ExitAPI, err)
+		}
+	}
+
+	// Heartbeats created for the CronJobs of each manifest
+	changed := map[string][]heartbeat.Heartbeat{}
+	failed := 0
+	for _, report := range reports {
+		if report.HeartbeatURL != "" {
+			fmt.Printf("Skipping CronJob already pinging a heartbeat: %s/%s\n", report.Namespace, report.Name)
+			continue
+		}
+		if report.Error != "" {
+			fmt.Printf("Skipping CronJob %s/%s: %s\n", report.Namespace, report.Name, report.Error)
+			continue
+		}
+
+		fmt.Printf("CronJob: %s/%s (%s), runs %s\n", report.Namespace, report.Name, report.Path, report.Schedule)
+		fmt.Printf("  Heartbeat: period %s (%ds), grace %s (%ds)\n",
+			tui.FormatSeconds(report.Period), report.Period,
+			tui.FormatSeconds(report.Grace), report.Grace)
+		approved, skipRest, err := crontab.PromptApproval()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading approval: %w", err))
+		}
+		if skipRest {
+			break
+		}
+		if !approved {
+			continue
+		}
+		name, err := crontab.PromptHeartbeatName(crontab.DefaultHeartbeatName(report.Namespace, report.Name))
+		if err != nil {
+			finish(ExitError, err)
+		}
+
+		data, err := heartbeat.PreparePeriodJson(name, heartbeatGroupID, report.Period, report.Grace, report.Spec)
+		if err != nil {
+			fmt.Println("Error preparing config JSON:", err)
+			failed++
+			continue
+		}
+		hb, err := heartbeat.CreateHeartbeat(authToken, data)
+		if err != nil {
+			fmt.Println("Error creating heartbeat:", err)
+			failed++
+			continue
+		}
+		fmt.Println("Heartbeat created successfully:", hb.Attributes.URL)
+
+		wrapped, err := report.Instrument(hb.Attributes.URL, cronJobWrap)
+		if err != nil {
+			fmt.Println("Error patching CronJob:", err)
+			deleteUnusedHeartbeat(hb)
+			failed++
+			continue
+		}
+		if wrapped {
+			fmt.Printf("Command of container %s wrapped to ping the heartbeat\n", report.Container)
+		} else {
+			fmt.Printf("Container %s gets %s, its image has to ping it\n", report.Container, kube.HeartbeatEnv)
+		}
+		changed[report.Path] = append(changed[report.Path], hb)
+	}
+
+	for _, manifest := range manifests {
+		if !cronJobInPlace {
+			if err := manifest.Encode(manifestOut); err != nil {
+				finish(ExitError, err)
+			}
+			continue
+		}
+		if len(changed[manifest.Path]) == 0 {
+			continue
+		}
+		if err := manifest.WriteFile(); err != nil {
+			fmt.Println("Error writing manifest:", err)
+			for _, hb := range changed[manifest.Path] {
+				deleteUnusedHeartbeat(hb)
+				failed++
+			}
+			continue
+		}
+		fmt.Println("Manifest updated:", manifest.Path)
+	}
+
+	if failed > 0 {
+		finish(ExitPartial, fmt.Errorf("%d CronJobs could not be instrumented", failed))
+	}
+}
+
+// helper function to print CronJobs as an aligned table
+func printCronJobsTable(reports []*CronJobReport) {
+	if len(reports) == 0 {
+		fmt.Println("No CronJobs found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSCHEDULE\tTIMEZONE\tPERIOD\tGRACE\tHEARTBEAT")
+	for _, report := range reports {
+		period, grace := tui.FormatSeconds(report.Period), tui.FormatSeconds(report.Grace)
+		if report.Error != "" {
+			period, grace = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			report.Namespace,
+			report.Name,
+			report.Schedule,
+			valueOrDash(report.TimeZone),
+			period,
+			grace,
+			valueOrDash(report.HeartbeatURL),
+		)
+	}
+	w.Flush()
+}
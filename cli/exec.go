@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/history"
+	"github.com/spf13/cobra"
+)
+
+// Exit code used when the wrapped command cannot be started, as a shell does
+const commandNotRunnable = 127
+
+// Time the captured output of a command may stay open once the command
+// exited: a child left running in the background keeps it open, and the
+// run must not wait for that child to end
+const outputWaitDelay = time.Second
+
+var execURL string
+
+var execCmd = &cobra.Command{
+	Use:   "exec [--url HEARTBEAT_URL] [--job NAME] -- COMMAND [ARGS...]",
+	Short: "Run a command and report its outcome to a heartbeat",
+	Long: `Run COMMAND with its arguments, then report the run to the heartbeat at
+HEARTBEAT_URL: a success when the command exits with 0, a failure with the
+exit code otherwise. Beatify exits with the exit code of the command, so
+it can replace the command in a crontab line. No authentication token is
+needed.
+
+With --job, the outcome and the duration of the run are also recorded as
+Prometheus metrics, see the metrics command, and in the run history of the
+job with the last --output-limit bytes printed on stdout and stderr, see
+the history and logs commands; --url can then be left out. With
+--attach-output, the end of the output of a failed run is sent with its
+ping. A child COMMAND leaves running in the background does not hold up the
+run, only what it prints in the second after COMMAND exits is captured.`,
+	Example: `  beatify exec --url https://uptime.betterstack.com/api/v1/heartbeat/abc123 -- /usr/local/bin/backup --full
+  beatify exec --job backup --schedule "0 2 * * *" --textfile-dir /var/lib/node_exporter/textfile -- /usr/local/bin/backup`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if execURL == "" && jobName == "" {
+			finish(ExitUsage, fmt.Errorf("exec needs --url or --job"))
+		}
+		if jobName != "" {
+			if err := history.ValidateJobName(jobName); err != nil {
+				finish(ExitUsage, err)
+			}
+		}
+
+		var output *history.Tail
+		var capture io.Writer
+		if outputLimit > 0 && (jobName != "" || attachOutput) {
+			output = history.NewTail(outputLimit)
+			capture = output
+		}
+
+		start := time.Now()
+		exitCode := runCommand(args, capture)
+		end := time.Now()
+		recordRunMetrics(exitCode, end.Sub(start))
+		recordRunHistory(args, start, end, exitCode, output)
+
+		// A failed ping must not hide the outcome of the command
+		if execURL != "" {
+			if err := heartbeat.PingWithOutput(execURL, exitCode, pingOutput(exitCode, output)); err != nil {
+				fmt.Fprintln(os.Stderr, "Error pinging heartbeat:", err)
+			}
+		}
+		os.Exit(exitCode)
+	},
+}
+
+func init() {
+	// Options after the command name belong to the command
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().StringVar(&execURL, "url", "", "Heartbeat URL to report the run to")
+	addMetricsFlags(execCmd.Flags())
+	addHistoryFlags(execCmd.Flags())
+}
+
+// Function to run a command with the standard streams of beatify and return
+// its exit code, copying what it prints to output when not nil
+func runCommand(args []string, output io.Writer) int {
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if output != nil {
+		command.Stdout = io.MultiWriter(os.Stdout, output)
+		command.Stderr = io.MultiWriter(os.Stderr, output)
+		command.WaitDelay = outputWaitDelay
+	}
+
+	err := command.Run()
+	if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+		// What the background children print after the delay is not captured
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() >= 0 {
+			return exitErr.ExitCode()
+		}
+		// A command killed by a signal exits with 128 plus the signal, as
+		// a shell reports it
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Error running command:", err)
+	return commandNotRunnable
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/tui"
+	"github.com/spf13/cobra"
+)
+
+// Number of next runs shown while approving cron tasks
+const approvalNextRuns = 3
+
+var explainCount int
+
+var explainCmd = &cobra.Command{
+	Use:   "explain SCHEDULE",
+	Short: "Describe a cron schedule and the heartbeat beatify would create for it",
+	Long: `Describe the five-field cron schedule SCHEDULE in plain English, list its
+next run times, and show the period and grace beatify would send when
+creating a heartbeat for it. The @hourly, @daily and other macros are
+accepted, @reboot excepted. The same explanation is shown for each cron task
+during the approval.
+
+Run times are given in the host timezone, or in the timezone of a
+CRON_TZ=ZONE prefix. The period is the longest time between two runs, so
+that no run is reported missed when the schedule pauses: "*/15 9-17 * * 1-5"
+gets the gap from Friday 17:45 to Monday 09:00, 63h15m, and "0 0 29 2 *" the
+four years between two leap days. The grace follows the configured defaults.`,
+	Example: `  beatify explain "30 2 * * 1-5"
+  beatify explain -n 10 "CRON_TZ=Europe/Paris 0 */6 * * *"
+  beatify explain @weekly`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Unquoted schedules arrive as several arguments
+		explanation, err := explainSchedule(strings.Join(args, " "), explainCount)
+		if err != nil {
+			finish(ExitUsage, err)
+		}
+
+		if outputFormat == "json" {
+			printJSON(explanation)
+			return
+		}
+		printExplanation(os.Stdout, explanation)
+	},
+}
+
+func init() {
+	explainCmd.Flags().IntVarP(&explainCount, "count", "n", 5, "Number of next run times to list")
+}
+
+// ScheduleExplanation describes a cron schedule and its heartbeat settings
+type ScheduleExplanation struct {
+	Spec        string      `json:"spec"`
+	Description string      `json:"description"`
+	Timezone    string      `json:"timezone"`
+	NextRuns    []time.Time `json:"next_runs"`
+	Period      int         `json:"period"`
+	Grace       int         `json:"grace"`
+}
+
+// Function to explain a schedule, optionally prefixed with CRON_TZ=ZONE
+func explainSchedule(spec string, count int) (ScheduleExplanation, error) {
+	spec, location, err := crontab.SplitScheduleTimezone(spec)
+	if err != nil {
+		return ScheduleExplanation{}, err
+	}
+	return explainScheduleIn(spec, location, count)
+}
+
+// helper function to explain a schedule in a timezone
+func explainScheduleIn(spec string, location *time.Location, count int) (ScheduleExplanation, error) {
+	if strings.EqualFold(strings.TrimSpace(spec), "@reboot") {
+		return ScheduleExplanation{}, fmt.Errorf("@reboot runs once at boot, it has no run times nor period")
+	}
+	expanded := crontab.ExpandScheduleMacro(spec)
+	runs, err := crontab.NextRuns(expanded, location, time.Now(), count)
+	if err != nil {
+		return ScheduleExplanation{}, err
+	}
+	period, grace, err := heartbeat.SchedulePeriod(expanded)
+	if err != nil {
+		return ScheduleExplanation{}, err
+	}
+
+	// The host timezone is named by its abbreviation, such as CEST
+	timezone := location.String()
+	if location == time.Local {
+		timezone, _ = time.Now().Zone()
+	}
+
+	return ScheduleExplanation{
+		Spec:        spec,
+		Description: crontab.DescribeSchedule(spec),
+		Timezone:    timezone,
+		NextRuns:    runs,
+		Period:      period,
+		Grace:       grace,
+	}, nil
+}
+
+// Function to print an explanation for humans
+func printExplanation(w io.Writer, explanation ScheduleExplanation) {
+	fmt.Fprintf(w, "  Schedule:  %s (%s)\n", explanation.Description, explanation.Timezone)
+	fmt.Fprintf(w, "  Heartbeat: period %s (%ds), grace %s (%ds)\n",
+		tui.FormatSeconds(explanation.Period), explanation.Period,
+		tui.FormatSeconds(explanation.Grace), explanation.Grace)
+	fmt.Fprintln(w, "  Next runs:")
+	for _, run := range explanation.NextRuns {
+		fmt.Fprintf(w, "    %s\n", run.Format("Mon 2006-01-02 15:04 MST"))
+	}
+}
+
+// Function to print the explanation of a cron task being approved
+func explainApprovedSchedule(spec string, location *time.Location) {
+	explanation, err := explainScheduleIn(spec, location, approvalNextRuns)
+	if err != nil {
+		fmt.Println("  Schedule: ", err)
+		return
+	}
+	printExplanation(os.Stdout, explanation)
+}
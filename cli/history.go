@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Largest output sent with the failure ping of a run
+const pingOutputLimit = 10000
+
+var (
+	historyDir    string
+	historyKeep   int
+	historyMaxAge time.Duration
+	outputLimit   int
+	attachOutput  bool
+	logsRun       string
+	logsCount     int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history JOB",
+	Short: "List the recorded runs of a job",
+	Long: `List the runs of JOB recorded by exec --job JOB, oldest first, with their
+start time, duration, exit code and the size of the output kept. The
+output itself is printed by the logs command.
+
+exec keeps the last --history-keep runs of each job, and with
+--history-max-age only the runs younger than that age.`,
+	Example: `  beatify history backup
+  beatify history backup -o json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := history.ValidateJobName(args[0]); err != nil {
+			finish(ExitUsage, err)
+		}
+		history.Dir = historyDir
+		runs, err := history.Runs(args[0])
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading the run history: %w", err))
+		}
+
+		if outputFormat == "json" {
+			printJSON(runs)
+			return
+		}
+		if len(runs) == 0 {
+			fmt.Printf("No run recorded for job '%s'\n", args[0])
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUN\tSTART\tEND\tDURATION\tEXIT\tOUTPUT")
+		for _, run := range runs {
+			output := fmt.Sprintf("%dB", len(run.Output))
+			if run.Truncated {
+				output = "last " + output
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+				run.ID,
+				run.Start.Local().Format(time.RFC3339),
+				run.End.Local().Format(time.RFC3339),
+				run.Duration.Round(time.Millisecond),
+				run.ExitCode,
+				output,
+			)
+		}
+		w.Flush()
+	},
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs JOB",
+	Short: "Print the output of the last runs of a job",
+	Long: `Print what the last run of JOB recorded by exec --job JOB printed on
+stdout and stderr, or of the last -n runs, or of the run picked with --run
+among those listed by the history command. Only the last --output-limit
+bytes of each run are kept.`,
+	Example: `  beatify logs backup
+  beatify logs backup -n 3
+  beatify logs backup --run 20240105T020000.000000000Z`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := history.ValidateJobName(args[0]); err != nil {
+			finish(ExitUsage, err)
+		}
+		history.Dir = historyDir
+		runs, err := history.Runs(args[0])
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading the run history: %w", err))
+		}
+
+		if logsRun != "" {
+			var picked []history.Run
+			for _, run := range runs {
+				if run.ID == logsRun {
+					picked = append(picked, run)
+				}
+			}
+			if len(picked) == 0 {
+				finish(ExitError, fmt.Errorf("No run '%s' recorded for job '%s'", logsRun, args[0]))
+			}
+			runs = picked
+		} else if logsCount > 0 && len(runs) > logsCount {
+			runs = runs[len(runs)-logsCount:]
+		}
+		if len(runs) == 0 {
+			finish(ExitError, fmt.Errorf("No run recorded for job '%s'", args[0]))
+		}
+
+		if outputFormat == "json" {
+			printJSON(runs)
+			return
+		}
+		for i, run := range runs {
+			if len(runs) > 1 {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("==> run %s, exit code %d <==\n", run.ID, run.ExitCode)
+			}
+			if run.Truncated {
+				fmt.Printf("[first bytes dropped, the last %d are kept]\n", len(run.Output))
+			}
+			fmt.Print(run.Output)
+			if run.Output != "" && !strings.HasSuffix(run.Output, "\n") {
+				fmt.Println()
+			}
+		}
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyDir, "history-dir", "", "Directory keeping the runs of each job, defaults to ~/.beatify/history")
+	logsCmd.Flags().StringVar(&historyDir, "history-dir", "", "Directory keeping the runs of each job, defaults to ~/.beatify/history")
+	logsCmd.Flags().StringVar(&logsRun, "run", "", "Run to print, as listed by the history command")
+	logsCmd.Flags().IntVarP(&logsCount, "count", "n", 1, "Number of last runs to print")
+}
+
+// helper function to register the options recording the history of a run
+func addHistoryFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&historyDir, "history-dir", "", "Directory keeping the runs of each job, defaults to ~/.beatify/history")
+	flags.IntVar(&historyKeep, "history-keep", 50, "Number of runs to keep per job, 0 keeps every run")
+	flags.DurationVar(&historyMaxAge, "history-max-age", 0, "Age beyond which runs are removed, e.g. 720h; 0 keeps runs of any age")
+	flags.IntVar(&outputLimit, "output-limit", 64*1024, "Number of last bytes of the output kept per run, 0 keeps no output")
+	flags.BoolVar(&attachOutput, "attach-output", false, "Send the end of the output of a failed run with its ping, shown on the incident by Better Stack")
+}
+
+// Function to record a run in the history of its job when a job name is
+// given; errors are printed without failing the run
+func recordRunHistory(args []string, start, end time.Time, exitCode int, output *history.Tail) {
+	if jobName == "" {
+		return
+	}
+
+	run := &history.Run{
+		Job:      jobName,
+		Command:  args,
+		Start:    start,
+		End:      end,
+		Duration: end.Sub(start),
+		ExitCode: exitCode,
+	}
+	if currentUser, err := user.Current(); err == nil {
+		run.User = currentUser.Username
+	}
+	if output != nil {
+		run.Output = output.String()
+		run.Truncated = output.Truncated()
+	}
+
+	history.Dir = historyDir
+	history.KeepRuns = historyKeep
+	history.KeepAge = historyMaxAge
+	if err := history.Record(run); err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording job history:", err)
+	}
+}
+
+// helper function to get the end of the output sent with a failure ping
+func pingOutput(exitCode int, output *history.Tail) string {
+	if !attachOutput || exitCode == 0 || output == nil {
+		return ""
+	}
+	text := output.String()
+	if len(text) > pingOutputLimit {
+		text = text[len(text)-pingOutputLimit:]
+	}
+	return text
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintDisable   []string
+	lintListRules bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the crontab for common mistakes before instrumenting it",
+	Long: `Check every line of the crontab for common mistakes, such as an unescaped %
+that would cut the appended ping, a missing trailing newline, tasks whose
+output is mailed on every run, tasks running every minute, duplicate lines
+and invalid schedules. Nothing is changed and the API is not called.
+
+Each finding names the rule that raised it. Rules are disabled with
+--disable, and --list-rules lists them. Beatify exits with 8 when a finding
+remains.`,
+	Example: `  beatify lint -u www-data
+  beatify lint --disable no-redirect,every-minute -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if lintListRules {
+			printLintRules()
+			return
+		}
+
+		disabled := map[string]bool{}
+		for _, id := range lintDisable {
+			if !crontab.IsLintRule(id) {
+				finish(ExitUsage, fmt.Errorf("Unknown lint rule '%s', see beatify lint --list-rules", id))
+			}
+			disabled[id] = true
+		}
+
+		findings, err := crontab.LintUserCrontab(mustResolveCrontabUser(), disabled)
+		if err != nil {
+			finish(ExitCrontab, fmt.Errorf("Error reading crontab: %w", err))
+		}
+
+		if outputFormat == "json" {
+			printJSON(struct {
+				Findings []crontab.LintFinding `json:"findings"`
+			}{findings})
+		} else if len(findings) == 0 {
+			fmt.Println("No problem found.")
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "LINE\tRULE\tMESSAGE\tTEXT")
+			for _, finding := range findings {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", finding.Line, finding.Rule, finding.Message, finding.Text)
+			}
+			w.Flush()
+		}
+
+		if len(findings) > 0 {
+			finish(ExitFindings, nil)
+		}
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringSliceVar(&lintDisable, "disable", nil, "Comma-separated IDs of the rules not to check")
+	lintCmd.Flags().BoolVar(&lintListRules, "list-rules", false, "List the rules and their IDs")
+}
+
+// helper function to list the lint rules
+func printLintRules() {
+	if outputFormat == "json" {
+		printJSON(crontab.LintRules)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tDESCRIPTION")
+	for _, rule := range crontab.LintRules {
+		fmt.Fprintf(w, "%s\t%s\n", rule.ID, rule.Description)
+	}
+	w.Flush()
+}
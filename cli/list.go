@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the heartbeats of the BetterUptime account",
+	Long: `List every heartbeat of the BetterUptime account with its status, period,
+grace, group and ping URL, whichever host or crontab it belongs to.`,
+	Example: `  beatify list
+  beatify list -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireToken()
+
+		heartbeats, err := heartbeat.ListHeartbeats(authToken)
+		if err != nil {
+			finish(ExitAPI, fmt.Errorf("Error listing heartbeats: %w", err))
+		}
+
+		if outputFormat == "json" {
+			if heartbeats == nil {
+				heartbeats = []heartbeat.Heartbeat{}
+			}
+			printJSON(heartbeats)
+			return
+		}
+
+		heartbeatGroups, err := heartbeat.ListHeartbeatGroups(authToken)
+		if err != nil {
+			finish(ExitAPI, fmt.Errorf("Error listing heartbeat groups: %w", err))
+		}
+		groupNames := make(map[string]string, len(heartbeatGroups))
+		for _, group := range heartbeatGroups {
+			groupNames[group.ID] = group.Attributes.Name
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS\tPERIOD\tGRACE\tGROUP\tURL")
+		for _, hb := range heartbeats {
+			group := ""
+			if hb.Attributes.HeartbeatGroupID != 0 {
+				group = groupNames[strconv.Itoa(hb.Attributes.HeartbeatGroupID)]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%ds\t%ds\t%s\t%s\n",
+				hb.ID,
+				hb.Attributes.Name,
+				valueOrDash(hb.Attributes.Status),
+				hb.Attributes.Period,
+				hb.Attributes.Grace,
+				valueOrDash(group),
+				hb.Attributes.URL,
+			)
+		}
+		w.Flush()
+	},
+}
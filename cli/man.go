@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+// Author named in the generated manpages
+const manAuthor = "Wayne Theisinger <wayne@it-jonction-lab.com>"
+
+var manDir string
+
+var manCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate the manpages of beatify",
+	Long: `Generate a manpage in section 1 for beatify and each of its commands from
+the command definitions, in the directory given by --dir.`,
+	Example: `  beatify man --dir /usr/local/share/man/man1`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generateManPages(rootCmd, manDir); err != nil {
+			finish(ExitError, fmt.Errorf("Error generating manpages: %w", err))
+		}
+		fmt.Println("Manpages written to", manDir)
+	},
+}
+
+func init() {
+	manCmd.Flags().StringVar(&manDir, "dir", ".", "Directory to write the manpages to")
+}
+
+// Function to write the manpage of a command and of each of its subcommands
+func generateManPages(cmd *cobra.Command, dir string) error {
+	for _, child := range cmd.Commands() {
+		if !child.IsAvailableCommand() || child.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		if err := generateManPages(child, dir); err != nil {
+			return err
+		}
+	}
+
+	header := &doc.GenManHeader{
+		Title:   strings.ToUpper(strings.ReplaceAll(cmd.CommandPath(), " ", "-")),
+		Section: "1",
+		Source:  "Beatify",
+		Manual:  "Beatify Manual",
+	}
+	cmd.DisableAutoGenTag = true
+
+	var page bytes.Buffer
+	if err := doc.GenMan(cmd, header, &page); err != nil {
+		return err
+	}
+	page.WriteString(".SH EXIT STATUS\n.nf\n")
+	page.WriteString(exitStatusDoc)
+	page.WriteString("\n.fi\n.SH AUTHOR\n")
+	page.WriteString(manAuthor)
+	page.WriteString("\n")
+
+	name := strings.ReplaceAll(cmd.CommandPath(), " ", "-") + ".1"
+	return os.WriteFile(filepath.Join(dir, name), page.Bytes(), 0644)
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/metrics"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	jobName            string
+	metricsSchedule    string
+	metricsTextfileDir string
+	metricsDir         string
+	metricsListen      string
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Print or serve the Prometheus metrics of the cron jobs run through beatify",
+	Long: `Print the metrics recorded by exec and ping for the runs given a --job
+name, in the Prometheus text format, or serve them at /metrics with
+--listen. Every metric is a gauge labelled by job_name and user, job being
+the label of the Prometheus scrape:
+
+    cron_job_last_success_timestamp    Unix time of the last successful run
+    cron_job_last_exit_code            exit code of the last run
+    cron_job_duration_seconds          duration of the last run, exec only
+    cron_job_expected_period_seconds   time between two runs, from --schedule
+
+exec and ping can also write the metrics of their job to the directory of
+the textfile collector of the node exporter with --textfile-dir, so no
+endpoint is needed. A recording rule on the last success and the expected
+period then catches missed runs without the heartbeat provider:
+
+    time() - cron_job_last_success_timestamp > 2 * cron_job_expected_period_seconds`,
+	Example: `  beatify exec --job backup --schedule "0 2 * * *" --textfile-dir /var/lib/node_exporter/textfile -- /usr/local/bin/backup
+  beatify metrics --listen 127.0.0.1:9470`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		metrics.StateDir = metricsDir
+
+		if metricsListen == "" {
+			states, err := metrics.Jobs()
+			if err != nil {
+				finish(ExitError, fmt.Errorf("Error reading the job metrics: %w", err))
+			}
+			if outputFormat == "json" {
+				printJSON(states)
+				return
+			}
+			metrics.Write(os.Stdout, states)
+			return
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		server := &http.Server{Addr: metricsListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		fmt.Printf("Serving metrics on http://%s/metrics\n", metricsListen)
+		if err := server.ListenAndServe(); err != nil {
+			finish(ExitError, fmt.Errorf("Error serving metrics: %w", err))
+		}
+	},
+}
+
+func init() {
+	metricsCmd.Flags().StringVar(&metricsDir, "metrics-dir", "", "Directory keeping the state of each job, defaults to ~/.beatify/metrics")
+	metricsCmd.Flags().StringVar(&metricsListen, "listen", "", "Address to serve /metrics on instead of printing the metrics")
+}
+
+// helper function to register the options recording the metrics of a run
+func addMetricsFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&jobName, "job", "", "Job name of the run, labelling its metrics and its history; nothing is recorded without it")
+	flags.StringVar(&metricsSchedule, "schedule", "", "Cron schedule of the job, giving its expected period")
+	flags.StringVar(&metricsTextfileDir, "textfile-dir", "", "Directory of the node exporter textfile collector to write the metrics of the job to")
+	flags.StringVar(&metricsDir, "metrics-dir", "", "Directory keeping the state of each job, defaults to ~/.beatify/metrics")
+}
+
+// Function to record the metrics of a run when a job name is given; errors
+// are printed without failing the run
+func recordRunMetrics(exitCode int, duration time.Duration) {
+	if jobName == "" {
+		return
+	}
+	if err := recordMetrics(exitCode, duration); err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording job metrics:", err)
+	}
+}
+
+// helper function to record the metrics of a run
+func recordMetrics(exitCode int, duration time.Duration) error {
+	currentUser, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("Error obtaining current user: %w", err)
+	}
+
+	run := metrics.Run{
+		Job:      jobName,
+		User:     currentUser.Username,
+		ExitCode: exitCode,
+		Time:     time.Now(),
+		Duration: duration,
+	}
+	if metricsSchedule != "" {
+		spec, _, err := crontab.SplitScheduleTimezone(metricsSchedule)
+		if err != nil {
+			return err
+		}
+		period, _, err := heartbeat.SchedulePeriod(spec)
+		if err != nil {
+			return fmt.Errorf("Error reading schedule '%s': %w", metricsSchedule, err)
+		}
+		run.Period = period
+	}
+
+	metrics.StateDir = metricsDir
+	state, err := metrics.Record(run)
+	if err != nil {
+		return err
+	}
+	if metricsTextfileDir != "" {
+		return metrics.WriteTextfile(metricsTextfileDir, state)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pingExitCode int
+	pingFail     bool
+	pingDuration time.Duration
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping [HEARTBEAT_URL]",
+	Short: "Report a run to a heartbeat",
+	Long: `Report a run to the heartbeat at HEARTBEAT_URL. Without options the run is
+reported as a success; --fail or a non-zero --exit-code reports a failed
+run. No authentication token is needed.
+
+With --job, the run is also recorded as Prometheus metrics, see the
+metrics command, and HEARTBEAT_URL can be left out.`,
+	Example: `  beatify ping https://uptime.betterstack.com/api/v1/heartbeat/abc123
+  beatify ping --exit-code 3 https://uptime.betterstack.com/api/v1/heartbeat/abc123
+  beatify ping --job rotate-logs --schedule "0 0 * * *" --duration 42s --textfile-dir /var/lib/node_exporter/textfile`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitCode := pingExitCode
+		if pingFail && exitCode == 0 {
+			exitCode = 1
+		}
+
+		if len(args) == 0 && jobName == "" {
+			finish(ExitUsage, fmt.Errorf("ping needs HEARTBEAT_URL or --job"))
+		}
+
+		recordRunMetrics(exitCode, pingDuration)
+		if len(args) == 1 {
+			if err := heartbeat.Ping(args[0], exitCode); err != nil {
+				finish(ExitAPI, fmt.Errorf("Error pinging heartbeat: %w", err))
+			}
+		}
+	},
+}
+
+func init() {
+	pingCmd.Flags().IntVar(&pingExitCode, "exit-code", 0, "Exit code of the run, non-zero codes report a failure")
+	pingCmd.Flags().BoolVar(&pingFail, "fail", false, "Report a failed run")
+	pingCmd.Flags().DurationVar(&pingDuration, "duration", 0, "Duration of the run, recorded with --job")
+	addMetricsFlags(pingCmd.Flags())
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+// Token reference of the selected profile, resolved only when needed
+var profileToken string
+
+// Alerting settings of the configuration, used by serve
+var alertingConfig config.Alerting
+
+// Function to apply the selected profile to the settings not given as flags
+func applyProfile(cmd *cobra.Command) error {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	name := profileName
+	if name == "" {
+		name = os.Getenv("BEATIFY_PROFILE")
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return err
+	}
+	alertingConfig = cfg.Alerting
+
+	// Flags given on the command line take precedence over the profile
+	if !cmd.Flags().Changed("auth-token") {
+		profileToken = profile.Token
+	}
+	if !cmd.Flags().Changed("heartbeat-group") && profile.HeartbeatGroup != "" {
+		heartbeatGroupName = profile.HeartbeatGroup
+	}
+	if !cmd.Flags().Changed("user") && profile.User != "" {
+		crontabUser = profile.User
+	}
+
+	if profile.APIBaseURL != "" {
+		heartbeat.APIBaseURL = profile.APIBaseURL
+	} else if profile.Provider != "" {
+		heartbeat.APIBaseURL = config.Providers[profile.Provider]
+	}
+	// The local receiver evaluates the cron schedule itself
+	heartbeat.Defaults.SendSchedule = profile.Provider == "local"
+	if profile.Grace != "" {
+		ratio, seconds, err := config.ParseGrace(profile.Grace)
+		if err != nil {
+			return err
+		}
+		heartbeat.Defaults.GraceRatio = ratio
+		heartbeat.Defaults.Grace = seconds
+	}
+	heartbeat.Defaults.Call = profile.Call
+	heartbeat.Defaults.SMS = profile.SMS
+	heartbeat.Defaults.Email = profile.Email
+	heartbeat.Defaults.Push = profile.Push
+	heartbeat.Defaults.TeamWait = profile.TeamWait
+	if profile.NameTemplate != "" {
+		crontab.NameTemplate = profile.NameTemplate
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+var deleteHeartbeats bool
+
+var removeCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove the heartbeat ping from approved cron tasks",
+	Long: `Present each cron task of the crontab that pings a heartbeat for approval,
+and remove the curl request beatify appended to each approved task. The
+heartbeats themselves are left in the BetterUptime account, unless
+--delete-heartbeat is given: they are then deleted once the crontab no
+longer pings them.
+
+As with apply, the crontab is backed up first and locked for the session.`,
+	Example: `  beatify remove -u www-data
+  beatify remove -u www-data --delete-heartbeat`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if deleteHeartbeats {
+			requireToken()
+		}
+		removeUser := mustResolveCrontabUser()
+
+		// Lock the crontab until the updated one is installed
+		lock, err := crontab.LockCrontab(removeUser)
+		if err != nil {
+			finish(ExitCrontab, err)
+		}
+		defer lock.Unlock()
+
+		cronTasks, err := crontab.ParseAndApproveManagedCronTasks(removeUser)
+		if err != nil {
+			finish(ExitCrontab, fmt.Errorf("Error parsing crontab: %w", err))
+		}
+
+		if err := crontab.RemoveCronsCommand(cronTasks, removeUser); err != nil {
+			code := ExitInstall
+			if errors.Is(err, crontab.ErrCrontabChanged) {
+				code = ExitCrontab
+			}
+			finish(code, fmt.Errorf("Error removing curl command from cron tasks: %w", err))
+		}
+		fmt.Printf("Curl commands removed from %d cron tasks.\n", len(cronTasks))
+
+		if deleteHeartbeats {
+			deleteTaskHeartbeats(cronTasks)
+		}
+	},
+}
+
+func init() {
+	removeCmd.Flags().BoolVar(&deleteHeartbeats, "delete-heartbeat", false, "Also delete the heartbeats of the approved cron tasks")
+}
+
+// Function to delete the heartbeats pinged by cron tasks
+func deleteTaskHeartbeats(cronTasks []crontab.CronTask) {
+	heartbeats, err := heartbeat.ListHeartbeats(authToken)
+	if err != nil {
+		finish(ExitAPI, fmt.Errorf("Error listing heartbeats: %w", err))
+	}
+	heartbeatIDs := make(map[string]string, len(heartbeats))
+	for _, hb := range heartbeats {
+		heartbeatIDs[hb.Attributes.URL] = hb.ID
+	}
+
+	failed := 0
+	for _, cronTask := range cronTasks {
+		id, ok := heartbeatIDs[cronTask.HeartbeatURL]
+		if !ok {
+			fmt.Println("No heartbeat found for", cronTask.HeartbeatURL)
+			continue
+		}
+		if err := heartbeat.DeleteHeartbeat(authToken, id); err != nil {
+			fmt.Println("Error deleting heartbeat:", err)
+			failed++
+			continue
+		}
+		fmt.Println("Heartbeat deleted:", cronTask.HeartbeatURL)
+	}
+
+	if failed > 0 {
+		finish(ExitPartial, fmt.Errorf("%d heartbeats could not be deleted", failed))
+	}
+}
+
+// Function to delete a heartbeat created for a job that could not be made to
+// ping it, so it does not report the runs it never hears of as missed
+func deleteUnusedHeartbeat(hb heartbeat.Heartbeat) {
+	if err := heartbeat.DeleteHeartbeat(authToken, hb.ID); err != nil {
+		fmt.Println("Error deleting heartbeat:", err)
+		return
+	}
+	fmt.Println("Heartbeat deleted:", hb.Attributes.URL)
+}
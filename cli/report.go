@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes of beatify, documented in the manpage
+const (
+	ExitOK       = 0 // every approved job was instrumented
+	ExitError    = 1 // generic error, e.g. invalid configuration
+	ExitUsage    = 2 // unknown command or option
+	ExitAuth     = 3 // no token, or the token was rejected by the API
+	ExitPartial  = 4 // some heartbeats could not be created
+	ExitInstall  = 5 // the updated crontab could not be installed
+	ExitCrontab  = 6 // a crontab could not be read, locked, or changed during review
+	ExitAPI      = 7 // the API could not be reached or returned an error
+	ExitFindings = 8 // lint found mistakes in the crontab
+)
+
+// Statuses of a job in the report
+const (
+	JobSkipped  = "skipped"
+	JobApproved = "approved"
+	JobCreated  = "created"
+	JobFailed   = "failed"
+)
+
+// JobReport records what happened to one cron task
+type JobReport struct {
+	Crontab      string `json:"crontab"`
+	Line         string `json:"line,omitempty"`
+	Spec         string `json:"spec,omitempty"`
+	Task         string `json:"task,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Status       string `json:"status"`
+	Reason       string `json:"reason,omitempty"`
+	HeartbeatID  string `json:"heartbeat_id,omitempty"`
+	HeartbeatURL string `json:"heartbeat_url,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// CrontabReport records the outcome of instrumenting one crontab
+type CrontabReport struct {
+	Name      string `json:"name"`
+	Approved  int    `json:"approved"`
+	Created   int    `json:"created"`
+	Failed    int    `json:"failed"`
+	Installed bool   `json:"installed"`
+	Error     string `json:"error,omitempty"`
+
+	Err      error `json:"-"`
+	ExitCode int   `json:"-"`
+}
+
+// Report is the result of a run, printed as JSON with --output json
+type Report struct {
+	Crontabs []*CrontabReport `json:"crontabs"`
+	Jobs     []*JobReport     `json:"jobs"`
+	ExitCode int              `json:"exit_code"`
+	Error    string           `json:"error,omitempty"`
+}
+
+var (
+	report                  = Report{Crontabs: []*CrontabReport{}, Jobs: []*JobReport{}}
+	reportOut     io.Writer = os.Stdout
+	reportEnabled bool
+)
+
+// Function to send messages to stderr so stdout only carries the JSON output
+func useJSONOutput() {
+	reportOut = os.Stdout
+	os.Stdout = os.Stderr
+}
+
+// Function to end the run, printing err and the JSON report when enabled
+func finish(code int, err error) {
+	if err != nil {
+		fmt.Println(err)
+		report.Error = err.Error()
+	}
+	report.ExitCode = code
+	closeStreamStore()
+
+	if reportEnabled {
+		encoder := json.NewEncoder(reportOut)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(report)
+	}
+
+	os.Exit(code)
+}
+
+// Function to compute the exit code of the apply flow from the crontab
+// reports: the first crontab error wins, then failed heartbeats
+func reportExitCode() int {
+	code := ExitOK
+	for _, crontabReport := range report.Crontabs {
+		if crontabReport.ExitCode != ExitOK {
+			return crontabReport.ExitCode
+		}
+		if crontabReport.Failed > 0 {
+			code = ExitPartial
+		}
+	}
+	return code
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/spf13/cobra"
+)
+
+// Layouts accepted by the --at option of the restore command
+var restoreTimeLayouts = []string{
+	time.RFC3339,
+	crontab.BackupTimeLayout,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [--list | --at TIME]",
+	Short: "Reinstall a backup of the crontab",
+	Long: `Reinstall a backup of the crontab. Beatify takes a timestamped backup of
+the crontab before each run, in a subdirectory per crontab user of the
+backup directory. Without options the latest backup is restored; --at
+restores the latest backup taken at or before TIME and --list lists the
+available backups instead. The crontab replaced by a restore is backed up
+first, so the restore can be undone.`,
+	Example: `  beatify restore -u www-data --list
+  beatify restore -u www-data --at "2023-06-01 08:00"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Restoring a backup does not need the BetterUptime API
+		handleRestore(mustResolveCrontabUser())
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreList, "list", false, "List the crontab backups instead of restoring one")
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "Restore the latest backup taken at or before this time, as \"2006-01-02 15:04\", \"2006-01-02\" or RFC 3339")
+}
+
+// Function to list or reinstall the crontab backups of a user
+func handleRestore(crontabUser string) {
+	if restoreList {
+		backups, err := crontab.ListBackups(crontabUser)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error listing crontab backups: %w", err))
+		}
+		if len(backups) == 0 {
+			fmt.Println("No crontab backups found for", crontabUser)
+			return
+		}
+		for _, backup := range backups {
+			fmt.Printf("%s  %s\n", backup.Taken.Local().Format("2006-01-02 15:04:05"), backup.Path)
+		}
+		return
+	}
+
+	at := time.Now()
+	if restoreAt != "" {
+		var err error
+		at, err = parseRestoreTime(restoreAt)
+		if err != nil {
+			finish(ExitUsage, err)
+		}
+	}
+
+	backup, err := crontab.FindBackup(crontabUser, at)
+	if err != nil {
+		finish(ExitError, fmt.Errorf("Error finding crontab backup: %w", err))
+	}
+
+	lock, err := crontab.LockCrontab(crontabUser)
+	if err != nil {
+		finish(ExitCrontab, err)
+	}
+	defer lock.Unlock()
+
+	fmt.Println("Restoring crontab backup:", backup.Path)
+	replaced, err := crontab.RestoreBackup(backup)
+	if replaced != "" {
+		fmt.Println("Crontab replaced saved to:", replaced)
+	}
+	if err != nil {
+		finish(ExitInstall, fmt.Errorf("Error restoring crontab backup: %w", err))
+	}
+	fmt.Println("Crontab restored successfully.")
+}
+
+// helper function to parse the --at option in the local timezone
+func parseRestoreTime(value string) (time.Time, error) {
+	for _, layout := range restoreTimeLayouts {
+		location := time.Local
+		if layout == crontab.BackupTimeLayout {
+			location = time.UTC
+		}
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			// A bare date means any backup taken during that day
+			if layout == "2006-01-02" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s': expected a format such as '2006-01-02 15:04' or RFC 3339", value)
+}
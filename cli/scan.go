@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "List the cron tasks of the crontab and whether they ping a heartbeat",
+	Long: `List every cron task of the crontab with its state: "managed" when it
+already pings a heartbeat, "unmanaged" when it does not, and "invalid" when
+the line cannot be parsed. Nothing is changed and the API is not called.`,
+	Example: `  beatify scan -u www-data
+  beatify scan --store file --crontab-file ops/crontabs/www-data -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tasks, err := crontab.ScanCrontab(mustResolveCrontabUser())
+		if err != nil {
+			finish(ExitCrontab, fmt.Errorf("Error reading crontab: %w", err))
+		}
+
+		if outputFormat == "json" {
+			printJSON(scanReport(tasks))
+			return
+		}
+
+		if len(tasks) == 0 {
+			fmt.Println("No cron tasks found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATE\tSCHEDULE\tTASK\tHEARTBEAT URL")
+		for _, task := range tasks {
+			if task.State == crontab.TaskInvalid {
+				fmt.Fprintf(w, "%s\t-\t%s\t-\n", task.State, task.Line)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.State, task.Spec, task.Task, valueOrDash(task.HeartbeatURL))
+		}
+		w.Flush()
+	},
+}
+
+// ScannedTaskReport is the JSON form of a scanned cron task
+type ScannedTaskReport struct {
+	State        string `json:"state"`
+	Line         string `json:"line"`
+	Spec         string `json:"spec,omitempty"`
+	Task         string `json:"task,omitempty"`
+	HeartbeatURL string `json:"heartbeat_url,omitempty"`
+}
+
+// helper function to convert scanned tasks to their JSON form
+func scanReport(tasks []crontab.ScannedTask) []ScannedTaskReport {
+	reports := []ScannedTaskReport{}
+	for _, task := range tasks {
+		reports = append(reports, ScannedTaskReport{
+			State:        task.State,
+			Line:         task.Line,
+			Spec:         task.Spec,
+			Task:         task.Task,
+			HeartbeatURL: task.HeartbeatURL,
+		})
+	}
+	return reports
+}
+
+// Function to print a value as indented JSON on the report output
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(reportOut)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		finish(ExitError, fmt.Errorf("Error encoding JSON output: %w", err))
+	}
+}
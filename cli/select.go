@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/tui"
+)
+
+// Function to build the review function selecting the cron tasks of a
+// crontab in the terminal UI
+func selectWithTUI(crontabUser string) func([]crontab.ScannedTask) ([]crontab.CronTask, error) {
+	return func(tasks []crontab.ScannedTask) ([]crontab.CronTask, error) {
+		selector := &tui.Selector{
+			Title:      fmt.Sprintf("Select the cron tasks of %s to monitor", crontabUser),
+			Items:      selectorItems(crontabUser, tasks),
+			ParseGrace: parseItemGrace,
+		}
+
+		// Keys are read where the approval prompts read their answers
+		terminal, restore, err := tui.OpenTerminal(crontab.PromptInput, os.Stdout)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := selector.Run(terminal)
+		restore()
+		if err != nil {
+			return nil, err
+		}
+
+		cronTasks := make([]crontab.CronTask, 0, len(selected))
+		for _, item := range selected {
+			cronTask := crontab.CronTask{
+				Spec:  item.Spec,
+				Task:  item.Task,
+				Name:  item.Name,
+				Group: item.Group,
+				Grace: crontab.NoGrace,
+			}
+			if item.GraceChosen {
+				cronTask.Grace = item.Grace
+			}
+			cronTasks = append(cronTasks, cronTask)
+		}
+		return cronTasks, nil
+	}
+}
+
+// helper function to turn scanned cron tasks into selector items
+func selectorItems(crontabUser string, tasks []crontab.ScannedTask) []tui.Item {
+	items := make([]tui.Item, 0, len(tasks))
+	for _, task := range tasks {
+		item := tui.Item{
+			Line:  task.Line,
+			Spec:  task.Spec,
+			Task:  task.Task,
+			State: task.State,
+			Group: heartbeatGroupName,
+		}
+
+		if task.State == crontab.TaskUnmanaged {
+			period, grace, err := heartbeat.SchedulePeriod(task.Spec)
+			if err != nil {
+				item.State = "invalid schedule"
+			} else if err := crontab.CheckInstrumentable(task.Line); err != nil {
+				item.State = err.Error()
+			} else {
+				item.Selectable = true
+				item.Schedule = crontab.DescribeSchedule(task.Spec)
+				item.Period, item.Grace = period, grace
+				item.Name = crontab.DefaultTaskHeartbeatName(crontabUser, task.CronTask)
+			}
+		}
+
+		items = append(items, item)
+	}
+	return items
+}
+
+// helper function to read a grace typed in the terminal UI as a percentage
+// of the period, seconds or a duration
+func parseItemGrace(value string, period int) (int, error) {
+	ratio, seconds, err := config.ParseGrace(value)
+	if err != nil {
+		return 0, err
+	}
+	if ratio > 0 {
+		return int(float64(period) * ratio), nil
+	}
+	return seconds, nil
+}
@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/notify"
+	"github.com/IT-JONCTION/beatify/receiver"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen        string
+	serveStateFile     string
+	servePublicURL     string
+	serveCheckInterval time.Duration
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receive heartbeats locally and detect missed runs",
+	Long: `Run a heartbeat receiver for hosts that cannot reach Better Stack. The
+receiver serves the heartbeat and heartbeat group endpoints of the API, so
+apply, list, status, sync and remove work against it through a profile with
+"provider: local", and the ping URLs it hands out take the same /fail and
+/EXIT_CODE suffixes.
+
+The receiver keeps the last ping of every heartbeat in --state-file. A
+heartbeat created with its cron schedule is expected at the next run of the
+schedule after its last ping, others a period after it; once the grace has
+passed too an incident is raised. A ping reporting a failure raises an
+incident at once, and the next successful ping resolves it, deleting the
+heartbeat closes it. Incidents are logged to stdout and listed at
+/api/v2/incidents, the resolved ones for 30 days, 500 at most.
+
+Incidents are also sent to the notifiers of the alerting section of the
+configuration: SMTP mail, JSON webhooks, Slack or Mattermost incoming
+webhooks and scripts. Routes pick the notifiers by heartbeat name and
+event, an event repeated within the dedup window is dropped and open
+incidents are reminded of at the reminder interval:
+
+    alerting:
+      reminder: 4h
+      dedup: 10m
+      notifiers:
+        ops-mail:
+          type: smtp
+          server: mail.lan:25
+          from: beatify@lan
+          to: [ops@lan]
+        chat:
+          type: slack          # or webhook, with the same url setting
+          url: https://chat.lan/hooks/abc123
+        pager:
+          type: script
+          command: /usr/local/bin/page-oncall
+      routes:
+        - match: "*backup*"
+          notifiers: [ops-mail, pager]
+        - events: [down, failed]
+          notifiers: [chat]
+
+The API token is read like for the other commands and is not checked
+against Better Stack.`,
+	Example: `  beatify serve --token-file /etc/beatify/receiver-token --listen 0.0.0.0:8470 --public-url http://monitor.lan:8470`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := config.ResolveToken(config.TokenSources{
+			Flag:      authToken,
+			File:      tokenFile,
+			Command:   tokenCommand,
+			Reference: profileToken,
+		})
+		if err != nil {
+			finish(ExitAuth, fmt.Errorf("Error reading the authentication token: %w", err))
+		}
+
+		publicURL := servePublicURL
+		if publicURL == "" {
+			publicURL = "http://" + serveListen
+		}
+		rcv, err := receiver.New(serveStateFile, token, publicURL)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error loading the receiver state: %w", err))
+		}
+
+		dispatcher, err := notify.NewDispatcher(alertingConfig)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error loading the alerting settings: %w", err))
+		}
+		// Incidents left open by a previous run are reminded of, not alerted again
+		for _, incident := range rcv.Incidents() {
+			if !incident.Open() {
+				continue
+			}
+			event := receiver.Event{Type: receiver.EventDown, Incident: incident, Time: incident.StartedAt}
+			if incident.Cause == receiver.CauseFailed {
+				event.Type = receiver.EventFailed
+			}
+			dispatcher.Track(incidentAlert(event), time.Now())
+		}
+
+		// Alerts are sent in order, without holding the pings up, and the
+		// reminders on their own so neither waits for a slow notifier
+		alerts := make(chan notify.Alert, 100)
+		go func() {
+			for alert := range alerts {
+				printNotifyErrors(dispatcher.Dispatch(alert))
+			}
+		}()
+		reminders := time.NewTicker(serveCheckInterval)
+		defer reminders.Stop()
+		go func() {
+			for now := range reminders.C {
+				printNotifyErrors(dispatcher.Remind(now))
+			}
+		}()
+		rcv.OnEvent = func(event receiver.Event) {
+			logReceiverEvent(event)
+			if event.Type == receiver.EventDeleted {
+				dispatcher.Forget(event.Incident.ID)
+				return
+			}
+			select {
+			case alerts <- incidentAlert(event):
+			default:
+				// The notifiers are behind, the event is only logged
+				fmt.Printf("Alert queue full, incident %s not notified\n", event.Incident.ID)
+			}
+		}
+
+		listener, err := net.Listen("tcp", serveListen)
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error listening on %s: %w", serveListen, err))
+		}
+		server := &http.Server{Handler: rcv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		fmt.Printf("Receiving heartbeats on %s, API at %s%s\n", listener.Addr(), rcv.PublicURL, receiver.APIPath)
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		ticker := time.NewTicker(serveCheckInterval)
+		defer ticker.Stop()
+
+		rcv.Evaluate()
+		for {
+			select {
+			case <-ticker.C:
+				rcv.Evaluate()
+			case <-signals:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				server.Shutdown(ctx)
+				cancel()
+				finish(ExitOK, nil)
+			}
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8470", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveStateFile, "state-file", "/var/lib/beatify/receiver.json", "File keeping the heartbeats, their last ping and the incidents")
+	serveCmd.Flags().StringVar(&servePublicURL, "public-url", "", "Base of the ping URLs handed out, defaults to http://LISTEN")
+	serveCmd.Flags().DurationVar(&serveCheckInterval, "check-interval", 30*time.Second, "How often overdue heartbeats are looked for")
+}
+
+// helper function to log an incident raised or resolved by the receiver
+func logReceiverEvent(event receiver.Event) {
+	timestamp := event.Time.Format(time.RFC3339)
+	switch event.Type {
+	case receiver.EventDown:
+		fmt.Printf("%s Incident %s: heartbeat '%s' missed its run\n", timestamp, event.Incident.ID, event.Check.Name)
+	case receiver.EventFailed:
+		fmt.Printf("%s Incident %s: heartbeat '%s' reported a failure (exit code %d)\n", timestamp, event.Incident.ID, event.Check.Name, event.Incident.ExitCode)
+	case receiver.EventRecovered:
+		fmt.Printf("%s Incident %s resolved: heartbeat '%s' is up after %s\n", timestamp, event.Incident.ID, event.Check.Name,
+			event.Time.Sub(event.Incident.StartedAt).Round(time.Second))
+	case receiver.EventDeleted:
+		fmt.Printf("%s Incident %s closed: heartbeat '%s' was deleted\n", timestamp, event.Incident.ID, event.Check.Name)
+	}
+}
+
+// helper function to turn an event of the receiver into an alert
+func incidentAlert(event receiver.Event) notify.Alert {
+	return notify.Alert{
+		Event:      event.Type,
+		CheckID:    event.Incident.CheckID,
+		Check:      event.Incident.CheckName,
+		IncidentID: event.Incident.ID,
+		ExitCode:   event.Incident.ExitCode,
+		Output:     event.Incident.Output,
+		StartedAt:  event.Incident.StartedAt,
+		Time:       event.Time,
+	}
+}
+
+// helper function to log the notifiers that failed
+func printNotifyErrors(errs []error) {
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+// JobStatus describes the live health of one managed cron task
+type JobStatus struct {
+	Spec          string `json:"spec"`
+	Task          string `json:"task"`
+	HeartbeatURL  string `json:"heartbeat_url"`
+	HeartbeatID   string `json:"heartbeat_id,omitempty"`
+	HeartbeatName string `json:"heartbeat_name,omitempty"`
+	Group         string `json:"group,omitempty"`
+	Period        int    `json:"period,omitempty"`
+	Grace         int    `json:"grace,omitempty"`
+	Paused        bool   `json:"paused"`
+	State         string `json:"state"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the live health of every cron task pinging a heartbeat",
+	Long: `List each cron task of the crontab that pings a heartbeat, with its
+heartbeat name, group, period, grace, paused state and current up/down
+state as reported by the BetterUptime API.`,
+	Example: `  beatify status --token-command "pass show betterstack" -u www-data
+  beatify status -o json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireToken()
+		handleStatus(mustResolveCrontabUser())
+	},
+}
+
+// Function to report the heartbeat state of every managed cron task of a user
+func handleStatus(crontabUser string) {
+	cronTasks, err := crontab.ListManagedCronTasks(crontabUser)
+	if err != nil {
+		finish(ExitCrontab, fmt.Errorf("Error reading crontab: %w", err))
+	}
+
+	heartbeats, err := heartbeat.ListHeartbeats(authToken)
+	if err != nil {
+		finish(ExitAPI, fmt.Errorf("Error listing heartbeats: %w", err))
+	}
+
+	heartbeatGroups, err := heartbeat.ListHeartbeatGroups(authToken)
+	if err != nil {
+		finish(ExitAPI, fmt.Errorf("Error listing heartbeat groups: %w", err))
+	}
+
+	statuses := buildJobStatuses(cronTasks, heartbeats, heartbeatGroups)
+
+	if outputFormat == "json" {
+		printJSON(statuses)
+		return
+	}
+	printStatusTable(statuses)
+}
+
+// helper function to match managed cron tasks with their heartbeats by ping URL
+func buildJobStatuses(cronTasks []crontab.CronTask, heartbeats []heartbeat.Heartbeat, heartbeatGroups []heartbeat.HeartbeatGroup) []JobStatus {
+	heartbeatsByURL := make(map[string]heartbeat.Heartbeat, len(heartbeats))
+	for _, hb := range heartbeats {
+		heartbeatsByURL[hb.Attributes.URL] = hb
+	}
+
+	groupNames := make(map[string]string, len(heartbeatGroups))
+	for _, group := range heartbeatGroups {
+		groupNames[group.ID] = group.Attributes.Name
+	}
+
+	statuses := []JobStatus{}
+	for _, cronTask := range cronTasks {
+		status := JobStatus{
+			Spec:         cronTask.Spec,
+			Task:         cronTask.Task,
+			HeartbeatURL: cronTask.HeartbeatURL,
+			State:        "missing",
+		}
+
+		if hb, ok := heartbeatsByURL[cronTask.HeartbeatURL]; ok {
+			status.HeartbeatID = hb.ID
+			status.HeartbeatName = hb.Attributes.Name
+			status.Period = hb.Attributes.Period
+			status.Grace = hb.Attributes.Grace
+			status.Paused = hb.Attributes.PausedAt != "" || hb.Attributes.Status == "paused"
+			status.State = hb.Attributes.Status
+			if hb.Attributes.HeartbeatGroupID != 0 {
+				status.Group = groupNames[strconv.Itoa(hb.Attributes.HeartbeatGroupID)]
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// helper function to print job statuses as an aligned table
+func printStatusTable(statuses []JobStatus) {
+	if len(statuses) == 0 {
+		fmt.Println("No cron tasks with heartbeats found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tHEARTBEAT\tGROUP\tPERIOD\tGRACE\tPAUSED\tSCHEDULE\tTASK")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%ds\t%ds\t%t\t%s\t%s\n",
+			status.State,
+			valueOrDash(status.HeartbeatName),
+			valueOrDash(status.Group),
+			status.Period,
+			status.Grace,
+			status.Paused,
+			status.Spec,
+			status.Task,
+		)
+	}
+	w.Flush()
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
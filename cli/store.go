@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/crontab"
+)
+
+// Function to configure where crontabs are read from and installed to
+func configureStore() error {
+	switch crontabStore {
+	case "auto", "debian", "rhel":
+		dir := spoolDir
+		if dir == "" {
+			switch crontabStore {
+			case "debian":
+				dir = crontab.DebianSpoolDir
+			case "rhel":
+				dir = crontab.RHELSpoolDir
+			default:
+				dir = crontab.DetectSpoolDir()
+			}
+		}
+		if dir == "" {
+			// No spool directory on this host, rely on the crontab binary
+			crontab.Store = crontab.NewBinaryStore(crontabBinary)
+			break
+		}
+		store := crontab.NewSpoolStore(dir)
+		store.Binary.Path = crontabBinary
+		crontab.Store = store
+	case "binary":
+		crontab.Store = crontab.NewBinaryStore(crontabBinary)
+	case "file":
+		// The crontab may belong to an account of another host
+		crontab.RequireSystemUser = false
+		if crontabFile == "" {
+			return fmt.Errorf("the file store needs --crontab-file")
+		}
+		crontab.Store = crontab.NewFileStore(crontabFile)
+	case "stream":
+		// The prompts read from the terminal, commands without prompts
+		// run without one
+		crontab.RequireSystemUser = false
+		crontab.Store = useStreamStore()
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			crontab.PromptInput = errReader{fmt.Errorf("the stream store needs a terminal for the prompts: %w", err)}
+			break
+		}
+		crontab.PromptInput = tty
+	default:
+		return fmt.Errorf("unknown crontab store '%s': expected auto, debian, rhel, binary, file or stream", crontabStore)
+	}
+
+	return nil
+}
+
+// Stream store of the run, once --store stream is set up
+var streamStore *crontab.StreamStore
+
+// helper function to set up the stream store: stdout carries the resulting
+// crontab, so every message goes to stderr and the token command gets no
+// input, which is the crontab
+func useStreamStore() *crontab.StreamStore {
+	if streamStore == nil {
+		streamStore = crontab.NewStreamStore(os.Stdin, os.Stdout)
+		os.Stdout = os.Stderr
+		config.TokenCommandInput = nil
+	}
+	return streamStore
+}
+
+// helper function to write the crontab read by the stream store unchanged
+// when the run installed none
+func closeStreamStore() {
+	if streamStore == nil {
+		return
+	}
+	if err := streamStore.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	streamStore = nil
+}
+
+// errReader fails every read with its error
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
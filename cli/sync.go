@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/spf13/cobra"
+)
+
+// States of a managed cron task compared with its heartbeat
+const (
+	SyncInSync  = "in-sync"
+	SyncDrift   = "drift"
+	SyncMissing = "missing"
+	SyncInvalid = "invalid"
+	SyncUpdated = "updated"
+)
+
+var syncApply bool
+
+// SyncReport compares a managed cron task with its heartbeat
+type SyncReport struct {
+	Spec           string `json:"spec"`
+	Task           string `json:"task"`
+	HeartbeatURL   string `json:"heartbeat_url"`
+	HeartbeatID    string `json:"heartbeat_id,omitempty"`
+	State          string `json:"state"`
+	Period         int    `json:"period"`
+	Grace          int    `json:"grace"`
+	ExpectedPeriod int    `json:"expected_period"`
+	ExpectedGrace  int    `json:"expected_grace"`
+	Error          string `json:"error,omitempty"`
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Compare the heartbeats of the crontab with the schedules of their tasks",
+	Long: `Compare each cron task of the crontab that pings a heartbeat with its
+heartbeat: "in-sync" when the heartbeat period and grace match the ones
+computed from the task schedule, "drift" when they differ, for instance
+after the schedule was edited, and "missing" when the heartbeat no longer
+exists. The period is the longest time between two runs of the schedule,
+e.g. the weekend for a task running on weekdays. The grace chosen for a task
+when its heartbeat was created, in the --tui list, is kept and not compared.
+
+With --apply, the period and grace of the drifted heartbeats are updated to
+the computed ones, and their state becomes "updated".`,
+	Example: `  beatify sync -u www-data
+  beatify sync -u www-data --apply`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireToken()
+
+		reports := compareHeartbeats(mustResolveCrontabUser())
+		failed := 0
+		if syncApply {
+			failed = updateDriftedHeartbeats(reports)
+		}
+
+		if outputFormat == "json" {
+			printJSON(reports)
+		} else {
+			printSyncTable(reports)
+		}
+
+		if failed > 0 {
+			finish(ExitPartial, fmt.Errorf("%d heartbeats could not be updated", failed))
+		}
+	},
+}
+
+// Function to compare the managed cron tasks of a user with their heartbeats
+func compareHeartbeats(crontabUser string) []*SyncReport {
+	cronTasks, err := crontab.ListManagedCronTasks(crontabUser)
+	if err != nil {
+		finish(ExitCrontab, fmt.Errorf("Error reading crontab: %w", err))
+	}
+
+	heartbeats, err := heartbeat.ListHeartbeats(authToken)
+	if err != nil {
+		finish(ExitAPI, fmt.Errorf("Error listing heartbeats: %w", err))
+	}
+	graces, err := heartbeat.RecordedGraces()
+	if err != nil {
+		finish(ExitError, fmt.Errorf("Error reading recorded graces: %w", err))
+	}
+	return compareTaskHeartbeats(cronTasks, heartbeats, graces)
+}
+
+// Function to compare managed cron tasks with the heartbeats of the account.
+// The grace of a heartbeat is only compared when no grace was recorded for it
+func compareTaskHeartbeats(cronTasks []crontab.CronTask, heartbeats []heartbeat.Heartbeat, graces map[string]int) []*SyncReport {
+	heartbeatsByURL := make(map[string]heartbeat.Heartbeat, len(heartbeats))
+	for _, hb := range heartbeats {
+		heartbeatsByURL[hb.Attributes.URL] = hb
+	}
+
+	reports := []*SyncReport{}
+	for _, cronTask := range cronTasks {
+		report := &SyncReport{
+			Spec:         cronTask.Spec,
+			Task:         cronTask.Task,
+			HeartbeatURL: cronTask.HeartbeatURL,
+		}
+		reports = append(reports, report)
+
+		period, grace, err := heartbeat.SchedulePeriod(cronTask.Spec)
+		if err != nil {
+			report.State, report.Error = SyncInvalid, err.Error()
+			continue
+		}
+		report.ExpectedPeriod, report.ExpectedGrace = period, grace
+
+		hb, ok := heartbeatsByURL[cronTask.HeartbeatURL]
+		if !ok {
+			report.State = SyncMissing
+			continue
+		}
+		report.HeartbeatID = hb.ID
+		report.Period, report.Grace = hb.Attributes.Period, hb.Attributes.Grace
+		if _, ok := graces[cronTask.HeartbeatURL]; ok {
+			// The grace chosen for the task is kept
+			grace = report.Grace
+			report.ExpectedGrace = grace
+		}
+
+		report.State = SyncInSync
+		if report.Period != period || report.Grace != grace {
+			report.State = SyncDrift
+		}
+	}
+
+	return reports
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncApply, "apply", false, "Update the period and grace of the drifted heartbeats")
+}
+
+// Function to update the drifted heartbeats to the period and grace of their
+// schedule, returning the number of failed updates
+func updateDriftedHeartbeats(reports []*SyncReport) int {
+	failed := 0
+	for _, report := range reports {
+		if report.State != SyncDrift {
+			continue
+		}
+
+		period, grace := report.ExpectedPeriod, report.ExpectedGrace
+		updated, err := heartbeat.UpdateHeartbeat(authToken, report.HeartbeatID, heartbeat.HeartbeatRequest{
+			Period: &period,
+			Grace:  &grace,
+		})
+		if err != nil {
+			report.Error = err.Error()
+			failed++
+			continue
+		}
+		report.State = SyncUpdated
+		report.Period, report.Grace = updated.Attributes.Period, updated.Attributes.Grace
+	}
+	return failed
+}
+
+// helper function to print sync reports as an aligned table
+func printSyncTable(reports []*SyncReport) {
+	if len(reports) == 0 {
+		fmt.Println("No cron tasks with heartbeats found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tPERIOD\tEXPECTED\tGRACE\tEXPECTED\tSCHEDULE\tTASK")
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s\t%ds\t%ds\t%ds\t%ds\t%s\t%s\n",
+			report.State,
+			report.Period,
+			report.ExpectedPeriod,
+			report.Grace,
+			report.ExpectedGrace,
+			report.Spec,
+			report.Task,
+		)
+	}
+	w.Flush()
+}
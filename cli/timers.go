@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/systemd"
+	"github.com/IT-JONCTION/beatify/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	timerUnitDirs     []string
+	timerApply        bool
+	timerRemove       bool
+	timerDaemonReload bool
+)
+
+// TimerReport describes a systemd timer and the heartbeat it would get
+type TimerReport struct {
+	systemd.Timer
+	Schedule string `json:"schedule"`
+	Spec     string `json:"cron_spec,omitempty"`
+	Period   int    `json:"period"`
+	Grace    int    `json:"grace"`
+	Error    string `json:"error,omitempty"`
+}
+
+var timersCmd = &cobra.Command{
+	Use:   "timers",
+	Short: "List systemd timers and make their services ping heartbeats",
+	Long: `List the .timer units of the unit directories with the period and grace of
+the heartbeat each would get, and the heartbeat it already pings.
+
+The period comes from OnCalendar=, translated into a cron schedule, or from
+OnUnitActiveSec= and OnUnitInactiveSec=. It is the longest time between two
+runs of the OnCalendar= lines, and the timezone ending an OnCalendar=
+expression is sent with the schedule to a receiver that evaluates it, such
+as beatify serve, as a CRON_TZ= prefix. The grace follows the configured
+defaults, plus AccuracySec= (one minute by default) and RandomizedDelaySec=.
+
+With --apply, each timer without a heartbeat is presented for approval, a
+heartbeat is created for each approved timer and a drop-in of its service
+is written to --drop-in-dir instead of a crontab. The drop-in adds an
+ExecStopPost= command, run once each run is over whatever the type of the
+service: it pings the heartbeat after a successful run, its exit status URL
+after a run that exited with an error, and its /fail URL when the run was
+killed or timed out. The units are then reloaded with systemctl
+daemon-reload.
+
+With --remove, each timer with a heartbeat is presented for approval and
+the drop-ins beatify wrote for the approved ones are removed; their heartbeats
+are kept unless --delete-heartbeat is given.`,
+	Example: `  beatify timers
+  beatify timers --apply -g systemd-timers
+  beatify timers --unit-dir ./units --drop-in-dir ./units --reload=false --apply`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if timerApply && timerRemove {
+			finish(ExitUsage, fmt.Errorf("--apply and --remove cannot be combined"))
+		}
+		systemd.UnitDirs = timerUnitDirs
+
+		timers, err := systemd.DiscoverTimers()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error listing timers: %w", err))
+		}
+		reports := make([]*TimerReport, 0, len(timers))
+		for _, timer := range timers {
+			reports = append(reports, timerReport(timer))
+		}
+
+		switch {
+		case timerApply:
+			applyTimers(reports)
+		case timerRemove:
+			removeTimers(reports)
+		case outputFormat == "json":
+			printJSON(reports)
+		default:
+			printTimersTable(reports)
+		}
+	},
+}
+
+func init() {
+	timersCmd.Flags().StringSliceVar(&timerUnitDirs, "unit-dir", systemd.UnitDirs, "Directories holding the unit files, the first one holding a unit wins")
+	timersCmd.Flags().StringVar(&systemd.DropInDir, "drop-in-dir", systemd.DropInDir, "Directory the drop-ins are written to")
+	timersCmd.Flags().StringVar(&systemd.Systemctl, "systemctl", systemd.Systemctl, "Path of the systemctl binary")
+	timersCmd.Flags().StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group to add the heartbeats to, created if missing")
+	timersCmd.Flags().BoolVar(&timerApply, "apply", false, "Create heartbeats for the approved timers and write their drop-ins")
+	timersCmd.Flags().BoolVar(&timerRemove, "remove", false, "Remove the drop-ins of the approved timers")
+	timersCmd.Flags().BoolVar(&deleteHeartbeats, "delete-heartbeat", false, "With --remove, also delete the heartbeats of the approved timers")
+	timersCmd.Flags().BoolVar(&timerDaemonReload, "reload", true, "Run systemctl daemon-reload once the drop-ins changed")
+}
+
+// helper function to compute the heartbeat settings of a timer
+func timerReport(timer systemd.Timer) *TimerReport {
+	report := &TimerReport{Timer: timer, Schedule: timer.Schedule()}
+	if timer.ReadError != "" {
+		report.Error = timer.ReadError
+		return report
+	}
+	period, grace, spec, err := timer.Period()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Period, report.Grace, report.Spec = period, grace, spec
+	return report
+}
+
+// Function to create a heartbeat and write the drop-ins of each approved
+// timer without one
+func applyTimers(reports []*TimerReport) {
+	requireToken()
+
+	var heartbeatGroupID string
+	if heartbeatGroupName != "" {
+		var err error
+		if heartbeatGroupID, err = heartbeatGroupIDFor(heartbeatGroupName); err != nil {
+			finish(ExitAPI, err)
+		}
+	}
+
+	created, failed := 0, 0
+	for _, report := range reports {
+		if report.HeartbeatURL != "" {
+			fmt.Printf("Skipping timer already pinging a heartbeat: %s\n", report.Name)
+			continue
+		}
+		if report.Error != "" {
+			fmt.Printf("Skipping timer %s: %s\n", report.Name, report.Error)
+			continue
+		}
+
+		fmt.Printf("Timer: %s (%s), runs %s as %s\n", report.Name, report.Service, report.Schedule, report.User)
+		fmt.Printf("  Heartbeat: period %s (%ds), grace %s (%ds)\n",
+			tui.FormatSeconds(report.Period), report.Period,
+			tui.FormatSeconds(report.Grace), report.Grace)
+		approved, skipRest, err := crontab.PromptApproval()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading approval: %w", err))
+		}
+		if skipRest {
+			break
+		}
+		if !approved {
+			continue
+		}
+		name, err := crontab.PromptHeartbeatName(crontab.DefaultHeartbeatName(report.User, report.Service))
+		if err != nil {
+			finish(ExitError, err)
+		}
+
+		data, err := heartbeat.PreparePeriodJson(name, heartbeatGroupID, report.Period, report.Grace, report.Spec)
+		if err != nil {
+			fmt.Println("Error preparing config JSON:", err)
+			failed++
+			continue
+		}
+		hb, err := heartbeat.CreateHeartbeat(authToken, data)
+		if err != nil {
+			fmt.Println("Error creating heartbeat:", err)
+			failed++
+			continue
+		}
+		fmt.Println("Heartbeat created successfully:", hb.Attributes.URL)
+
+		if err := systemd.Instrument(report.Timer, hb.Attributes.URL); err != nil {
+			fmt.Println("Error writing drop-in:", err)
+			deleteUnusedHeartbeat(hb)
+			failed++
+			continue
+		}
+		fmt.Println("Drop-in written for", report.Service)
+		created++
+	}
+
+	reloadUnits(created)
+	if failed > 0 {
+		finish(ExitPartial, fmt.Errorf("%d timers could not be instrumented", failed))
+	}
+}
+
+// Function to remove the drop-ins of each approved timer with a heartbeat
+func removeTimers(reports []*TimerReport) {
+	if deleteHeartbeats {
+		requireToken()
+	}
+
+	var removed []crontab.CronTask
+	for _, report := range reports {
+		if report.HeartbeatURL == "" {
+			continue
+		}
+
+		fmt.Printf("Monitored timer: %s (%s), pings %s\n", report.Name, report.Service, report.HeartbeatURL)
+		approved, skipRest, err := crontab.PromptApproval()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error reading approval: %w", err))
+		}
+		if skipRest {
+			break
+		}
+		if !approved {
+			continue
+		}
+
+		if err := systemd.Uninstrument(report.Timer); err != nil {
+			finish(ExitError, fmt.Errorf("Error removing drop-in: %w", err))
+		}
+		fmt.Println("Drop-in removed for", report.Service)
+		removed = append(removed, crontab.CronTask{HeartbeatURL: report.HeartbeatURL})
+	}
+
+	reloadUnits(len(removed))
+	if deleteHeartbeats && len(removed) > 0 {
+		deleteTaskHeartbeats(removed)
+	}
+}
+
+// helper function to reload the units once drop-ins changed
+func reloadUnits(changed int) {
+	if changed == 0 || !timerDaemonReload {
+		return
+	}
+	if err := systemd.DaemonReload(); err != nil {
+		finish(ExitInstall, err)
+	}
+	fmt.Println("Units reloaded.")
+}
+
+// helper function to print timers as an aligned table
+func printTimersTable(reports []*TimerReport) {
+	if len(reports) == 0 {
+		fmt.Println("No timers found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIMER\tSERVICE\tUSER\tSCHEDULE\tPERIOD\tGRACE\tHEARTBEAT")
+	for _, report := range reports {
+		period, grace := tui.FormatSeconds(report.Period), tui.FormatSeconds(report.Grace)
+		if report.Error != "" {
+			period, grace = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			report.Name,
+			report.Service,
+			report.User,
+			report.Schedule,
+			period,
+			grace,
+			valueOrDash(report.HeartbeatURL),
+		)
+	}
+	w.Flush()
+}
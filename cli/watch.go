@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+)
+
+var (
+	watchRulesFile  string
+	watchReportOnly bool
+	watchSettle     time.Duration
+	watchPasses     int
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch the crontabs and monitor new cron tasks by rules",
+	Long: `Watch the cron spool directory and /etc/cron.d with inotify and, after
+each change, read every crontab of the host again and apply the rules file
+to its cron tasks:
+
+  - a new task matching a monitor rule gets a heartbeat and its ping,
+    as apply would do after approving it
+  - the heartbeat of a monitored task whose schedule changed gets the
+    period and grace of the new schedule, as sync --apply would do
+  - the heartbeat of a monitored task removed from its crontab while
+    watch runs is deleted
+
+Every action is logged on stdout. With --report-only, the actions are
+logged without being taken. A first pass runs at start, and /etc/crontab
+is read on every pass, though edits to it alone do not start one. With
+--passes, watch exits once that many passes ran.
+
+The first rule matching the user and the command of a task applies, the
+default action otherwise. In patterns, * stands for any text and ? for one
+character:
+
+    default: ignore              # or monitor
+    rules:
+      - match: "*/backup*"
+        action: monitor
+        heartbeat_group: backups  # the profile group when empty
+        grace: 10%                # the profile grace when empty
+      - user: www-data
+        action: monitor
+      - match: "*logrotate*"
+        action: ignore
+
+Watching needs a spool store and, for /etc/cron.d, root. --system-crontab
+and --system-crontab-dir read the system crontabs from other paths.`,
+	Example: `  beatify watch --rules /etc/beatify/rules.yaml
+  beatify watch --report-only`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		spool, ok := crontab.Store.(*crontab.SpoolStore)
+		if !ok {
+			finish(ExitUsage, fmt.Errorf("watch needs a spool store"))
+		}
+		rules, err := config.LoadRules(watchRulesFile)
+		if err != nil {
+			finish(ExitError, err)
+		}
+		requireToken()
+
+		notifier, err := fsnotify.NewWatcher()
+		if err != nil {
+			finish(ExitError, fmt.Errorf("Error starting the crontab watcher: %w", err))
+		}
+		defer notifier.Close()
+		for _, dir := range []string{spool.Dir, crontab.SystemCrontabDir} {
+			if err := notifier.Add(dir); err != nil {
+				if dir == spool.Dir {
+					finish(ExitCrontab, fmt.Errorf("Error watching %s: %w", dir, err))
+				}
+				logWatch("Not watching %s: %v", dir, err)
+				continue
+			}
+			logWatch("Watching %s", dir)
+		}
+
+		watcher := &crontabWatcher{
+			Rules:   rules,
+			Spool:   crontab.CrontabTarget{Store: spool},
+			Limiter: rate.NewLimiter(3, 1),
+		}
+		watcher.Pass()
+		passes := 1
+		if watchPasses > 0 && passes >= watchPasses {
+			return
+		}
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		// Changes come in bursts, a pass runs once they settle
+		settle := time.NewTimer(watchSettle)
+		settle.Stop()
+		for {
+			select {
+			case event := <-notifier.Events:
+				if ignoredWatchEvent(event) {
+					continue
+				}
+				settle.Reset(watchSettle)
+			case err := <-notifier.Errors:
+				logWatch("Error watching crontabs: %v", err)
+			case <-settle.C:
+				watcher.Pass()
+				if passes++; watchPasses > 0 && passes >= watchPasses {
+					return
+				}
+			case <-signals:
+				finish(ExitOK, nil)
+			}
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchRulesFile, "rules", config.RulesFile, "Rules file deciding which cron tasks are monitored")
+	watchCmd.Flags().BoolVar(&watchReportOnly, "report-only", false, "Log the actions the rules call for without taking them")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 2*time.Second, "Time without change to wait for before reading the crontabs")
+	addSystemCrontabFlags(watchCmd.Flags())
+	watchCmd.Flags().IntVar(&watchPasses, "passes", 0, "Exit after this number of passes, 0 watches until interrupted")
+	watchCmd.Flags().StringVarP(&heartbeatGroupName, "heartbeat-group", "g", "", "Heartbeat group of the heartbeats created, for the rules setting none")
+}
+
+// crontabWatcher applies the rules to the crontabs of the host on each pass
+type crontabWatcher struct {
+	Rules   config.Rules
+	Spool   crontab.CrontabTarget
+	Limiter *rate.Limiter
+
+	// Monitored tasks found by the last pass, by heartbeat URL
+	known map[string]watchedTask
+}
+
+// Monitored cron task and the crontab it was found in
+type watchedTask struct {
+	Crontab string
+	Task    crontab.CronTask
+	Rule    config.Rule
+}
+
+// Function to read every crontab and take the actions the rules call for
+func (w *crontabWatcher) Pass() {
+	crontab.UseTarget(w.Spool)
+	targets, err := crontab.DiscoverCrontabs()
+	if err != nil {
+		logWatch("Error listing crontabs: %v", err)
+		return
+	}
+	heartbeats, err := heartbeat.ListHeartbeats(authToken)
+	if err != nil {
+		logWatch("Error listing heartbeats: %v", err)
+		return
+	}
+
+	seen := map[string]watchedTask{}
+	unread := map[string]bool{}
+	for _, target := range targets {
+		crontab.UseTarget(target)
+		tasks, err := crontab.ScanCrontab(target.Name)
+		if err != nil {
+			logWatch("Error reading crontab %s: %v", target.Name, err)
+			unread[target.Name] = true
+			continue
+		}
+
+		if w.create(target, tasks) && !watchReportOnly {
+			// Read the crontab and the heartbeats again to know the ones just created
+			if tasks, err = crontab.ScanCrontab(target.Name); err != nil {
+				logWatch("Error reading crontab %s: %v", target.Name, err)
+				unread[target.Name] = true
+				continue
+			}
+			if heartbeats, err = heartbeat.ListHeartbeats(authToken); err != nil {
+				logWatch("Error listing heartbeats: %v", err)
+				unread[target.Name] = true
+				continue
+			}
+		}
+
+		var monitored []watchedTask
+		for _, task := range tasks {
+			if task.State != crontab.TaskManaged {
+				continue
+			}
+			watched := watchedTask{Crontab: target.Name, Task: task.CronTask, Rule: w.rule(target, task.CronTask)}
+			seen[task.HeartbeatURL] = watched
+			if watched.Rule.Action == config.ActionMonitor {
+				monitored = append(monitored, watched)
+			}
+		}
+		w.update(target, monitored, heartbeats)
+	}
+
+	// A crontab that could not be read keeps its tasks until the next pass
+	for url, watched := range w.known {
+		if _, ok := seen[url]; !ok && unread[watched.Crontab] {
+			seen[url] = watched
+		}
+	}
+	w.remove(seen, heartbeats)
+	w.known = seen
+}
+
+// helper function to find the rule of a cron task, whose user is the
+// crontab user or, in system crontabs, its user field
+func (w *crontabWatcher) rule(target crontab.CrontabTarget, cronTask crontab.CronTask) config.Rule {
+	taskUser, command := target.Name, cronTask.Task
+	if target.System {
+		if fields := strings.Fields(cronTask.Task); len(fields) > 1 {
+			taskUser, command = fields[0], strings.Join(fields[1:], " ")
+		}
+	}
+	return w.Rules.Find(taskUser, command)
+}
+
+// helper function to create the heartbeats of the new tasks the rules
+// monitor and make them ping, returning whether there were any
+func (w *crontabWatcher) create(target crontab.CrontabTarget, tasks []crontab.ScannedTask) bool {
+	selected := w.selectTasks(target, tasks, true)
+	if len(selected) == 0 {
+		return false
+	}
+
+	if watchReportOnly {
+		for _, cronTask := range selected {
+			logWatch("Would create heartbeat '%s' in %s for: %s %s", cronTask.Name, target.Name, cronTask.Spec, cronTask.Task)
+		}
+		return true
+	}
+
+	var groupID string
+	if heartbeatGroupName != "" {
+		var err error
+		if groupID, err = heartbeatGroupIDFor(heartbeatGroupName); err != nil {
+			logWatch("%v", err)
+			return true
+		}
+	}
+
+	review := func(crontabUser string) ([]crontab.CronTask, error) {
+		// Select again in the crontab as it is once locked
+		return crontab.ReviewCronTasks(crontabUser, func(scanned []crontab.ScannedTask) ([]crontab.CronTask, error) {
+			return w.selectTasks(target, scanned, false), nil
+		})
+	}
+	summary, jobs := applyCrontab(target.Name, groupID, w.Limiter, review)
+
+	for _, job := range jobs {
+		switch job.Status {
+		case JobCreated:
+			logWatch("Created heartbeat '%s' in %s for: %s %s", job.Name, target.Name, job.Spec, job.Task)
+		case JobFailed:
+			logWatch("Error creating heartbeat '%s' in %s for: %s %s: %s", job.Name, target.Name, job.Spec, job.Task, job.Error)
+		}
+	}
+	if summary.Err != nil {
+		logWatch("Error updating crontab %s: %v", target.Name, summary.Err)
+	} else if summary.Created > 0 {
+		logWatch("Installed crontab %s with %d new pings", target.Name, summary.Created)
+	}
+	return true
+}
+
+// helper function to pick the unmonitored tasks the rules monitor, with the
+// heartbeat settings of their rule, logging the tasks left out when verbose
+func (w *crontabWatcher) selectTasks(target crontab.CrontabTarget, tasks []crontab.ScannedTask, verbose bool) []crontab.CronTask {
+	skip := func(task crontab.ScannedTask, err error) {
+		if verbose {
+			logWatch("Cannot monitor task in %s: %s: %v", target.Name, task.Line, err)
+		}
+	}
+
+	var selected []crontab.CronTask
+	for _, task := range tasks {
+		if task.State != crontab.TaskUnmanaged {
+			continue
+		}
+		rule := w.rule(target, task.CronTask)
+		if rule.Action != config.ActionMonitor {
+			continue
+		}
+
+		period, _, err := heartbeat.SchedulePeriod(task.Spec)
+		if err != nil {
+			skip(task, err)
+			continue
+		}
+		if err := crontab.CheckInstrumentable(task.Line); err != nil {
+			skip(task, err)
+			continue
+		}
+
+		cronTask := task.CronTask
+		cronTask.Name = crontab.DefaultTaskHeartbeatName(target.Name, cronTask)
+		cronTask.Group = rule.HeartbeatGroup
+		if rule.Grace != "" {
+			// The rules file was checked when loaded
+			cronTask.Grace, _ = parseItemGrace(rule.Grace, period)
+		}
+		selected = append(selected, cronTask)
+	}
+	return selected
+}
+
+// helper function to bring the heartbeats of monitored tasks in line with
+// their schedule and rule
+func (w *crontabWatcher) update(target crontab.CrontabTarget, monitored []watchedTask, heartbeats []heartbeat.Heartbeat) {
+	cronTasks := make([]crontab.CronTask, 0, len(monitored))
+	for _, watched := range monitored {
+		cronTasks = append(cronTasks, watched.Task)
+	}
+
+	graces, err := heartbeat.RecordedGraces()
+	if err != nil {
+		logWatch("Error reading recorded graces: %v", err)
+	}
+	reports := compareTaskHeartbeats(cronTasks, heartbeats, graces)
+	for i, report := range reports {
+		rule := monitored[i].Rule
+		if rule.Grace != "" && (report.State == SyncInSync || report.State == SyncDrift) {
+			report.ExpectedGrace, _ = parseItemGrace(rule.Grace, report.ExpectedPeriod)
+			report.State = SyncInSync
+			if report.Period != report.ExpectedPeriod || report.Grace != report.ExpectedGrace {
+				report.State = SyncDrift
+			}
+		}
+
+		switch report.State {
+		case SyncMissing:
+			logWatch("Heartbeat of task in %s no longer exists: %s %s", target.Name, report.Spec, report.Task)
+		case SyncDrift:
+			if watchReportOnly {
+				logWatch("Would update heartbeat %s in %s to period %ds and grace %ds, from %ds and %ds: %s %s", report.HeartbeatID, target.Name,
+					report.ExpectedPeriod, report.ExpectedGrace, report.Period, report.Grace, report.Spec, report.Task)
+			}
+		}
+	}
+	if watchReportOnly {
+		return
+	}
+
+	updateDriftedHeartbeats(reports)
+	for _, report := range reports {
+		switch {
+		case report.State == SyncUpdated:
+			logWatch("Updated heartbeat %s in %s to period %ds and grace %ds: %s %s", report.HeartbeatID, target.Name,
+				report.Period, report.Grace, report.Spec, report.Task)
+		case report.State == SyncDrift && report.Error != "":
+			logWatch("Error updating heartbeat %s in %s: %s", report.HeartbeatID, target.Name, report.Error)
+		}
+	}
+}
+
+// helper function to delete the heartbeats of the monitored tasks gone
+// since the last pass
+func (w *crontabWatcher) remove(seen map[string]watchedTask, heartbeats []heartbeat.Heartbeat) {
+	heartbeatIDs := make(map[string]string, len(heartbeats))
+	for _, hb := range heartbeats {
+		heartbeatIDs[hb.Attributes.URL] = hb.ID
+	}
+
+	for url, watched := range w.known {
+		if _, ok := seen[url]; ok || watched.Rule.Action != config.ActionMonitor {
+			continue
+		}
+		id, ok := heartbeatIDs[url]
+		if !ok {
+			continue
+		}
+		if watchReportOnly {
+			logWatch("Would delete heartbeat %s of task removed from %s: %s %s", id, watched.Crontab, watched.Task.Spec, watched.Task.Task)
+			continue
+		}
+		if err := heartbeat.DeleteHeartbeat(authToken, id); err != nil {
+			logWatch("Error deleting heartbeat %s: %v", id, err)
+			// Try again on the next pass
+			seen[url] = watched
+			continue
+		}
+		logWatch("Deleted heartbeat %s of task removed from %s: %s %s", id, watched.Crontab, watched.Task.Spec, watched.Task.Task)
+	}
+}
+
+// helper function to leave out the events of files cron ignores, such as
+// the temp files of crontab -e
+func ignoredWatchEvent(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	return event.Op == fsnotify.Chmod || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "tmp.") || strings.HasSuffix(name, "~")
+}
+
+// helper function to log an action of watch with its time
+func logWatch(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
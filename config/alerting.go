@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// Types of notifier understood by beatify serve
+var NotifierTypes = []string{"smtp", "webhook", "slack", "script"}
+
+// Alerting holds where the receiver run by beatify serve sends its alerts
+type Alerting struct {
+	Notifiers map[string]NotifierConfig `yaml:"notifiers"`
+	Routes    []Route                   `yaml:"routes"`
+	// Interval between reminders of an incident that is still open, none when empty
+	Reminder string `yaml:"reminder"`
+	// Window in which an alert repeating an event of a heartbeat is dropped
+	Dedup string `yaml:"dedup"`
+}
+
+// NotifierConfig describes one destination of the alerts
+type NotifierConfig struct {
+	Type string `yaml:"type"`
+
+	// Settings of the smtp type, the password is a token reference
+	Server   string   `yaml:"server"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+
+	// URL of the webhook and slack types
+	URL string `yaml:"url"`
+
+	// Command of the script type, run with sh
+	Command string `yaml:"command"`
+}
+
+// Route sends the alerts of the heartbeats whose name matches a pattern, as
+// in the rules of watch, to notifiers, all events when Events is empty
+type Route struct {
+	Match     string   `yaml:"match"`
+	Events    []string `yaml:"events"`
+	Notifiers []string `yaml:"notifiers"`
+}
+
+// Function to check the alerting settings and parse their durations
+func (a Alerting) Validate() (reminder time.Duration, dedup time.Duration, err error) {
+	for name, notifier := range a.Notifiers {
+		if !contains(NotifierTypes, notifier.Type) {
+			return 0, 0, fmt.Errorf("notifier '%s' has unknown type '%s'", name, notifier.Type)
+		}
+	}
+	for i, route := range a.Routes {
+		if _, err := path.Match(route.Match, ""); err != nil {
+			return 0, 0, fmt.Errorf("route %d has an invalid pattern '%s': %w", i+1, route.Match, err)
+		}
+		for _, name := range route.Notifiers {
+			if _, ok := a.Notifiers[name]; !ok {
+				return 0, 0, fmt.Errorf("route %d names unknown notifier '%s'", i+1, name)
+			}
+		}
+	}
+
+	if a.Reminder != "" {
+		if reminder, err = time.ParseDuration(a.Reminder); err != nil {
+			return 0, 0, fmt.Errorf("invalid reminder interval '%s': %w", a.Reminder, err)
+		}
+	}
+	if a.Dedup != "" {
+		if dedup, err = time.ParseDuration(a.Dedup); err != nil {
+			return 0, 0, fmt.Errorf("invalid dedup window '%s': %w", a.Dedup, err)
+		}
+	}
+	return reminder, dedup, nil
+}
+
+// helper function to override the alerting settings of base set in override
+func mergeAlerting(base, override Alerting) Alerting {
+	for name, notifier := range override.Notifiers {
+		if base.Notifiers == nil {
+			base.Notifiers = map[string]NotifierConfig{}
+		}
+		base.Notifiers[name] = notifier
+	}
+	if override.Routes != nil {
+		base.Routes = override.Routes
+	}
+	if override.Reminder != "" {
+		base.Reminder = override.Reminder
+	}
+	if override.Dedup != "" {
+		base.Dedup = override.Dedup
+	}
+	return base
+}
+
+// helper function to tell a list holds a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,18 +2,69 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"strings"
+
+	"golang.org/x/term"
 )
 
-func PromptAuthToken() string {
-	var authToken string
+// Environment variable holding the authentication token
+const TokenEnvVar = "BEATIFY_TOKEN"
 
-	fmt.Print("Enter the authentication token for the BetterUptime API: ")
-	fmt.Scanln(&authToken)
-	fmt.Println()
+// TokenSources lists where the authentication token may come from, in
+// order of precedence
+type TokenSources struct {
+	Flag      string
+	File      string
+	Command   string
+	Reference string
+}
 
-	// Trim any leading or trailing whitespace from the input
-	authToken = strings.TrimSpace(authToken)
+// Function to resolve the authentication token from the first source that is
+// set, falling back to an interactive prompt that does not echo
+func ResolveToken(sources TokenSources) (string, error) {
+	var token string
+	var err error
+
+	switch {
+	case sources.Flag != "":
+		fmt.Fprintln(os.Stderr, "Warning: a token given with -a is visible in the process list and shell history, prefer "+TokenEnvVar+" or --token-file.")
+		token = sources.Flag
+	case sources.File != "":
+		token, err = ReadTokenFile(sources.File)
+	case sources.Command != "":
+		token, err = RunTokenCommand(sources.Command)
+	case os.Getenv(TokenEnvVar) != "":
+		token = os.Getenv(TokenEnvVar)
+	case sources.Reference != "":
+		token, err = ResolveTokenReference(sources.Reference)
+	default:
+		token, err = PromptAuthToken()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("the authentication token is empty")
+	}
+	return token, nil
+}
 
-	return authToken
-}
\ No newline at end of file
+func PromptAuthToken() (string, error) {
+	// Reading without echo needs a terminal
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no authentication token: set %s, --token-file or --token-command", TokenEnvVar)
+	}
+
+	fmt.Fprint(os.Stderr, "Enter the authentication token for the BetterUptime API: ")
+	authToken, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the authentication token: %w", err)
+	}
+
+	// Trim any leading or trailing whitespace from the input
+	return strings.TrimSpace(string(authToken)), nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration files, later files override earlier ones
+var (
+	SystemConfigFile = "/etc/beatify/config.yaml"
+	UserConfigFile   = filepath.Join(".config", "beatify", "config.yaml")
+)
+
+// Providers known to beatify, with the API base URL used when a profile
+// sets none: local is the receiver run by beatify serve
+var Providers = map[string]string{
+	"betterstack": "https://uptime.betterstack.com/api/v2",
+	"local":       "http://127.0.0.1:8470/api/v2",
+}
+
+// Config is the content of a beatify configuration file
+type Config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+	Alerting       Alerting           `yaml:"alerting"`
+}
+
+// Profile holds the settings of one environment
+type Profile struct {
+	Provider       string `yaml:"provider"`
+	APIBaseURL     string `yaml:"api_base_url"`
+	Token          string `yaml:"token"`
+	HeartbeatGroup string `yaml:"heartbeat_group"`
+	User           string `yaml:"user"`
+	Grace          string `yaml:"grace"`
+	Call           *bool  `yaml:"call"`
+	SMS            *bool  `yaml:"sms"`
+	Email          *bool  `yaml:"email"`
+	Push           *bool  `yaml:"push"`
+	TeamWait       *int   `yaml:"team_wait"`
+	NameTemplate   string `yaml:"name_template"`
+}
+
+// Function to load and merge the system and user configuration files, or
+// only the given file when path is set
+func LoadConfig(path string) (Config, error) {
+	paths := []string{path}
+	if path == "" {
+		paths = []string{SystemConfigFile}
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			paths = append(paths, filepath.Join(homeDir, UserConfigFile))
+		}
+	}
+
+	merged := Config{Profiles: map[string]Profile{}}
+	for _, p := range paths {
+		content, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) && path == "" {
+			continue
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		var cfg Config
+		if err := yaml.Unmarshal(content, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse config file %s: %w", p, err)
+		}
+
+		if cfg.DefaultProfile != "" {
+			merged.DefaultProfile = cfg.DefaultProfile
+		}
+		merged.Alerting = mergeAlerting(merged.Alerting, cfg.Alerting)
+		for name, profile := range cfg.Profiles {
+			merged.Profiles[name] = mergeProfile(merged.Profiles[name], profile)
+		}
+	}
+
+	return merged, nil
+}
+
+// Function to get a profile by name, or the default profile when name is empty
+func (c Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile '%s' not found in the configuration", name)
+	}
+	if _, ok := Providers[profile.Provider]; profile.Provider != "" && !ok {
+		return Profile{}, fmt.Errorf("profile '%s' has unknown provider '%s'", name, profile.Provider)
+	}
+
+	return profile, nil
+}
+
+// helper function to override the fields of base that are set in override
+func mergeProfile(base, override Profile) Profile {
+	mergeString := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	mergeString(&base.Provider, override.Provider)
+	mergeString(&base.APIBaseURL, override.APIBaseURL)
+	mergeString(&base.Token, override.Token)
+	mergeString(&base.HeartbeatGroup, override.HeartbeatGroup)
+	mergeString(&base.User, override.User)
+	mergeString(&base.Grace, override.Grace)
+	mergeString(&base.NameTemplate, override.NameTemplate)
+
+	if override.Call != nil {
+		base.Call = override.Call
+	}
+	if override.SMS != nil {
+		base.SMS = override.SMS
+	}
+	if override.Email != nil {
+		base.Email = override.Email
+	}
+	if override.Push != nil {
+		base.Push = override.Push
+	}
+	if override.TeamWait != nil {
+		base.TeamWait = override.TeamWait
+	}
+
+	return base
+}
+
+// Function to parse a grace setting, either a percentage of the period such
+// as "20%", a number of seconds or a duration such as "5m"
+func ParseGrace(value string) (ratio float64, seconds int, err error) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent < 0 {
+			return 0, 0, fmt.Errorf("invalid grace '%s'", value)
+		}
+		return percent / 100, 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return 0, n, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, 0, fmt.Errorf("invalid grace '%s': expected a percentage, seconds or a duration", value)
+	}
+	return 0, int(duration.Seconds()), nil
+}
+
+// Function to resolve a token reference: "env:NAME" reads an environment
+// variable, "file:PATH" reads a token file, "command:CMD" runs a command and
+// anything else is the token itself
+func ResolveTokenReference(reference string) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, "env:"):
+		name := strings.TrimPrefix(reference, "env:")
+		token := strings.TrimSpace(os.Getenv(name))
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return token, nil
+	case strings.HasPrefix(reference, "file:"):
+		return ReadTokenFile(strings.TrimPrefix(reference, "file:"))
+	case strings.HasPrefix(reference, "command:"):
+		return RunTokenCommand(strings.TrimPrefix(reference, "command:"))
+	default:
+		return reference, nil
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules file read by beatify watch
+var RulesFile = "/etc/beatify/rules.yaml"
+
+// Actions of a rule
+const (
+	ActionMonitor = "monitor"
+	ActionIgnore  = "ignore"
+)
+
+// Rules decide which cron tasks beatify watch monitors: the first rule
+// matching a task applies, the default action otherwise
+type Rules struct {
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule applies to the cron tasks whose user and command match patterns,
+// where * stands for any text, slashes included, and ? for one character;
+// an empty pattern matches any task
+type Rule struct {
+	User   string `yaml:"user"`
+	Match  string `yaml:"match"`
+	Action string `yaml:"action"`
+	// Heartbeat group and grace of the heartbeats created, the profile
+	// settings when empty
+	HeartbeatGroup string `yaml:"heartbeat_group"`
+	Grace          string `yaml:"grace"`
+}
+
+// Function to read and check a rules file
+func LoadRules(path string) (Rules, error) {
+	var rules Rules
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		return rules, fmt.Errorf("failed to parse rules file %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return rules, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Function to check the actions, patterns and graces of the rules
+func (r Rules) Validate() error {
+	if r.Default != "" && r.Default != ActionMonitor && r.Default != ActionIgnore {
+		return fmt.Errorf("unknown default action '%s': expected '%s' or '%s'", r.Default, ActionMonitor, ActionIgnore)
+	}
+	for i, rule := range r.Rules {
+		if rule.Action != ActionMonitor && rule.Action != ActionIgnore {
+			return fmt.Errorf("rule %d has unknown action '%s': expected '%s' or '%s'", i+1, rule.Action, ActionMonitor, ActionIgnore)
+		}
+		if rule.Grace != "" {
+			if _, _, err := ParseGrace(rule.Grace); err != nil {
+				return fmt.Errorf("rule %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Function to find the rule applying to a cron task, a rule with the
+// default action when none matches
+func (r Rules) Find(user, command string) Rule {
+	for _, rule := range r.Rules {
+		if MatchPattern(rule.User, user) && MatchPattern(rule.Match, command) {
+			return rule
+		}
+	}
+
+	action := r.Default
+	if action == "" {
+		action = ActionIgnore
+	}
+	return Rule{Action: action}
+}
+
+// Function to match a value against a rule or route pattern, where * stands
+// for any text, slashes included, and ? for one character; an empty pattern
+// matches any value
+func MatchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	return regexp.MustCompile("^" + expression + "$").MatchString(value)
+}
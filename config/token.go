@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Input of the token command, so it can prompt for a passphrase; nil gives
+// it /dev/null
+var TokenCommandInput io.Reader = os.Stdin
+
+// Function to read a token file that only its owner can read
+func ReadTokenFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("token file %s is accessible by group or others (mode %04o), restrict it with chmod 600", path, info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid := uint32(os.Getuid())
+		if stat.Uid != uid && stat.Uid != 0 {
+			return "", fmt.Errorf("token file %s must be owned by the current user or root", path)
+		}
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Function to get the token from the output of a command such as
+// "pass show betterstack/prod"
+func RunTokenCommand(command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Let the command prompt for a passphrase if it needs to
+	cmd.Stdin = TokenCommandInput
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("token command failed: %w\nOutput: %s", err, stderr.String())
+	}
+
+	// Password managers print the secret on the first line
+	token := strings.SplitN(strings.TrimSpace(stdout.String()), "\n", 2)[0]
+	return strings.TrimSpace(token), nil
+}
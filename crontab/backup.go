@@ -0,0 +1,224 @@
+package crontab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layout of the timestamp embedded in backup file names. New backups carry
+// microseconds, which parsing with this layout accepts as well
+const BackupTimeLayout = "20060102T150405Z"
+
+// Layout used to name new backups, so backups taken within a second do not
+// replace each other
+const backupNameLayout = "20060102T150405.000000Z"
+
+// Settings for where crontab backups are kept and how many are retained
+var (
+	BackupDir       = ""
+	BackupRetention = 10
+)
+
+// Backup describes one timestamped crontab backup
+type Backup struct {
+	User  string
+	Path  string
+	Taken time.Time
+}
+
+// Helper function to get the backup directory of a crontab user
+func userBackupDir(crontabUser string) (string, error) {
+	baseDir := BackupDir
+	if baseDir == "" {
+		// Default to a directory in the invoking user's home
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		baseDir = filepath.Join(homeDir, ".beatify", "backups")
+	}
+
+	return filepath.Join(baseDir, crontabUser), nil
+}
+
+// Helper function to create the backup directory and write a new backup
+// file in it. An existing backup is never replaced: the name is moved on
+// by a microsecond until it is free
+func writeBackup(crontabUser string, content []byte, taken time.Time) (string, error) {
+	dir, err := userBackupDir(crontabUser)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	for {
+		fileName := fmt.Sprintf("%s-%s.bak", BackupFilePrefix, taken.UTC().Format(backupNameLayout))
+		backupPath := filepath.Join(dir, fileName)
+		file, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			taken = taken.Add(time.Microsecond)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create backup file: %w", err)
+		}
+
+		if _, err := file.Write(content); err != nil {
+			file.Close()
+			os.Remove(backupPath)
+			return "", fmt.Errorf("failed to write crontab to backup file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			os.Remove(backupPath)
+			return "", fmt.Errorf("failed to write crontab to backup file: %w", err)
+		}
+		return backupPath, nil
+	}
+}
+
+// Function to back up the current crontab of a user, keeping at most
+// BackupRetention backups. Nothing is written when the crontab cannot be read
+func BackupCrontab(crontabUser string) (string, error) {
+	if err := IsValidUsername(crontabUser); err != nil {
+		return "", err
+	}
+
+	content, err := Store.Read(crontabUser)
+	if err != nil {
+		return "", err
+	}
+	backupPath, err := writeBackup(crontabUser, content, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	// Drop the backups beyond the retention limit
+	if err := pruneBackups(crontabUser); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// Function to list the backups of a user's crontab, oldest first
+func ListBackups(crontabUser string) ([]Backup, error) {
+	if err := IsValidUsername(crontabUser); err != nil {
+		return nil, err
+	}
+
+	dir, err := userBackupDir(crontabUser)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, BackupFilePrefix+"-") || !strings.HasSuffix(name, ".bak") {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, BackupFilePrefix+"-"), ".bak")
+		taken, err := time.Parse(BackupTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			User:  crontabUser,
+			Path:  filepath.Join(dir, name),
+			Taken: taken,
+		})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Taken.Before(backups[j].Taken)
+	})
+
+	return backups, nil
+}
+
+// Function to find the latest backup taken at or before the given time
+func FindBackup(crontabUser string, at time.Time) (Backup, error) {
+	backups, err := ListBackups(crontabUser)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !backups[i].Taken.After(at) {
+			return backups[i], nil
+		}
+	}
+
+	return Backup{}, fmt.Errorf("no crontab backup of '%s' taken at or before %s", crontabUser, at.Format(time.RFC3339))
+}
+
+// Function to reinstall a backup as the user's crontab. The crontab it
+// replaces is backed up first, once the backup restored is read, and the
+// path of that new backup is returned
+func RestoreBackup(backup Backup) (string, error) {
+	if err := IsValidUsername(backup.User); err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(backup.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup file: %w", err)
+	}
+
+	replaced, err := BackupCrontab(backup.User)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up the crontab replaced: %w", err)
+	}
+
+	// reloadCrontab removes the file it loads, so install from a copy
+	restoreFile, err := ioutil.TempFile("", TempFilePrefix)
+	if err != nil {
+		return replaced, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer restoreFile.Close()
+
+	if _, err := restoreFile.Write(content); err != nil {
+		os.Remove(restoreFile.Name())
+		return replaced, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	return replaced, reloadCrontab(restoreFile.Name(), backup.User)
+}
+
+// helper function to remove the oldest backups beyond BackupRetention
+func pruneBackups(crontabUser string) error {
+	if BackupRetention <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(crontabUser)
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > BackupRetention {
+		if err := os.Remove(backups[0].Path); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
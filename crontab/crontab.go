@@ -2,13 +2,19 @@ package crontab
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/user"
 	"regexp"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
 )
 
 type CronTask struct {
@@ -16,28 +22,115 @@ type CronTask struct {
 	Task         string
 	Name         string
 	HeartbeatURL string
+
+	// Heartbeat group name and grace period in seconds chosen for this task,
+	// the run defaults are used when empty or NoGrace
+	Group string
+	Grace int
 }
 
+// Grace of a cron task using the default grace of its schedule, a grace of
+// 0 being a valid choice
+const NoGrace = -1
+
 // Constants for temp and backup file prefixes
 const (
 	TempFilePrefix   = "crontab"
 	BackupFilePrefix = "crontab_backup"
 )
 
+// Format of the curl command appended to approved cron tasks
+const curlCommandFormat = `curl -fs --retry 3 %s > /dev/null 2>&1`
+
 // Package-level variables for the temp and backup files
 var (
 	TempFile   *os.File
 	BackupFile *os.File
 )
 
+// Input the approval prompts read answers from
+var (
+	PromptInput   io.Reader = os.Stdin
+	bufferedInput *bufio.Reader
+	bufferedFrom  io.Reader
+)
+
+// ErrCrontabChanged is returned when the crontab was edited during the review
+var ErrCrontabChanged = errors.New("crontab changed since it was reviewed")
+
+// Crontab content the user reviewed, checked again before installing
+var (
+	approvedFingerprint string
+	approvedLines       []string
+)
+
+// Hook called for every cron task line left out of the approved tasks
+var ReportSkipped = func(line, reason string) {}
+
+// Hook called to explain the schedule of a cron task before asking for its
+// approval, in the timezone set by CRON_TZ or the host timezone
+var ExplainSchedule = func(spec string, location *time.Location) {}
+
+// Whether the crontab being edited is a system crontab with a user field
+var SystemCrontab bool
+
+// Whether usernames must resolve to an account on this host
+var RequireSystemUser = true
+
+// Function to check a username is safe to use as a path element and
+// command argument and, unless disabled, names an existing account
 func IsValidUsername(username string) error {
-	r := regexp.MustCompile(`^[a-zA-Z0-9_-]{3,16}$`)
-	if !r.MatchString(username) {
-		return fmt.Errorf("invalid username '%s': a valid username is 3-16 characters long and consists of alphanumeric characters, underscores, or dashes", username)
+	if username == "" || username == "." || username == ".." {
+		return fmt.Errorf("invalid username '%s'", username)
+	}
+	if strings.HasPrefix(username, "-") {
+		return fmt.Errorf("invalid username '%s': a username cannot start with a dash", username)
+	}
+	for _, r := range username {
+		if r == '/' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("invalid username '%s': a username cannot contain slashes, spaces or control characters", username)
+		}
+	}
+
+	if !RequireSystemUser {
+		return nil
+	}
+	if _, err := LookupUser(username); err != nil {
+		return fmt.Errorf("invalid username '%s': %w", username, err)
 	}
 	return nil
 }
 
+// Function to look up an account through the name service switch, so
+// LDAP and SSSD accounts resolve even when os/user only reads /etc/passwd
+func LookupUser(username string) (*user.User, error) {
+	account, err := user.Lookup(username)
+	if err == nil {
+		return account, nil
+	}
+	if _, ok := err.(user.UnknownUserError); !ok {
+		return nil, err
+	}
+
+	// getent consults every NSS source configured on the host
+	output, getentErr := exec.Command("getent", "passwd", username).Output()
+	if getentErr != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(output)), ":")
+	if len(fields) < 7 || fields[0] != username {
+		return nil, err
+	}
+
+	return &user.User{
+		Username: fields[0],
+		Uid:      fields[2],
+		Gid:      fields[3],
+		Name:     fields[4],
+		HomeDir:  fields[5],
+	}, nil
+}
+
 // Helper function to read crontab file
 func readCrontabFile() ([]string, error) {
 	// Ensure TempFile is not nil
@@ -80,24 +173,25 @@ func updateCronTask(cronTask CronTask, lines []string) ([]string, error) {
 		return nil, fmt.Errorf("invalid HeartbeatURL in task '%s': %w", cronTask.Task, err)
 	}
 
-	// Construct the curl command string to append to the task
-	curlCommand := fmt.Sprintf(`curl -fs --retry 3 %s > /dev/null 2>&1`, cronTask.HeartbeatURL)
-
-	// Construct the task spec string
+	// Compare lines field by field, whatever their spacing
 	taskSpec := cronTask.Spec + " " + cronTask.Task
 
 	var updatedLines []string
 
 	// Check if the line matches the cron task
 	for _, line := range lines {
-		if strings.Contains(line, taskSpec) {
+		if strings.Join(strings.Fields(line), " ") == taskSpec {
 			// Check if the curl command is already appended to the task
-			if !strings.Contains(line, curlCommand) {
-				// Append the curl command to the task
-				line += " && " + curlCommand
-			} else {
+			if _, _, ok := StripLine(line); ok {
 				return nil, fmt.Errorf("curl command is already appended to task '%s'", cronTask.Task)
 			}
+
+			// Append the curl command to the command of the task
+			instrumented, err := InstrumentLine(line, cronTask.HeartbeatURL)
+			if err != nil {
+				return nil, fmt.Errorf("cannot append curl command to task '%s': %w", cronTask.Task, err)
+			}
+			line = instrumented
 		}
 
 		updatedLines = append(updatedLines, line)
@@ -135,7 +229,14 @@ func ParseAndApproveCronTasks(crontabUser string) ([]CronTask, error) {
 		return nil, err
 	}
 
+	// Remember the reviewed content to detect concurrent edits
+	if err := recordApprovedContent(lines); err != nil {
+		return nil, err
+	}
+
 	approvedCronTasks := []CronTask{}
+	skipRest := false
+	location := time.Local
 
 	for _, line := range lines {
 		// Ignore empty lines and comments
@@ -146,56 +247,172 @@ func ParseAndApproveCronTasks(crontabUser string) ([]CronTask, error) {
 		// If cron task contains "uptime.betterstack", skip and inform user
 		if strings.Contains(line, "uptime.betterstack") {
 			fmt.Println("Skipping cron task containing 'uptime.betterstack.com':", line)
+			ReportSkipped(line, "already monitored")
+			continue
+		}
+
+		// Environment assignments such as SHELL=/bin/sh are not tasks, but
+		// CRON_TZ sets the timezone of the tasks that follow
+		if isEnvironmentLine(line) {
+			if zone, ok := cronTimezone(line); ok {
+				if loaded, err := time.LoadLocation(zone); err == nil {
+					location = loaded
+				}
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 6 || (SystemCrontab && len(fields) < 7) {
+			fmt.Println("Skipping invalid cron task:", line)
+			ReportSkipped(line, "invalid cron task")
+			continue
+		}
+
+		spec := strings.Join(fields[:5], " ")
+		task := strings.Join(fields[5:], " ")
+
+		// Tasks whose command cannot take the curl command are not offered
+		if err := CheckInstrumentable(line); err != nil {
+			fmt.Printf("Skipping cron task that cannot be instrumented (%v): %s\n", err, line)
+			ReportSkipped(line, err.Error())
+			continue
+		}
+
+		// The remaining tasks are skipped without asking
+		if skipRest {
+			ReportSkipped(line, "not reviewed")
 			continue
 		}
 
 		// Display the cron task and ask for approval
 		fmt.Println("Cron task:", line)
-		isApproved, exitLoop, err := promptApproval()
+		ExplainSchedule(spec, location)
+		isApproved, exitLoop, err := PromptApproval()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get approval: %w", err)
 		}
 		if exitLoop {
-			break
+			skipRest = true
+			ReportSkipped(line, "not reviewed")
+			continue
 		}
 		if !isApproved {
+			ReportSkipped(line, "declined")
 			continue
 		}
 
+		// System crontabs name the user running the task in the sixth field
+		taskUser, command := crontabUser, task
+		if SystemCrontab {
+			taskUser, command = fields[5], strings.Join(fields[6:], " ")
+		}
+
 		// Prompt the user to enter the name for the heartbeat
-		name, err := promptHeartbeatName()
+		name, err := PromptHeartbeatName(DefaultHeartbeatName(taskUser, command))
 		if err != nil {
 			return nil, err
 		}
 
-		fields := strings.Fields(line)
-		if len(fields) < 6 {
-			fmt.Println("Skipping invalid cron task:", line)
-			continue
-		}
-
-		spec := strings.Join(fields[:5], " ")
-		task := strings.Join(fields[5:], " ")
-
 		approvedCronTasks = append(approvedCronTasks, CronTask{
-			Spec: spec,
-			Task: task,
-			Name: name,
+			Spec:  spec,
+			Task:  task,
+			Name:  name,
+			Grace: NoGrace,
 		})
 	}
 
 	return approvedCronTasks, nil
 }
 
-func promptHeartbeatName() (string, error) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Enter the name for the heartbeat: ")
-	name, err := reader.ReadString('\n')
+// Maximum length of a default heartbeat name
+const maxDefaultNameLength = 80
+
+// Template of the default heartbeat name, rendered with NameTemplateData
+var NameTemplate = "{{.User}}: {{.Command}}"
+
+// NameTemplateData is the data available to NameTemplate
+type NameTemplateData struct {
+	User    string
+	Host    string
+	Command string
+}
+
+// Regular expression matching the redirections of a command with their
+// target, such as "> /dev/null", "2>&1" or "&>> out.log"
+var redirectionRegexp = regexp.MustCompile(`(\s+\d+|\s*&|\s*)(>>?|<)(&\d+|&-|\s*[^\s;&|<>]+)`)
+
+// Function to build the default heartbeat name of a task run by a user
+func DefaultHeartbeatName(taskUser, command string) string {
+	// Drop the redirections, then the characters refused in heartbeat names
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`";$|><&`, r) {
+			return ' '
+		}
+		return r
+	}, redirectionRegexp.ReplaceAllString(command, " "))
+
+	host, _ := os.Hostname()
+	data := NameTemplateData{
+		User:    taskUser,
+		Host:    host,
+		Command: strings.Join(strings.Fields(cleaned), " "),
+	}
+
+	var rendered strings.Builder
+	tmpl, err := template.New("name").Parse(NameTemplate)
+	if err != nil || tmpl.Execute(&rendered, data) != nil {
+		// Fall back to the built-in format rather than fail the prompt
+		rendered.Reset()
+		rendered.WriteString(data.User + ": " + data.Command)
+	}
+
+	name := rendered.String()
+	if len(name) > maxDefaultNameLength {
+		name = strings.TrimSpace(name[:maxDefaultNameLength])
+	}
+	return name
+}
+
+// Regular expression matching variable assignments such as MAILTO=ops
+var environmentLineRegexp = regexp.MustCompile(`^\s*[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+// helper function to detect variable assignments
+func isEnvironmentLine(line string) bool {
+	return environmentLineRegexp.MatchString(line)
+}
+
+// helper function to get the timezone set by a CRON_TZ line
+func cronTimezone(line string) (string, bool) {
+	name, value, _ := strings.Cut(line, "=")
+	if strings.TrimSpace(name) != "CRON_TZ" {
+		return "", false
+	}
+	return strings.Trim(strings.TrimSpace(value), `"'`), true
+}
+
+// helper function to share one buffered reader between prompts, so answers
+// typed ahead or piped in are not lost
+func promptReader() *bufio.Reader {
+	if bufferedInput == nil || bufferedFrom != PromptInput {
+		bufferedInput = bufio.NewReader(PromptInput)
+		bufferedFrom = PromptInput
+	}
+	return bufferedInput
+}
+
+// Function to ask for the name of a heartbeat, defaultName when left empty
+func PromptHeartbeatName(defaultName string) (string, error) {
+	fmt.Printf("Enter the name for the heartbeat [%s]: ", defaultName)
+	name, err := promptReader().ReadString('\n')
 	if err != nil {
 		return "", fmt.Errorf("failed to read input for heartbeat name: %w", err)
 	}
 
 	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultName
+	}
 
 	// Perform basic validation to prevent command injection
 	if strings.ContainsAny(name, `";$|><&`) {
@@ -205,10 +422,11 @@ func promptHeartbeatName() (string, error) {
 	return name, nil
 }
 
-func promptApproval() (bool, bool, error) {
-	reader := bufio.NewReader(os.Stdin)
+// Function to ask whether to approve an item: the first result approves it,
+// the second skips the remaining items
+func PromptApproval() (bool, bool, error) {
 	fmt.Print("Continue? (n skips this cron, N skips the rest of the crons) (y/n/N): ")
-	text, err := reader.ReadString('\n')
+	text, err := promptReader().ReadString('\n')
 	if err != nil {
 		return false, false, fmt.Errorf("error reading input: %w", err)
 	}
@@ -246,35 +464,27 @@ func DumpCrontabToFile(crontabUser, fileType string) error {
 		}
 		filePath = TempFile.Name()
 	case "backup":
-		// Use the user's home directory to store backup file
-		homeDir, err := os.UserHomeDir()
+		// A timestamped file in the target user's backup directory, only
+		// written once the crontab was read
+		backupFilePath, err := BackupCrontab(crontabUser)
 		if err != nil {
-			return fmt.Errorf("failed to get user home directory: %w", err)
+			return err
 		}
-		backupFilePath := fmt.Sprintf("%s/%s.bak", homeDir, BackupFilePrefix)
-		BackupFile, err = os.OpenFile(backupFilePath, os.O_RDWR|os.O_CREATE, 0666)
+		// BackupFile keeps the name of the latest backup
+		BackupFile, err = os.Open(backupFilePath)
 		if err != nil {
-			return fmt.Errorf("failed to open or create backup file: %w", err)
+			return fmt.Errorf("failed to open backup file: %w", err)
 		}
-		filePath = BackupFile.Name()
+		BackupFile.Close()
+		return nil
 	default:
 		return fmt.Errorf("invalid file type: %s", fileType)
 	}
 
-	// Get the crontab file path
-	crontabFilePath := ""
-	if crontabUser != "" {
-		// User-specific crontab file
-		crontabFilePath = fmt.Sprintf("/var/spool/cron/crontabs/%s", crontabUser)
-	} else {
-		// Current user's crontab file
-		crontabFilePath = fmt.Sprintf("/var/spool/cron/crontabs/%s", os.Getenv("USER"))
-	}
-
-	// Read the crontab file
-	bytes, err := ioutil.ReadFile(crontabFilePath)
+	// Read the crontab from the store
+	bytes, err := Store.Read(crontabUser)
 	if err != nil {
-		return fmt.Errorf("failed to read crontab file: %w", err)
+		return err
 	}
 
 	// Write the crontab content to the file
@@ -296,23 +506,51 @@ func PrepareCrontabFiles(crontabUser string) error {
 		return err
 	}
 
-	// Backup crontab
-	if err := DumpCrontabToFile(crontabUser, "backup"); err != nil {
-		return err
-	}
-
-	return nil
+	// Backup crontab, dropping the backups beyond the retention limit
+	return DumpCrontabToFile(crontabUser, "backup")
 }
 
 // Function to append curl command to crontab tasks
 func AppendCronsCommand(cronTasks []CronTask, crontabUser string) error {
+	return rewriteCrontab(crontabUser, func(lines []string) ([]string, error) {
+		var err error
+		// Loop through the cron tasks
+		for _, cronTask := range cronTasks {
+			// Update the cron task
+			lines, err = updateCronTask(cronTask, lines)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return lines, nil
+	})
+}
 
+// Function to check the crontab did not change since it was reviewed, before
+// heartbeats are created for its tasks
+func CheckUnchanged(crontabUser string) error {
 	if err := IsValidUsername(crontabUser); err != nil {
 		return err
 	}
+	if err := DumpCrontabToFile(crontabUser, "temp"); err != nil {
+		return err
+	}
+	lines, err := readCrontabFile()
+	if err != nil {
+		return err
+	}
+	return checkApprovedContent(lines)
+}
 
-	// Prepare the temporary and backup crontab files
-	if err := PrepareCrontabFiles(crontabUser); err != nil {
+// helper function to apply an update to the reviewed crontab and install it
+func rewriteCrontab(crontabUser string, update func([]string) ([]string, error)) error {
+
+	if err := IsValidUsername(crontabUser); err != nil {
+		return err
+	}
+
+	// Refresh the temp crontab file, the backup was taken before approval
+	if err := DumpCrontabToFile(crontabUser, "temp"); err != nil {
 		return err
 	}
 
@@ -322,13 +560,14 @@ func AppendCronsCommand(cronTasks []CronTask, crontabUser string) error {
 		return err
 	}
 
-	// Loop through the cron tasks
-	for _, cronTask := range cronTasks {
-		// Update the cron task
-		lines, err = updateCronTask(cronTask, lines)
-		if err != nil {
-			return err
-		}
+	// Abort if the crontab changed since it was reviewed
+	if err := checkApprovedContent(lines); err != nil {
+		return err
+	}
+
+	lines, err = update(lines)
+	if err != nil {
+		return err
 	}
 
 	// Write the updated lines back to the temp file
@@ -344,23 +583,48 @@ func AppendCronsCommand(cronTasks []CronTask, crontabUser string) error {
 	return nil
 }
 
+// helper function to fingerprint the crontab content that was reviewed
+func recordApprovedContent(lines []string) error {
+	content, err := ioutil.ReadFile(TempFile.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read temp crontab file: %w", err)
+	}
+	approvedFingerprint = fingerprint(content)
+	approvedLines = lines
+	return nil
+}
+
+// helper function to compare the live crontab with the reviewed one
+func checkApprovedContent(lines []string) error {
+	if approvedFingerprint == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(TempFile.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read temp crontab file: %w", err)
+	}
+	if fingerprint(content) == approvedFingerprint {
+		return nil
+	}
+
+	return fmt.Errorf("%w, nothing was installed:\n%s", ErrCrontabChanged, diffLines(approvedLines, lines))
+}
+
 // Function to load reload crontab from a file
 func reloadCrontab(fileName, crontabUser string) error {
 	if err := IsValidUsername(crontabUser); err != nil {
 		return err
 	}
-	var cmd *exec.Cmd
-	// Check if crontabUser is supplied
-	if crontabUser != "" {
-		// Load crontab from specified file for specific user
-		cmd = exec.Command("sh", "-c", fmt.Sprintf("crontab -u %s %s", crontabUser, fileName))
-	} else {
-		// Load crontab from specified file
-		cmd = exec.Command("sh", "-c", fmt.Sprintf("crontab %s", fileName))
-	}
-	output, err := cmd.CombinedOutput()
+
+	content, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		return fmt.Errorf("failed to load crontab from %s: %w\nOutput: %s", fileName, err, string(output))
+		return fmt.Errorf("failed to read crontab from %s: %w", fileName, err)
+	}
+
+	// Install the crontab through the configured store
+	if err := Store.Write(crontabUser, content); err != nil {
+		return err
 	}
 
 	// Cleanup: Delete the temporary file
@@ -0,0 +1,252 @@
+package crontab
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Names of the months and week days, in cron order
+var (
+	monthNames   = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+)
+
+// Schedule macros of cron and Kubernetes and the cron schedule they stand
+// for, @reboot having none
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Function to replace a macro such as @daily by the five-field cron
+// schedule it stands for, other schedules being returned unchanged
+func ExpandScheduleMacro(spec string) string {
+	if macro, ok := scheduleMacros[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		return macro
+	}
+	return spec
+}
+
+// Function to describe a five-field cron schedule or a macro in plain
+// English, such as "at 02:30 on Monday through Friday"
+func DescribeSchedule(spec string) string {
+	fields := strings.Fields(ExpandScheduleMacro(spec))
+	if len(fields) != 5 {
+		return spec
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	parts := []string{describeTime(minute, hour)}
+
+	// Cron runs a task when either the day of month or the week day matches
+	switch {
+	case dom != "*" && dow != "*":
+		parts = append(parts, describeDays(dom)+" or on "+describeField(dow, "week day", weekdayNames))
+	case dom != "*":
+		parts = append(parts, describeDays(dom))
+	case dow != "*":
+		parts = append(parts, "on "+describeField(dow, "week day", weekdayNames))
+	case minute != "*" && hour != "*" && !strings.HasPrefix(hour, "*/"):
+		parts = append(parts, "every day")
+	}
+
+	if month != "*" {
+		parts = append(parts, "in "+describeField(month, "month", monthNames))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Function to split the CRON_TZ=ZONE or TZ=ZONE prefix from a schedule,
+// returning the host timezone when there is none
+func SplitScheduleTimezone(spec string) (string, *time.Location, error) {
+	spec = strings.TrimSpace(spec)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if !strings.HasPrefix(spec, prefix) {
+			continue
+		}
+		zone, rest, _ := strings.Cut(strings.TrimPrefix(spec, prefix), " ")
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return "", nil, fmt.Errorf("unknown timezone '%s': %w", zone, err)
+		}
+		return strings.TrimSpace(rest), location, nil
+	}
+	return spec, time.Local, nil
+}
+
+// Function to compute the next run times of a five-field cron schedule in
+// a timezone
+func NextRuns(spec string, location *time.Location, from time.Time, count int) ([]time.Time, error) {
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %w", spec, err)
+	}
+
+	runs := make([]time.Time, 0, count)
+	next := from.In(location)
+	for i := 0; i < count; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+// helper function to describe the minute and hour fields of a schedule
+func describeTime(minute, hour string) string {
+	switch {
+	case minute == "*" && hour == "*":
+		return "every minute"
+	case strings.HasPrefix(minute, "*/") && hour == "*":
+		return "every " + strings.TrimPrefix(minute, "*/") + " minutes"
+	case minute == "*":
+		return "every minute of " + describeHours(hour)
+	case strings.ContainsAny(minute, "-/") && !strings.Contains(minute, ",") && hour != "*":
+		// Repeated minutes read as a repetition within the hours
+		if window, ok := describeHourWindows(hour); ok {
+			return describeMinuteSteps(minute) + ", " + window
+		}
+		return describeMinuteSteps(minute) + " of " + describeHours(hour)
+	case hour == "*":
+		return "at minute " + describeField(minute, "minute", nil) + " of every hour"
+	case strings.HasPrefix(hour, "*/"):
+		return "at minute " + describeField(minute, "minute", nil) + " of every " + strings.TrimPrefix(hour, "*/") + " hours"
+	}
+
+	// Plain minute and hour values read as clock times
+	m, err := strconv.Atoi(minute)
+	if err == nil && !strings.ContainsAny(hour, "-/") {
+		var times []string
+		for _, h := range strings.Split(hour, ",") {
+			n, err := strconv.Atoi(h)
+			if err != nil {
+				times = nil
+				break
+			}
+			times = append(times, fmt.Sprintf("%02d:%02d", n, m))
+		}
+		if times != nil {
+			return "at " + joinWords(times)
+		}
+	}
+
+	return "at minute " + describeField(minute, "minute", nil) + " of " + describeHours(hour)
+}
+
+// helper function to describe a minute field made of one range or step,
+// such as "every 15 minutes" or "every minute from minute 0 through 30"
+func describeMinuteSteps(minute string) string {
+	base, step, hasStep := strings.Cut(minute, "/")
+	every := "every minute"
+	if hasStep {
+		every = "every " + step + " minutes"
+	}
+	switch {
+	case base == "*":
+		return every
+	case strings.Contains(base, "-"):
+		from, to, _ := strings.Cut(base, "-")
+		return every + " from minute " + from + " through " + to
+	}
+	return every + " from minute " + base
+}
+
+// helper function to describe an hour field made of values and ranges as
+// the clock times it covers, such as "between 09:00 and 17:59"
+func describeHourWindows(hour string) (string, bool) {
+	var windows []string
+	for _, item := range strings.Split(hour, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		if !isRange {
+			to = from
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			return "", false
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil {
+			return "", false
+		}
+		windows = append(windows, fmt.Sprintf("between %02d:00 and %02d:59", first, last))
+	}
+	return joinWords(windows), true
+}
+
+// helper function to describe a day of month field
+func describeDays(dom string) string {
+	days := describeField(dom, "day", nil)
+	if strings.HasPrefix(days, "every ") {
+		return days + " of the month"
+	}
+	return "on day " + days + " of the month"
+}
+
+// helper function to describe an hour field
+func describeHours(hour string) string {
+	if strings.HasPrefix(hour, "*/") {
+		return "every " + strings.TrimPrefix(hour, "*/") + " hours"
+	}
+	return "hour " + describeField(hour, "hour", nil)
+}
+
+// helper function to describe a field made of values, ranges and steps,
+// naming the values when names are given
+func describeField(field, unit string, names []string) string {
+	name := func(value string) string {
+		if names == nil {
+			return value
+		}
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n < len(names) {
+			return names[n]
+		}
+		// Cron also accepts the first three letters of the names
+		for _, full := range names {
+			if len(value) == 3 && strings.HasPrefix(strings.ToLower(full), strings.ToLower(value)) {
+				return full
+			}
+		}
+		return value
+	}
+
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step, hasStep := strings.Cut(item, "/")
+		var text string
+		if from, to, isRange := strings.Cut(rangePart, "-"); isRange {
+			text = name(from) + " through " + name(to)
+		} else if rangePart == "*" {
+			text = "every " + unit
+		} else {
+			text = name(rangePart)
+		}
+		if hasStep {
+			text = "every " + step + " " + unit + "s of " + strings.TrimPrefix(text, "every "+unit)
+			text = strings.TrimSuffix(text, " of ")
+		}
+		items = append(items, text)
+	}
+
+	return joinWords(items)
+}
+
+// helper function to join words as "a, b and c"
+func joinWords(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
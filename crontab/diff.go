@@ -0,0 +1,51 @@
+package crontab
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Helper function to fingerprint crontab content
+func fingerprint(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Helper function to render a line diff between two versions of a crontab
+func diffLines(before, after []string) string {
+	// Longest common subsequence table
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff strings.Builder
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			i++
+			j++
+		case j < len(after) && (i == len(before) || lcs[i][j+1] > lcs[i+1][j]):
+			diff.WriteString("+ " + after[j] + "\n")
+			j++
+		default:
+			diff.WriteString("- " + before[i] + "\n")
+			i++
+		}
+	}
+
+	return diff.String()
+}
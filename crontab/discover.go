@@ -0,0 +1,97 @@
+package crontab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Locations of the system crontabs, whose lines carry a user field
+var (
+	SystemCrontabFile = "/etc/crontab"
+	SystemCrontabDir  = "/etc/cron.d"
+)
+
+// Names cron accepts in /etc/cron.d, other files such as editor backups or
+// package manager leftovers are ignored by the daemon
+var cronDFileRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// CrontabTarget is one crontab found on the host
+type CrontabTarget struct {
+	// Name is the crontab user, or a name derived from the system crontab path
+	Name   string
+	Path   string
+	Store  CrontabStore
+	System bool
+}
+
+// Function to list every user crontab in the spool directory and the system crontabs
+func DiscoverCrontabs() ([]CrontabTarget, error) {
+	spool, ok := Store.(*SpoolStore)
+	if !ok {
+		return nil, fmt.Errorf("enumerating crontabs needs a spool store")
+	}
+
+	entries, err := ioutil.ReadDir(spool.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var targets []CrontabTarget
+	for _, entry := range entries {
+		name := entry.Name()
+		// Skip the temp files crontab writes while a user is editing
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "tmp.") {
+			continue
+		}
+		if err := IsValidUsername(name); err != nil {
+			fmt.Println("Skipping crontab of unknown user:", name)
+			continue
+		}
+		targets = append(targets, CrontabTarget{
+			Name:  name,
+			Path:  filepath.Join(spool.Dir, name),
+			Store: spool,
+		})
+	}
+
+	if _, err := os.Stat(SystemCrontabFile); err == nil {
+		targets = append(targets, systemTarget("system-crontab", SystemCrontabFile))
+	}
+
+	entries, err = ioutil.ReadDir(SystemCrontabDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", SystemCrontabDir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !cronDFileRegexp.MatchString(entry.Name()) {
+			continue
+		}
+		targets = append(targets, systemTarget("system-cron.d-"+entry.Name(), filepath.Join(SystemCrontabDir, entry.Name())))
+	}
+
+	return targets, nil
+}
+
+// helper function to describe a system crontab file, named after its place
+// among the system crontabs whatever their paths, so its backups never clash
+// with a user crontab
+func systemTarget(name, path string) CrontabTarget {
+	return CrontabTarget{
+		Name:   name,
+		Path:   path,
+		Store:  NewFileStore(path),
+		System: true,
+	}
+}
+
+// Function to point the package at a target before editing it
+func UseTarget(target CrontabTarget) {
+	Store = target.Store
+	SystemCrontab = target.System
+	// System crontab names are not accounts
+	RequireSystemUser = !target.System
+}
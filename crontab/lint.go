@@ -0,0 +1,181 @@
+package crontab
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/robfig/cron"
+)
+
+// LintRule is a check run by LintCrontab
+type LintRule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// Rules checked by LintCrontab
+var LintRules = []LintRule{
+	{"invalid-schedule", "the line is not a valid cron task"},
+	{"unescaped-percent", "cron turns an unescaped % into a newline, which cuts the command and the appended ping"},
+	{"missing-newline", "cron ignores the last line of a crontab without a trailing newline"},
+	{"no-redirect", "the output is not redirected, so every run mails it"},
+	{"every-minute", "the task runs every minute, its heartbeat would be noisy"},
+	{"duplicate", "the line repeats an earlier line"},
+	{"macro-schedule", "beatify cannot instrument @reboot, @daily and other macro schedules"},
+}
+
+// LintFinding is a problem found on a crontab line
+type LintFinding struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+}
+
+// Macro schedules understood by cron
+var cronMacros = map[string]bool{
+	"@reboot": true, "@yearly": true, "@annually": true, "@monthly": true,
+	"@weekly": true, "@daily": true, "@midnight": true, "@hourly": true,
+}
+
+// Function to check a user's crontab for common mistakes
+func LintUserCrontab(crontabUser string, disabled map[string]bool) ([]LintFinding, error) {
+	if err := IsValidUsername(crontabUser); err != nil {
+		return nil, err
+	}
+
+	content, err := Store.Read(crontabUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return LintCrontab(content, disabled)
+}
+
+// Function to check a crontab for common mistakes, leaving out the
+// disabled rules
+func LintCrontab(content []byte, disabled map[string]bool) ([]LintFinding, error) {
+	findings := []LintFinding{}
+	report := func(rule string, number int, text, message string) {
+		if !disabled[rule] {
+			findings = append(findings, LintFinding{Rule: rule, Line: number, Text: text, Message: message})
+		}
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	seen := map[string]int{}
+	mailDisabled := false
+	number := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		number++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// An empty MAILTO stops the mails for the tasks that follow
+		if isEnvironmentLine(trimmed) {
+			name, value, _ := strings.Cut(trimmed, "=")
+			if strings.TrimSpace(name) == "MAILTO" {
+				mailDisabled = strings.Trim(strings.TrimSpace(value), `"'`) == ""
+			}
+			continue
+		}
+
+		if first, ok := seen[trimmed]; ok {
+			report("duplicate", number, line, fmt.Sprintf("same as line %d", first))
+		} else {
+			seen[trimmed] = number
+		}
+
+		// Split the schedule from the command
+		fields := strings.Fields(trimmed)
+		var spec string
+		var commandFields []string
+		if strings.HasPrefix(fields[0], "@") {
+			if !cronMacros[fields[0]] {
+				report("invalid-schedule", number, line, fmt.Sprintf("unknown macro '%s'", fields[0]))
+				continue
+			}
+			report("macro-schedule", number, line, fmt.Sprintf("'%s' cannot be instrumented, use five schedule fields", fields[0]))
+			commandFields = fields[1:]
+		} else {
+			if len(fields) < 6 {
+				report("invalid-schedule", number, line, "expected five schedule fields and a command")
+				continue
+			}
+			spec = strings.Join(fields[:5], " ")
+			commandFields = fields[5:]
+		}
+		if SystemCrontab {
+			if len(commandFields) < 2 {
+				report("invalid-schedule", number, line, "expected a user and a command after the schedule")
+				continue
+			}
+			commandFields = commandFields[1:]
+		}
+
+		if spec != "" {
+			if _, err := parser.Parse(spec); err != nil {
+				report("invalid-schedule", number, line, err.Error())
+				continue
+			}
+			if spec == "* * * * *" {
+				report("every-minute", number, line, "runs every minute")
+			}
+		}
+
+		// Check the command as it was before a ping was appended, without
+		// its comment
+		command, _ := splitComment(strings.Join(commandFields, " "))
+		command = strings.TrimRight(command, " \t")
+		if original, _, ok := stripPing(command); ok {
+			command = original
+		}
+
+		if hasUnescapedPercent(command) {
+			report("unescaped-percent", number, line, `escape % as \% in the command`)
+		}
+		if !mailDisabled && !strings.Contains(command, ">") {
+			report("no-redirect", number, line, "redirect the output, e.g. > /dev/null 2>&1, or set MAILTO=\"\"")
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("error reading crontab: %w", scanner.Err())
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		report("missing-newline", number, "", "add a newline after the last line")
+	}
+
+	return findings, nil
+}
+
+// helper function to find a % not escaped with a backslash
+func hasUnescapedPercent(command string) bool {
+	for i := 0; i < len(command); i++ {
+		if command[i] == '\\' {
+			i++
+			continue
+		}
+		if command[i] == '%' {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check a rule ID names a known rule
+func IsLintRule(id string) bool {
+	for _, rule := range LintRules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
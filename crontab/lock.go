@@ -0,0 +1,162 @@
+package crontab
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Directory holding the advisory lock files, one per crontab edited. When
+// empty, /run/beatify for root and ~/.beatify/locks for other users
+var LockDir = ""
+
+// Lock is an advisory lock on a user's crontab held by this process
+type Lock struct {
+	file *os.File
+}
+
+// Function to take the advisory lock on the crontab the store edits for a
+// user without waiting. A stream store edits nothing shared and takes no lock
+func LockCrontab(crontabUser string) (*Lock, error) {
+	if err := IsValidUsername(crontabUser); err != nil {
+		return nil, err
+	}
+
+	target := lockTarget(crontabUser)
+	if target == "" {
+		return nil, nil
+	}
+	dir, err := lockDir()
+	if err != nil {
+		return nil, err
+	}
+	lockPath := filepath.Join(dir, lockFileName(target))
+	// A symlink planted in place of the lock file is not followed
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := checkOwner(file, lockPath); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := ioutil.ReadAll(file)
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("crontab of '%s' is being edited by another beatify run (pid %s), lock file %s",
+				crontabUser, strings.TrimSpace(string(holder)), lockPath)
+		}
+		return nil, fmt.Errorf("failed to lock crontab of '%s': %w", crontabUser, err)
+	}
+
+	// Record our pid so a blocked run can tell who holds the lock
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// helper function to get what the store edits for a user: the crontab file
+// when it has one, so two names for the same file share a lock and two files
+// of one user do not
+func lockTarget(crontabUser string) string {
+	var path string
+	switch store := Store.(type) {
+	case *StreamStore:
+		return ""
+	case *SpoolStore:
+		path = filepath.Join(store.Dir, crontabUser)
+	case *FileStore:
+		path = store.Path
+	default:
+		// The crontab binary installs into the spool directory of the host
+		dir := DetectSpoolDir()
+		if dir == "" {
+			return "crontab:" + crontabUser
+		}
+		path = filepath.Join(dir, crontabUser)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+// helper function to name the lock file of a target. The readable part is
+// the base name of the target, the hash of the whole target tells apart files
+// of the same name in different directories
+func lockFileName(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	base := unsafeLockNameRegexp.ReplaceAllString(filepath.Base(strings.TrimPrefix(target, "crontab:")), "_")
+	return fmt.Sprintf("beatify-%s-%s.lock", base, hex.EncodeToString(sum[:6]))
+}
+
+// Characters left out of the readable part of a lock file name
+var unsafeLockNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// helper function to get the directory of the lock files, created when
+// missing, and check only the current user can write to it
+func lockDir() (string, error) {
+	dir := LockDir
+	if dir == "" {
+		dir = "/run/beatify"
+		if os.Geteuid() != 0 {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to get user home directory: %w", err)
+			}
+			dir = filepath.Join(homeDir, ".beatify", "locks")
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read lock directory: %w", err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(stat.Uid) != os.Geteuid() || info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("lock directory %s must be a directory owned by the current user and not accessible by group or others", dir)
+	}
+	return dir, nil
+}
+
+// helper function to check a lock file belongs to the current user
+func checkOwner(file *os.File, path string) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %w", err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || !ok || int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("lock file %s is not a file owned by the current user", path)
+	}
+	return nil
+}
+
+// Unlock releases the advisory lock
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	defer l.file.Close()
+
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		return fmt.Errorf("failed to unlock crontab: %w", err)
+	}
+	l.file = nil
+
+	return nil
+}
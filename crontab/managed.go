@@ -0,0 +1,200 @@
+package crontab
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Function to read a user's crontab lines without making a copy
+func ReadCrontab(crontabUser string) ([]string, error) {
+	if err := IsValidUsername(crontabUser); err != nil {
+		return nil, err
+	}
+
+	content, err := Store.Read(crontabUser)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("error reading crontab file: %w", scanner.Err())
+	}
+
+	return lines, nil
+}
+
+// Function to list the cron tasks that already ping a heartbeat
+func ListManagedCronTasks(crontabUser string) ([]CronTask, error) {
+	lines, err := ReadCrontab(crontabUser)
+	if err != nil {
+		return nil, err
+	}
+
+	var managedCronTasks []CronTask
+	for _, line := range lines {
+		if cronTask, ok := parseManagedLine(line); ok {
+			managedCronTasks = append(managedCronTasks, cronTask)
+		}
+	}
+
+	return managedCronTasks, nil
+}
+
+// helper function to split a managed crontab line into its task and heartbeat URL
+func parseManagedLine(line string) (CronTask, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return CronTask{}, false
+	}
+
+	original, heartbeatURL, ok := StripLine(trimmed)
+	if !ok {
+		return CronTask{}, false
+	}
+
+	// The task is the command as it was before the ping was appended
+	prefix, command, _ := splitCommand(original)
+	body, _ := splitComment(command)
+	fields := strings.Fields(prefix + body)
+	if len(fields) < 6 {
+		return CronTask{}, false
+	}
+
+	return CronTask{
+		Spec:         strings.Join(fields[:5], " "),
+		Task:         strings.Join(fields[5:], " "),
+		HeartbeatURL: heartbeatURL,
+		Grace:        NoGrace,
+	}, true
+}
+
+// ScannedTask is a cron task line found in a crontab
+type ScannedTask struct {
+	CronTask
+	Line  string
+	State string
+}
+
+// States of a scanned cron task
+const (
+	TaskManaged   = "managed"
+	TaskUnmanaged = "unmanaged"
+	TaskInvalid   = "invalid"
+)
+
+// Function to list every cron task of a crontab with its monitoring state
+func ScanCrontab(crontabUser string) ([]ScannedTask, error) {
+	lines, err := ReadCrontab(crontabUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanLines(lines), nil
+}
+
+// helper function to classify the cron task lines of a crontab
+func scanLines(lines []string) []ScannedTask {
+	var tasks []ScannedTask
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || isEnvironmentLine(trimmed) {
+			continue
+		}
+
+		if cronTask, ok := parseManagedLine(trimmed); ok {
+			tasks = append(tasks, ScannedTask{CronTask: cronTask, Line: line, State: TaskManaged})
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		if len(fields) < 6 || (SystemCrontab && len(fields) < 7) {
+			tasks = append(tasks, ScannedTask{Line: line, State: TaskInvalid})
+			continue
+		}
+
+		tasks = append(tasks, ScannedTask{
+			CronTask: CronTask{
+				Spec:  strings.Join(fields[:5], " "),
+				Task:  strings.Join(fields[5:], " "),
+				Grace: NoGrace,
+			},
+			Line:  line,
+			State: TaskUnmanaged,
+		})
+	}
+
+	return tasks
+}
+
+// Function to present each cron task pinging a heartbeat for approval to
+// remove its ping
+func ParseAndApproveManagedCronTasks(crontabUser string) ([]CronTask, error) {
+
+	// Prepare the temp and backup crontab files
+	if err := PrepareCrontabFiles(crontabUser); err != nil {
+		return nil, err
+	}
+
+	// Read the temp crontab file
+	lines, err := readCrontabFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the reviewed content to detect concurrent edits
+	if err := recordApprovedContent(lines); err != nil {
+		return nil, err
+	}
+
+	approvedCronTasks := []CronTask{}
+	for _, line := range lines {
+		cronTask, ok := parseManagedLine(line)
+		if !ok {
+			continue
+		}
+
+		// Display the cron task and ask for approval
+		fmt.Println("Monitored cron task:", line)
+		isApproved, exitLoop, err := PromptApproval()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get approval: %w", err)
+		}
+		if exitLoop {
+			break
+		}
+		if !isApproved {
+			continue
+		}
+
+		approvedCronTasks = append(approvedCronTasks, cronTask)
+	}
+
+	return approvedCronTasks, nil
+}
+
+// Function to remove the heartbeat ping from crontab tasks
+func RemoveCronsCommand(cronTasks []CronTask, crontabUser string) error {
+	return rewriteCrontab(crontabUser, func(lines []string) ([]string, error) {
+		removals := make(map[string]bool, len(cronTasks))
+		for _, cronTask := range cronTasks {
+			removals[cronTask.HeartbeatURL] = true
+		}
+
+		var updatedLines []string
+		for _, line := range lines {
+			if original, heartbeatURL, ok := StripLine(line); ok && removals[heartbeatURL] {
+				// Keep the line as it was before the ping was appended
+				line = original
+			}
+			updatedLines = append(updatedLines, line)
+		}
+		return updatedLines, nil
+	})
+}
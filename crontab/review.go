@@ -0,0 +1,62 @@
+package crontab
+
+import (
+	"strings"
+)
+
+// Function to hand every cron task of a crontab to a review function, such
+// as the terminal UI, and return the tasks it approved
+func ReviewCronTasks(crontabUser string, review func([]ScannedTask) ([]CronTask, error)) ([]CronTask, error) {
+
+	// Prepare the temp and backup crontab files
+	if err := PrepareCrontabFiles(crontabUser); err != nil {
+		return nil, err
+	}
+
+	// Read the temp crontab file
+	lines, err := readCrontabFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the reviewed content to detect concurrent edits
+	if err := recordApprovedContent(lines); err != nil {
+		return nil, err
+	}
+
+	tasks := scanLines(lines)
+	approvedCronTasks, err := review(tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the tasks left out of the approved ones
+	approved := make(map[string]bool, len(approvedCronTasks))
+	for _, cronTask := range approvedCronTasks {
+		approved[cronTask.Spec+" "+cronTask.Task] = true
+	}
+	for _, task := range tasks {
+		switch {
+		case task.State == TaskManaged:
+			ReportSkipped(task.Line, "already monitored")
+		case task.State == TaskInvalid:
+			ReportSkipped(task.Line, "invalid cron task")
+		case !approved[task.Spec+" "+task.Task]:
+			ReportSkipped(task.Line, "declined")
+		}
+	}
+
+	return approvedCronTasks, nil
+}
+
+// Function to build the default heartbeat name of a cron task, taking the
+// user from the task itself in system crontabs
+func DefaultTaskHeartbeatName(crontabUser string, cronTask CronTask) string {
+	if SystemCrontab {
+		fields := strings.Fields(cronTask.Task)
+		if len(fields) > 1 {
+			return DefaultHeartbeatName(fields[0], strings.Join(fields[1:], " "))
+		}
+	}
+	return DefaultHeartbeatName(crontabUser, cronTask.Task)
+}
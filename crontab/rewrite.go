@@ -0,0 +1,224 @@
+package crontab
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Regular expression matching the ping appended by InstrumentLine at the end
+// of a command
+var pingSuffixRegexp = regexp.MustCompile(`\s+&&\s+curl -fs --retry 3 (\S+) > /dev/null 2>&1$`)
+
+// Function to rewrite a crontab line so its command pings a heartbeat URL
+// once it succeeded. Commands made of several commands are grouped so the
+// ping follows all of them, a backgrounded command is backgrounded with its
+// ping, and a trailing comment stays at the end of the line:
+//
+//	0 * * * * a; b        -> 0 * * * * ( a; b ) && curl ...
+//	0 * * * * a >> log &  -> 0 * * * * ( a >> log && curl ... ) &
+//	0 * * * * a # note    -> 0 * * * * a && curl ... # note
+func InstrumentLine(line, heartbeatURL string) (string, error) {
+	prefix, command, ok := splitCommand(line)
+	if !ok {
+		return "", fmt.Errorf("not a cron task")
+	}
+	body, comment := splitComment(command)
+	body = strings.TrimRight(body, " \t")
+	if body == "" {
+		return "", fmt.Errorf("the cron task has no command")
+	}
+
+	// Cron feeds what follows an unescaped % to the command as its input
+	if hasUnescapedPercent(body) {
+		return "", fmt.Errorf(`the command holds an unescaped %%, which would cut the ping: escape it as \%%`)
+	}
+	if _, _, ok := stripPing(body); ok {
+		return "", fmt.Errorf("the command already pings a heartbeat")
+	}
+
+	// The & backgrounding the command, with the spaces before it
+	background := ""
+	if trimmed, ok := trimBackground(body); ok {
+		body, background = trimmed, body[len(trimmed):]
+	}
+	if needsGrouping(body) {
+		body = "( " + body + " )"
+	}
+	body += " && " + fmt.Sprintf(curlCommandFormat, heartbeatURL)
+	if background != "" {
+		body = "( " + body + " )" + background
+	}
+
+	return prefix + body + comment, nil
+}
+
+// Function to check InstrumentLine can rewrite a crontab line
+func CheckInstrumentable(line string) error {
+	_, err := InstrumentLine(line, "https://uptime.betterstack.com")
+	return err
+}
+
+// Function to undo InstrumentLine, returning the line as it was before and
+// the heartbeat URL it pinged
+func StripLine(line string) (string, string, bool) {
+	prefix, command, ok := splitCommand(line)
+	if !ok {
+		return "", "", false
+	}
+	body, comment := splitComment(command)
+
+	original, heartbeatURL, ok := stripPing(strings.TrimRight(body, " \t"))
+	if !ok {
+		return "", "", false
+	}
+	return prefix + original + comment, heartbeatURL, true
+}
+
+// Regular expression matching a command backgrounded with its ping
+var backgroundedPingRegexp = regexp.MustCompile(`^\( (.*) \)(\s*&)$`)
+
+// helper function to remove the ping from a command
+func stripPing(body string) (string, string, bool) {
+	background := ""
+	if match := backgroundedPingRegexp.FindStringSubmatch(body); match != nil && pingSuffixRegexp.MatchString(match[1]) {
+		body, background = match[1], match[2]
+	}
+
+	match := pingSuffixRegexp.FindStringSubmatchIndex(body)
+	if match == nil {
+		return "", "", false
+	}
+	heartbeatURL := body[match[2]:match[3]]
+	original := body[:match[0]]
+
+	// Only the groups added by InstrumentLine are removed
+	if strings.HasPrefix(original, "( ") && strings.HasSuffix(original, " )") && needsGrouping(original[2:len(original)-2]) {
+		original = original[2 : len(original)-2]
+	}
+	return original + background, heartbeatURL, true
+}
+
+// helper function to split a crontab line after its schedule, and after the
+// user field of system crontabs, keeping the spacing of the line
+func splitCommand(line string) (string, string, bool) {
+	fieldCount := 5
+	if SystemCrontab {
+		fieldCount = 6
+	}
+
+	i := 0
+	for field := 0; field < fieldCount; field++ {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			return "", "", false
+		}
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+	}
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if i == len(line) {
+		return "", "", false
+	}
+
+	return line[:i], line[i:], true
+}
+
+// helper function to split a trailing shell comment, with the spaces before
+// it, from a command
+func splitComment(command string) (string, string) {
+	index := -1
+	forEachUnquoted(command, func(i int) bool {
+		if command[i] == '#' && (i == 0 || command[i-1] == ' ' || command[i-1] == '\t') {
+			index = i
+			return false
+		}
+		return true
+	})
+	if index < 0 {
+		return command, ""
+	}
+
+	start := len(strings.TrimRight(command[:index], " \t"))
+	return command[:start], command[start:]
+}
+
+// helper function to detect a command that must be grouped for a ping
+// appended with && to follow all of it: a ; or a & that backgrounds a command
+func needsGrouping(body string) bool {
+	found := false
+	forEachUnquoted(body, func(i int) bool {
+		switch body[i] {
+		case ';':
+			found = true
+		case '&':
+			found = !isRedirectOrAnd(body, i)
+		}
+		return !found
+	})
+	return found
+}
+
+// helper function to remove the & that backgrounds a whole command, and the
+// spaces before it
+func trimBackground(body string) (string, bool) {
+	last := len(body) - 1
+	if last < 0 || body[last] != '&' || isRedirectOrAnd(body, last) {
+		return "", false
+	}
+
+	// The & must not be quoted or escaped
+	unquoted := false
+	forEachUnquoted(body, func(i int) bool {
+		unquoted = i == last
+		return true
+	})
+	if !unquoted {
+		return "", false
+	}
+	return strings.TrimRight(body[:last], " \t"), true
+}
+
+// helper function to tell a & that belongs to &&, a redirection such as 2>&1
+// or &>, or |& apart from a & that backgrounds a command
+func isRedirectOrAnd(s string, i int) bool {
+	if i > 0 && (s[i-1] == '&' || s[i-1] == '>' || s[i-1] == '<' || s[i-1] == '|') {
+		return true
+	}
+	if i+1 < len(s) && (s[i+1] == '&' || s[i+1] == '>') {
+		return true
+	}
+	return false
+}
+
+// helper function to call a function with the index of every byte of a
+// command that is neither quoted nor escaped, until it returns false
+func forEachUnquoted(command string, f func(i int) bool) {
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		default:
+			if !f(i) {
+				return
+			}
+		}
+	}
+}
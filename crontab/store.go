@@ -0,0 +1,205 @@
+package crontab
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CrontabStore reads and installs the crontab of a user
+type CrontabStore interface {
+	// Read returns the current crontab content of the user
+	Read(crontabUser string) ([]byte, error)
+	// Write installs content as the crontab of the user
+	Write(crontabUser string, content []byte) error
+}
+
+// Spool directories of the common cron implementations
+const (
+	DebianSpoolDir = "/var/spool/cron/crontabs"
+	RHELSpoolDir   = "/var/spool/cron"
+)
+
+// Store used by the package to read and install crontabs
+var Store CrontabStore = NewSpoolStore(DebianSpoolDir)
+
+// Function to find the spool directory of the host's cron implementation
+func DetectSpoolDir() string {
+	for _, dir := range []string{DebianSpoolDir, RHELSpoolDir} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// SpoolStore reads crontabs straight from the cron spool directory and
+// installs them with the crontab binary so the cron daemon picks them up
+type SpoolStore struct {
+	Dir    string
+	Binary *BinaryStore
+}
+
+func NewSpoolStore(dir string) *SpoolStore {
+	return &SpoolStore{Dir: dir, Binary: NewBinaryStore("crontab")}
+}
+
+func (s *SpoolStore) Read(crontabUser string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.Dir, crontabUser))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crontab file: %w", err)
+	}
+	return content, nil
+}
+
+func (s *SpoolStore) Write(crontabUser string, content []byte) error {
+	return s.Binary.Write(crontabUser, content)
+}
+
+// BinaryStore lists and installs crontabs with `crontab -l` and `crontab -`
+type BinaryStore struct {
+	Path string
+}
+
+func NewBinaryStore(path string) *BinaryStore {
+	return &BinaryStore{Path: path}
+}
+
+func (s *BinaryStore) Read(crontabUser string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Path, append(userArgs(crontabUser), "-l")...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// A user without a crontab has an empty one
+		if strings.Contains(stderr.String(), "no crontab for") {
+			return []byte{}, nil
+		}
+		return nil, fmt.Errorf("failed to list crontab: %w\nOutput: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+func (s *BinaryStore) Write(crontabUser string, content []byte) error {
+	cmd := exec.Command(s.Path, append(userArgs(crontabUser), "-")...)
+	cmd.Stdin = bytes.NewReader(content)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to load crontab: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// helper function to build the -u option, omitted for the invoking user
+func userArgs(crontabUser string) []string {
+	if crontabUser == "" {
+		return nil
+	}
+	if currentUser, err := user.Current(); err == nil && currentUser.Username == crontabUser {
+		return nil
+	}
+	return []string{"-u", crontabUser}
+}
+
+// FileStore treats a plain file as the crontab, whatever the user, so
+// crontabs kept in a config-management repository can be edited without root
+type FileStore struct {
+	Path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (s *FileStore) Read(crontabUser string) ([]byte, error) {
+	content, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crontab file: %w", err)
+	}
+	return content, nil
+}
+
+func (s *FileStore) Write(crontabUser string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(s.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	// Write next to the target and rename so readers never see half a file
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".beatify")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write crontab file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write crontab file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set crontab file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return fmt.Errorf("failed to replace crontab file: %w", err)
+	}
+	return nil
+}
+
+// StreamStore reads the crontab from an input stream and writes the result
+// to an output stream, for use as a filter in a pipeline
+type StreamStore struct {
+	In  io.Reader
+	Out io.Writer
+
+	once    sync.Once
+	content []byte
+	err     error
+	written bool
+}
+
+func NewStreamStore(in io.Reader, out io.Writer) *StreamStore {
+	return &StreamStore{In: in, Out: out}
+}
+
+func (s *StreamStore) Read(crontabUser string) ([]byte, error) {
+	// The input can only be consumed once, later reads see the same content
+	s.once.Do(func() {
+		s.content, s.err = ioutil.ReadAll(s.In)
+		if s.err != nil {
+			s.err = fmt.Errorf("failed to read crontab from input: %w", s.err)
+		}
+	})
+	return s.content, s.err
+}
+
+func (s *StreamStore) Write(crontabUser string, content []byte) error {
+	s.written = true
+	if _, err := s.Out.Write(content); err != nil {
+		return fmt.Errorf("failed to write crontab to output: %w", err)
+	}
+	return nil
+}
+
+// Function to write the crontab read to the output unchanged when no other
+// crontab was written, so an aborted run does not truncate the crontab
+// redirected to the output
+func (s *StreamStore) Close() error {
+	if s.written {
+		return nil
+	}
+	content, err := s.Read("")
+	if err != nil {
+		return err
+	}
+	return s.Write("", content)
+}
@@ -3,7 +3,18 @@ module github.com/IT-JONCTION/beatify
 go 1.20
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/robfig/cron v1.2.0
+	github.com/spf13/cobra v1.7.0
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/term v0.10.0
 	golang.org/x/time v0.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/russross/blackfriday/v2 v2.1.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
@@ -0,0 +1,37 @@
+package heartbeat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrUnauthorized is returned when the API rejects the authentication token
+var ErrUnauthorized = errors.New("the BetterUptime API rejected the authentication token")
+
+// Function to check the authentication token against the API
+func ValidateToken(authToken string) error {
+	req, err := http.NewRequest("GET", APIBaseURL+"/heartbeats?per_page=1", nil)
+	if err != nil {
+		return fmt.Errorf("Error creating HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("Error response status code: %d", resp.StatusCode)
+	}
+}
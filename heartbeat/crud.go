@@ -0,0 +1,149 @@
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrNotFound is returned when a heartbeat or heartbeat group does not exist
+var ErrNotFound = errors.New("not found")
+
+// HeartbeatRequest is the body of a heartbeat update, fields left nil are
+// not changed
+type HeartbeatRequest struct {
+	Name             *string `json:"name,omitempty"`
+	Period           *int    `json:"period,omitempty"`
+	Grace            *int    `json:"grace,omitempty"`
+	Call             *bool   `json:"call,omitempty"`
+	SMS              *bool   `json:"sms,omitempty"`
+	Email            *bool   `json:"email,omitempty"`
+	Push             *bool   `json:"push,omitempty"`
+	TeamWait         *int    `json:"team_wait,omitempty"`
+	HeartbeatGroupID *string `json:"heartbeat_group_id,omitempty"`
+	Paused           *bool   `json:"paused,omitempty"`
+}
+
+// HeartbeatGroupRequest is the body of a heartbeat group update, fields left
+// nil are not changed
+type HeartbeatGroupRequest struct {
+	Name   *string `json:"name,omitempty"`
+	Paused *bool   `json:"paused,omitempty"`
+}
+
+// Function to get a heartbeat by ID
+func GetHeartbeat(authToken string, heartbeatID string) (Heartbeat, error) {
+	var response HeartbeatResponse
+	err := sendRequest(authToken, "GET", APIBaseURL+"/heartbeats/"+heartbeatID, nil, http.StatusOK, &response)
+	return response.Data, err
+}
+
+// Function to update the given fields of a heartbeat
+func UpdateHeartbeat(authToken string, heartbeatID string, request HeartbeatRequest) (Heartbeat, error) {
+	var response HeartbeatResponse
+	err := sendRequest(authToken, "PATCH", APIBaseURL+"/heartbeats/"+heartbeatID, request, http.StatusOK, &response)
+	return response.Data, err
+}
+
+// Function to pause a heartbeat, so missed pings raise no incident
+func PauseHeartbeat(authToken string, heartbeatID string) (Heartbeat, error) {
+	paused := true
+	return UpdateHeartbeat(authToken, heartbeatID, HeartbeatRequest{Paused: &paused})
+}
+
+// Function to resume a paused heartbeat
+func ResumeHeartbeat(authToken string, heartbeatID string) (Heartbeat, error) {
+	paused := false
+	return UpdateHeartbeat(authToken, heartbeatID, HeartbeatRequest{Paused: &paused})
+}
+
+// Function to delete a heartbeat
+func DeleteHeartbeat(authToken string, heartbeatID string) error {
+	return sendRequest(authToken, "DELETE", APIBaseURL+"/heartbeats/"+heartbeatID, nil, http.StatusNoContent, nil)
+}
+
+// Function to get a heartbeat group by ID
+func GetHeartbeatGroup(authToken string, heartbeatGroupID string) (HeartbeatGroup, error) {
+	var response HeartbeatGroupResponse
+	err := sendRequest(authToken, "GET", APIBaseURL+"/heartbeat-groups/"+heartbeatGroupID, nil, http.StatusOK, &response)
+	return response.Data, err
+}
+
+// Function to update the given fields of a heartbeat group
+func UpdateHeartbeatGroup(authToken string, heartbeatGroupID string, request HeartbeatGroupRequest) (HeartbeatGroup, error) {
+	var response HeartbeatGroupResponse
+	err := sendRequest(authToken, "PATCH", APIBaseURL+"/heartbeat-groups/"+heartbeatGroupID, request, http.StatusOK, &response)
+	return response.Data, err
+}
+
+// Function to pause every heartbeat of a group
+func PauseHeartbeatGroup(authToken string, heartbeatGroupID string) (HeartbeatGroup, error) {
+	paused := true
+	return UpdateHeartbeatGroup(authToken, heartbeatGroupID, HeartbeatGroupRequest{Paused: &paused})
+}
+
+// Function to resume the heartbeats of a paused group
+func ResumeHeartbeatGroup(authToken string, heartbeatGroupID string) (HeartbeatGroup, error) {
+	paused := false
+	return UpdateHeartbeatGroup(authToken, heartbeatGroupID, HeartbeatGroupRequest{Paused: &paused})
+}
+
+// Function to delete a heartbeat group
+func DeleteHeartbeatGroup(authToken string, heartbeatGroupID string) error {
+	return sendRequest(authToken, "DELETE", APIBaseURL+"/heartbeat-groups/"+heartbeatGroupID, nil, http.StatusNoContent, nil)
+}
+
+// helper function to send a request with an optional JSON body and decode
+// the JSON response, if any, into response
+func sendRequest(authToken string, method string, url string, body interface{}, expectedStatus int, response interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("Error creating JSON request body: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return fmt.Errorf("Error creating HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s %s: %w", method, url, ErrNotFound)
+	case resp.StatusCode != expectedStatus:
+		return fmt.Errorf("Error response status code: %d", resp.StatusCode)
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response body: %w", err)
+	}
+	if err := json.Unmarshal(responseBody, response); err != nil {
+		return fmt.Errorf("Error unmarshalling response body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package heartbeat
+
+// HeartbeatDefaults holds the settings applied to every heartbeat created
+type HeartbeatDefaults struct {
+	// GraceRatio is the grace period as a fraction of the period
+	GraceRatio float64
+	// Grace is a fixed grace period in seconds, used instead of GraceRatio when set
+	Grace int
+
+	// Escalation settings, left to the API defaults when nil
+	Call     *bool
+	SMS      *bool
+	Email    *bool
+	Push     *bool
+	TeamWait *int
+
+	// SendSchedule adds the cron schedule to the heartbeats created, for
+	// receivers that evaluate it such as beatify serve
+	SendSchedule bool
+}
+
+// Defaults used by PrepareConfigJson
+var Defaults = HeartbeatDefaults{GraceRatio: 0.2}
+
+// Function to compute the grace period of a heartbeat of the given period
+// from the configured defaults
+func DefaultGrace(period int) int {
+	return Defaults.grace(period)
+}
+
+// helper function to compute the grace period of a heartbeat
+func (d HeartbeatDefaults) grace(period int) int {
+	if d.Grace > 0 {
+		return d.Grace
+	}
+	return int(float64(period) * d.GraceRatio)
+}
+
+// helper function to add the configured escalation settings to a request body
+func (d HeartbeatDefaults) applyEscalation(config map[string]interface{}) {
+	if d.Call != nil {
+		config["call"] = *d.Call
+	}
+	if d.SMS != nil {
+		config["sms"] = *d.SMS
+	}
+	if d.Email != nil {
+		config["email"] = *d.Email
+	}
+	if d.Push != nil {
+		config["push"] = *d.Push
+	}
+	if d.TeamWait != nil {
+		config["team_wait"] = *d.TeamWait
+	}
+}
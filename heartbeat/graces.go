@@ -0,0 +1,86 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// File recording the grace periods chosen for heartbeats instead of the
+// default, by heartbeat URL, defaults to ~/.beatify/graces.json
+var GraceFile = ""
+
+// Function to read the grace periods chosen for heartbeats, a missing file
+// giving none
+func RecordedGraces() (map[string]int, error) {
+	path, err := graceFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	graces := map[string]int{}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return graces, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorded graces: %w", err)
+	}
+	if err := json.Unmarshal(content, &graces); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return graces, nil
+}
+
+// Function to record the grace period chosen for a heartbeat, so sync and
+// watch keep it instead of the default of its schedule
+func RecordGrace(heartbeatURL string, grace int) error {
+	graces, err := RecordedGraces()
+	if err != nil {
+		return err
+	}
+	graces[heartbeatURL] = grace
+
+	path, err := graceFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	content, err := json.MarshalIndent(graces, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recorded graces: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".graces")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// helper function to get the path of the file recording the graces
+func graceFilePath() (string, error) {
+	if GraceFile != "" {
+		return GraceFile, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".beatify", "graces.json"), nil
+}
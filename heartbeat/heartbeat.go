@@ -7,47 +7,62 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/IT-JONCTION/beatify/crontab"
 	"github.com/robfig/cron"
 	"golang.org/x/time/rate"
 )
 
+// Base URL of the BetterUptime API
+var APIBaseURL = "https://uptime.betterstack.com/api/v2"
+
+type HeartbeatAttributes struct {
+	URL              string `json:"url"`
+	Name             string `json:"name"`
+	Period           int    `json:"period"`
+	Grace            int    `json:"grace"`
+	Call             bool   `json:"call"`
+	SMS              bool   `json:"sms"`
+	Email            bool   `json:"email"`
+	Push             bool   `json:"push"`
+	TeamWait         int    `json:"team_wait"`
+	HeartbeatGroupID int    `json:"heartbeat_group_id"`
+	SortIndex        int    `json:"sort_index"`
+	PausedAt         string `json:"paused_at"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+	Status           string `json:"status"`
+}
+
+type Heartbeat struct {
+	ID         string              `json:"id"`
+	Type       string              `json:"type"`
+	Attributes HeartbeatAttributes `json:"attributes"`
+}
+
 type HeartbeatResponse struct {
-	Data struct {
-		ID         string `json:"id"`
-		Type       string `json:"type"`
-		Attributes struct {
-			URL              string `json:"url"`
-			Name             string `json:"name"`
-			Period           int    `json:"period"`
-			Grace            int    `json:"grace"`
-			Call             bool   `json:"call"`
-			SMS              bool   `json:"sms"`
-			Email            bool   `json:"email"`
-			Push             bool   `json:"push"`
-			TeamWait         int    `json:"team_wait"`
-			HeartbeatGroupID int    `json:"heartbeat_group_id"`
-			SortIndex        int    `json:"sort_index"`
-			PausedAt         string `json:"paused_at"`
-			CreatedAt        string `json:"created_at"`
-			UpdatedAt        string `json:"updated_at"`
-		} `json:"attributes"`
-	} `json:"data"`
+	Data Heartbeat `json:"data"`
+}
+
+type HeartbeatGroupAttributes struct {
+	Name      string `json:"name"`
+	SortIndex int    `json:"sort_index"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Paused    bool   `json:"paused"`
+}
+
+type HeartbeatGroup struct {
+	ID         string                   `json:"id"`
+	Type       string                   `json:"type"`
+	Attributes HeartbeatGroupAttributes `json:"attributes"`
 }
 
 type HeartbeatGroupResponse struct {
-	Data struct {
-		ID         string `json:"id"`
-		Type       string `json:"type"`
-		Attributes struct {
-			Name      string `json:"name"`
-			SortIndex int    `json:"sort_index"`
-			CreatedAt string `json:"created_at"`
-			UpdatedAt string `json:"updated_at"`
-			Paused    bool   `json:"paused"`
-		} `json:"attributes"`
-	} `json:"data"`
+	Data HeartbeatGroup `json:"data"`
 }
 
 type Pagination struct {
@@ -57,24 +72,19 @@ type Pagination struct {
 	Next  string `json:"next"`
 }
 
+type HeartbeatsResponse struct {
+	Data       []Heartbeat `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
 type HeartbeatGroupsResponse struct {
-	Data []struct {
-		ID         string `json:"id"`
-		Type       string `json:"type"`
-		Attributes struct {
-			Name      string `json:"name"`
-			SortIndex int    `json:"sort_index"`
-			CreatedAt string `json:"created_at"`
-			UpdatedAt string `json:"updated_at"`
-			Paused    bool   `json:"paused"`
-		} `json:"attributes"`
-	} `json:"data"`
-	Pagination Pagination `json:"pagination"`
+	Data       []HeartbeatGroup `json:"data"`
+	Pagination Pagination       `json:"pagination"`
 }
 
 func GetHeartbeatGroupID(authToken string, heartbeatGroupName string) (string, error) {
 	limiter := rate.NewLimiter(1, 5) // Limit to 1 request per second, with bursts up to 5 requests.
-	url := "https://uptime.betterstack.com/api/v2/heartbeat-groups"
+	url := APIBaseURL + "/heartbeat-groups"
 
 	for {
 		req, err := http.NewRequest("GET", url, nil)
@@ -134,7 +144,7 @@ func GetHeartbeatGroupID(authToken string, heartbeatGroupName string) (string, e
 }
 
 func CreateHeartbeatGroup(authToken string, heartbeatGroupName string) (string, error) {
-	url := "https://uptime.betterstack.com/api/v2/heartbeat-groups"
+	url := APIBaseURL + "/heartbeat-groups"
 
 	// Define the data to send in the request body
 	data := map[string]string{
@@ -197,66 +207,150 @@ func extractHeartbeatGroupIDFromResponse(responseBody []byte, heartbeatGroupName
 	return "", fmt.Errorf("heartbeat group not found")
 }
 
-// Utility function to extract URL from response body
-func extractURLFromResponse(responseBody []byte) (string, error) {
+// Utility function to extract the heartbeat from response body
+func extractHeartbeatFromResponse(responseBody []byte) (Heartbeat, error) {
 	var heartbeatResp HeartbeatResponse
 	err := json.Unmarshal(responseBody, &heartbeatResp)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal Heartbeat response body: %w", err)
+		return Heartbeat{}, fmt.Errorf("failed to unmarshal Heartbeat response body: %w", err)
 	}
 
-	return heartbeatResp.Data.Attributes.URL, nil
+	return heartbeatResp.Data, nil
 }
 
-// Function to prepare config JSON
-func PrepareConfigJson(crontab, heartbeatName string, heartbeatGroupID string) (string, error) {
+// Runs of a schedule compared to find its period: the runs of eight years,
+// covering its weekly, monthly and yearly patterns and two leap days for a
+// schedule running on February 29, up to a number of runs that still covers
+// a week of a schedule running every minute
+const (
+	gapWindow = 8 * 366 * 24 * time.Hour
+	gapRuns   = 10080
+)
+
+// Function to compute the period and grace of a heartbeat from a cron schedule
+func SchedulePeriod(crontab string) (int, int, error) {
+	// cron runs the jobs of an hour skipped or repeated by a DST change once,
+	// so the period is computed without DST changes
+	period, err := LongestGap([]string{crontab}, time.UTC, time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Calculate the grace period from the configured defaults
+	grace := Defaults.grace(period)
+
+	return period, grace, nil
+}
+
+// Function to find the longest time in seconds between two runs of one or
+// more cron schedules, run in location unless they have a CRON_TZ= prefix.
+// The runs compared cover the whole pattern of the schedules, so the period
+// does not depend on the day it is computed: a schedule running on weekdays
+// gets the gap over the weekend
+func LongestGap(schedules []string, location *time.Location, from time.Time) (int, error) {
 	// Create a new cron parser
 	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
 
-	// Parse the crontab schedule
-	schedule, err := parser.Parse(crontab)
-	if err != nil {
-		return "", fmt.Errorf("Error parsing crontab schedule: %w", err)
+	end := from.Add(gapWindow)
+	var runs []time.Time
+	for _, spec := range schedules {
+		scheduleLocation := location
+		stripped, zone, err := crontab.SplitScheduleTimezone(spec)
+		if err != nil {
+			return 0, fmt.Errorf("Error parsing crontab schedule: %w", err)
+		}
+		if stripped != strings.TrimSpace(spec) {
+			spec, scheduleLocation = stripped, zone
+		}
+
+		// Parse the crontab schedule
+		schedule, err := parser.Parse(spec)
+		if err != nil {
+			return 0, fmt.Errorf("Error parsing crontab schedule: %w", err)
+		}
+
+		next := from.In(scheduleLocation)
+		var count int
+		for count = 0; count < gapRuns; count++ {
+			next = schedule.Next(next)
+			if next.IsZero() || next.After(end) {
+				break
+			}
+			runs = append(runs, next)
+		}
+		// Only the runs every schedule still covers are compared
+		if count == gapRuns && next.Before(end) {
+			end = next
+		}
 	}
 
-	// Get the next scheduled time from current time
-	nextRun := schedule.Next(time.Now())
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Before(runs[j]) })
+	var gap time.Duration
+	for i := 1; i < len(runs) && !runs[i].After(end); i++ {
+		if d := runs[i].Sub(runs[i-1]); d > gap {
+			gap = d
+		}
+	}
+	if gap == 0 {
+		return 0, fmt.Errorf("Error parsing crontab schedule: '%s' does not run twice within %d days", strings.Join(schedules, "', '"), int(gapWindow.Hours()/24))
+	}
+	return int(gap.Seconds()), nil
+}
 
-	// Get the next scheduled time from the next run
-	nextNextRun := schedule.Next(nextRun)
+// Function to prepare config JSON
+func PrepareConfigJson(crontab, heartbeatName string, heartbeatGroupID string, graceOverride int) (string, error) {
+	period, grace, err := SchedulePeriod(crontab)
+	if err != nil {
+		return "", err
+	}
 
-	// Calculate the period in seconds between two runs
-	period := int(nextNextRun.Sub(nextRun).Seconds())
+	// A grace chosen for this heartbeat, 0 included, replaces the configured
+	// default; a negative one keeps it
+	if graceOverride >= 0 {
+		grace = graceOverride
+	}
 
-	// Calculate the grace period as approximately 20% of the period
-	grace := int(float64(period) * 0.2)
+	return PreparePeriodJson(heartbeatName, heartbeatGroupID, period, grace, crontab)
+}
 
+// Function to prepare the config JSON of a heartbeat from its period and
+// grace, for jobs scheduled by something else than a crontab line; the
+// cron schedule is sent to receivers evaluating it when not empty
+func PreparePeriodJson(heartbeatName string, heartbeatGroupID string, period, grace int, schedule string) (string, error) {
 	// Create the JSON representation
-	jsonData := fmt.Sprintf(`{
-		"name": "%s",
-		"period": %d,
-		"grace": %d`,
-		heartbeatName, period, grace)
+	config := map[string]interface{}{
+		"name":   heartbeatName,
+		"period": period,
+		"grace":  grace,
+	}
 
 	// Include heartbeat_group_id if provided
 	if heartbeatGroupID != "" {
-		jsonData += fmt.Sprintf(`,
-		"heartbeat_group_id": "%s"`, heartbeatGroupID)
+		config["heartbeat_group_id"] = heartbeatGroupID
 	}
 
-	jsonData += "\n}"
+	// Include the escalation settings that were configured
+	Defaults.applyEscalation(config)
+	if Defaults.SendSchedule && schedule != "" {
+		config["schedule"] = schedule
+	}
 
-	return jsonData, nil
+	jsonData, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("Error creating JSON request body: %w", err)
+	}
+
+	return string(jsonData), nil
 }
 
 // Function to create heartbeat
-func CreateHeartbeat(authToken string, jsonData string) (string, error) {
-	url := "https://uptime.betterstack.com/api/v2/heartbeats"
+func CreateHeartbeat(authToken string, jsonData string) (Heartbeat, error) {
+	url := APIBaseURL + "/heartbeats"
 
 	// Create a new HTTP request
 	req, err := http.NewRequest("POST", url, bytes.NewBufferString(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("Error creating HTTP request: %w", err)
+		return Heartbeat{}, fmt.Errorf("Error creating HTTP request: %w", err)
 	}
 
 	// Set the Authorization header
@@ -267,25 +361,23 @@ func CreateHeartbeat(authToken string, jsonData string) (string, error) {
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Error sending HTTP request: %w", err)
+		return Heartbeat{}, fmt.Errorf("Error sending HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check the response status code
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return Heartbeat{}, ErrUnauthorized
+	}
 	if resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("Unexpected response status: %s", resp.Status)
+		return Heartbeat{}, fmt.Errorf("Unexpected response status: %s", resp.Status)
 	}
 
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("Failed to read response body: %w", err)
-	}
-
-	// Extract the URL from the response using the utility function
-	resultURL, err := extractURLFromResponse(responseBody)
-	if err != nil {
-		return "", err
+		return Heartbeat{}, fmt.Errorf("Failed to read response body: %w", err)
 	}
 
-	return resultURL, nil
+	// Extract the heartbeat from the response using the utility function
+	return extractHeartbeatFromResponse(responseBody)
 }
@@ -0,0 +1,86 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// Function to list every heartbeat of the account, following pagination
+func ListHeartbeats(authToken string) ([]Heartbeat, error) {
+	limiter := rate.NewLimiter(1, 5) // Limit to 1 request per second, with bursts up to 5 requests.
+	url := APIBaseURL + "/heartbeats"
+
+	var heartbeats []Heartbeat
+	for url != "" {
+		var response HeartbeatsResponse
+		if err := getPage(limiter, authToken, url, &response); err != nil {
+			return nil, err
+		}
+		heartbeats = append(heartbeats, response.Data...)
+		url = response.Pagination.Next
+	}
+
+	return heartbeats, nil
+}
+
+// Function to list every heartbeat group of the account, following pagination
+func ListHeartbeatGroups(authToken string) ([]HeartbeatGroup, error) {
+	limiter := rate.NewLimiter(1, 5) // Limit to 1 request per second, with bursts up to 5 requests.
+	url := APIBaseURL + "/heartbeat-groups"
+
+	var heartbeatGroups []HeartbeatGroup
+	for url != "" {
+		var response HeartbeatGroupsResponse
+		if err := getPage(limiter, authToken, url, &response); err != nil {
+			return nil, err
+		}
+		heartbeatGroups = append(heartbeatGroups, response.Data...)
+		url = response.Pagination.Next
+	}
+
+	return heartbeatGroups, nil
+}
+
+// helper function to fetch and decode a single page of a list endpoint
+func getPage(limiter *rate.Limiter, authToken string, url string, response interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("Error creating HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	err = limiter.Wait(context.Background()) // Wait for the limiter to allow us to make the request.
+	if err != nil {
+		return fmt.Errorf("Error waiting for rate limiter: %w", err)
+	}
+
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error sending HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error response status code: %d", resp.StatusCode)
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response body: %w", err)
+	}
+
+	err = json.Unmarshal(responseBody, response)
+	if err != nil {
+		return fmt.Errorf("Error unmarshalling response body: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package heartbeat
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Function to report a run to a heartbeat URL: exit code 0 is a success,
+// any other code is reported as a failure with that code
+func Ping(heartbeatURL string, exitCode int) error {
+	return PingWithOutput(heartbeatURL, exitCode, "")
+}
+
+// Function to report a run to a heartbeat URL with the output of the run as
+// the request body, which Better Stack shows on the incident of a failure
+func PingWithOutput(heartbeatURL string, exitCode int, output string) error {
+	url := strings.TrimSuffix(heartbeatURL, "/")
+	if exitCode != 0 {
+		url = fmt.Sprintf("%s/%d", url, exitCode)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+
+	// Retry like the curl command appended to the crontab does
+	var lastErr error
+	for attempt := 0; attempt < 4; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+
+		resp, err := client.Post(url, "text/plain", strings.NewReader(output))
+		if err != nil {
+			lastErr = fmt.Errorf("Error sending HTTP request: %w", err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("Unexpected response status: %s", resp.Status)
+		if resp.StatusCode < 500 {
+			break
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,571 @@
+package heartbeat_mock
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"golang.org/x/time/rate"
+)
+
+// Path prefixes of the API and of the heartbeat pings served by Server
+const (
+	apiPath  = "/api/v2"
+	pingPath = "/api/v1/heartbeat/"
+)
+
+// Server is an in-process fake of the heartbeat and heartbeat group
+// endpoints of the Better Stack API. It keeps the heartbeats and groups it
+// is sent, paginates lists, checks the authentication token, limits the
+// request rate and can be told to fail requests
+type Server struct {
+	*httptest.Server
+
+	// Token accepted as "Authorization: Bearer <Token>"
+	Token string
+	// Number of items per page of the list endpoints
+	PerPage int
+
+	mu         sync.Mutex
+	limiter    *rate.Limiter
+	random     *rand.Rand
+	nextID     int
+	heartbeats map[string]*heartbeat.Heartbeat
+	groups     map[string]*heartbeat.HeartbeatGroup
+	failures   []*Failure
+	hooks      []Hook
+	requests   []Request
+	pings      []Ping
+}
+
+// Failure makes requests matching Method and a Path prefix fail with Status
+type Failure struct {
+	Method string
+	Path   string
+	Status int
+	// Number of requests to fail, 0 fails every matching request
+	Times int
+}
+
+// Hook runs once, before the first request matching Method and a Path
+// prefix is answered, e.g. to change the host while beatify runs
+type Hook struct {
+	Method string
+	Path   string
+	Run    func()
+}
+
+// Request is a request received by the Server
+type Request struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+// Ping is a heartbeat ping received by the Server
+type Ping struct {
+	HeartbeatID string
+	ExitCode    int
+	Time        time.Time
+}
+
+// Function to start a fake API accepting a token
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:      token,
+		PerPage:    50,
+		heartbeats: map[string]*heartbeat.Heartbeat{},
+		groups:     map[string]*heartbeat.HeartbeatGroup{},
+		// Seeded so the ping URLs are the same from one run to the next
+		random: rand.New(rand.NewSource(1)),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Function to get the base URL to set as heartbeat.APIBaseURL
+func (s *Server) APIBaseURL() string {
+	return s.URL + apiPath
+}
+
+// Function to point the heartbeat package at the server, returning a
+// function restoring the previous base URL
+func (s *Server) Use() func() {
+	previous := heartbeat.APIBaseURL
+	heartbeat.APIBaseURL = s.APIBaseURL()
+	return func() { heartbeat.APIBaseURL = previous }
+}
+
+// Function to limit the requests per second, answering 429 beyond it; a
+// limit of 0 removes the limit
+func (s *Server) SetRateLimit(perSecond int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limiter = nil
+	if perSecond > 0 {
+		s.limiter = rate.NewLimiter(rate.Limit(perSecond), perSecond)
+	}
+}
+
+// Function to make the requests matching a failure fail
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure)
+}
+
+// Function to run a hook before the first request matching it
+func (s *Server) OnRequest(hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Function to add a heartbeat group as if it had been created before
+func (s *Server) AddHeartbeatGroup(name string) heartbeat.HeartbeatGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.createGroup(heartbeat.HeartbeatGroupAttributes{Name: name})
+}
+
+// Function to add a heartbeat as if it had been created before
+func (s *Server) AddHeartbeat(attributes heartbeat.HeartbeatAttributes) heartbeat.Heartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.createHeartbeat(attributes)
+}
+
+// Function to get the heartbeats held by the server, ordered by ID
+func (s *Server) Heartbeats() []heartbeat.Heartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []heartbeat.Heartbeat{}
+	for _, id := range sortedIDs(s.heartbeats) {
+		list = append(list, *s.heartbeats[id])
+	}
+	return list
+}
+
+// Function to get the heartbeat groups held by the server, ordered by ID
+func (s *Server) HeartbeatGroups() []heartbeat.HeartbeatGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []heartbeat.HeartbeatGroup{}
+	for _, id := range sortedIDs(s.groups) {
+		list = append(list, *s.groups[id])
+	}
+	return list
+}
+
+// Function to get the API requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Function to get the heartbeat pings received so far
+func (s *Server) Pings() []Ping {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Ping(nil), s.pings...)
+}
+
+// helper function to serve every request
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	if strings.HasPrefix(r.URL.Path, pingPath) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.handlePing(w, r)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, apiPath)
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Body: string(body)})
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnauthorized, "Invalid Team API token")
+		return
+	}
+	if s.limiter != nil && !s.limiter.Allow() {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+		return
+	}
+	hook := s.takeHook(r.Method, path)
+	s.mu.Unlock()
+
+	// The hook runs unlocked, so it may look at the server
+	if hook != nil {
+		hook.Run()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status := s.injectedFailure(r.Method, path); status != 0 {
+		writeError(w, status, "Injected failure")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "heartbeats":
+		s.handleHeartbeats(w, r, body)
+	case len(parts) == 2 && parts[0] == "heartbeats":
+		s.handleHeartbeat(w, r, parts[1], body)
+	case len(parts) == 1 && parts[0] == "heartbeat-groups":
+		s.handleGroups(w, r, body)
+	case len(parts) == 2 && parts[0] == "heartbeat-groups":
+		s.handleGroup(w, r, parts[1], body)
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// helper function to remove and return the first hook matching a request
+func (s *Server) takeHook(method, path string) *Hook {
+	for i, hook := range s.hooks {
+		if (hook.Method == "" || hook.Method == method) && strings.HasPrefix(path, hook.Path) {
+			s.hooks = append(s.hooks[:i], s.hooks[i+1:]...)
+			return &hook
+		}
+	}
+	return nil
+}
+
+// helper function to find the status of an injected failure for a request
+func (s *Server) injectedFailure(method, path string) int {
+	for i, failure := range s.failures {
+		if failure.Method != "" && failure.Method != method {
+			continue
+		}
+		if !strings.HasPrefix(path, failure.Path) {
+			continue
+		}
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return failure.Status
+	}
+	return 0
+}
+
+// helper function to serve the heartbeat list and creation
+func (s *Server) handleHeartbeats(w http.ResponseWriter, r *http.Request, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+		list := []heartbeat.Heartbeat{}
+		for _, id := range sortedIDs(s.heartbeats) {
+			list = append(list, *s.heartbeats[id])
+		}
+		page, pagination := s.paginate(r, len(list))
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatsResponse{Data: list[page[0]:page[1]], Pagination: pagination})
+	case http.MethodPost:
+		var request struct {
+			heartbeat.HeartbeatAttributes
+			HeartbeatGroupID string `json:"heartbeat_group_id"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if request.Name == "" || request.Period <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, "name and period are required")
+			return
+		}
+		attributes := request.HeartbeatAttributes
+		if request.HeartbeatGroupID != "" {
+			if _, ok := s.groups[request.HeartbeatGroupID]; !ok {
+				writeError(w, http.StatusUnprocessableEntity, "unknown heartbeat group")
+				return
+			}
+			attributes.HeartbeatGroupID, _ = strconv.Atoi(request.HeartbeatGroupID)
+		}
+		writeJSON(w, http.StatusCreated, heartbeat.HeartbeatResponse{Data: *s.createHeartbeat(attributes)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to serve a single heartbeat
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request, id string, body []byte) {
+	hb, ok := s.heartbeats[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Heartbeat not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatResponse{Data: *hb})
+	case http.MethodPatch:
+		var request heartbeat.HeartbeatRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		applyHeartbeatRequest(&hb.Attributes, request)
+		hb.Attributes.UpdatedAt = now()
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatResponse{Data: *hb})
+	case http.MethodDelete:
+		delete(s.heartbeats, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to serve the heartbeat group list and creation
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+		list := []heartbeat.HeartbeatGroup{}
+		for _, id := range sortedIDs(s.groups) {
+			list = append(list, *s.groups[id])
+		}
+		page, pagination := s.paginate(r, len(list))
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupsResponse{Data: list[page[0]:page[1]], Pagination: pagination})
+	case http.MethodPost:
+		var attributes heartbeat.HeartbeatGroupAttributes
+		if err := json.Unmarshal(body, &attributes); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if attributes.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "name is required")
+			return
+		}
+		writeJSON(w, http.StatusCreated, heartbeat.HeartbeatGroupResponse{Data: *s.createGroup(attributes)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to serve a single heartbeat group
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request, id string, body []byte) {
+	group, ok := s.groups[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Heartbeat group not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupResponse{Data: *group})
+	case http.MethodPatch:
+		var request heartbeat.HeartbeatGroupRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if request.Name != nil {
+			group.Attributes.Name = *request.Name
+		}
+		if request.Paused != nil {
+			group.Attributes.Paused = *request.Paused
+		}
+		group.Attributes.UpdatedAt = now()
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupResponse{Data: *group})
+	case http.MethodDelete:
+		// The heartbeats of a deleted group go with it
+		groupID, _ := strconv.Atoi(id)
+		for heartbeatID, hb := range s.heartbeats {
+			if hb.Attributes.HeartbeatGroupID == groupID {
+				delete(s.heartbeats, heartbeatID)
+			}
+		}
+		delete(s.groups, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to record a heartbeat ping and update the heartbeat status
+func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	token, code, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, pingPath), "/")
+	exitCode, _ := strconv.Atoi(code)
+
+	for _, hb := range s.heartbeats {
+		if strings.HasSuffix(hb.Attributes.URL, pingPath+token) {
+			hb.Attributes.Status = "up"
+			if exitCode != 0 || code == "fail" {
+				hb.Attributes.Status = "down"
+			}
+			s.pings = append(s.pings, Ping{HeartbeatID: hb.ID, ExitCode: exitCode, Time: time.Now()})
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Heartbeat not found")
+}
+
+// helper function to select the page of a list request, returning the start
+// and end indexes of the page and the pagination links
+func (s *Server) paginate(r *http.Request, total int) ([2]int, heartbeat.Pagination) {
+	perPage := s.PerPage
+	if value, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && value > 0 {
+		perPage = value
+	}
+	page := 1
+	if value, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && value > 0 {
+		page = value
+	}
+	pages := (total + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+
+	link := func(n int) string {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(n))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return s.URL + r.URL.Path + "?" + query.Encode()
+	}
+	pagination := heartbeat.Pagination{First: link(1), Last: link(pages)}
+	if page > 1 {
+		pagination.Prev = link(page - 1)
+	}
+	if page < pages {
+		pagination.Next = link(page + 1)
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return [2]int{start, end}, pagination
+}
+
+// helper function to store a new heartbeat with a fresh ID and ping URL
+func (s *Server) createHeartbeat(attributes heartbeat.HeartbeatAttributes) *heartbeat.Heartbeat {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+
+	token := make([]byte, 12)
+	s.random.Read(token)
+	attributes.URL = s.URL + pingPath + hex.EncodeToString(token)
+	attributes.CreatedAt, attributes.UpdatedAt = now(), now()
+	if attributes.Status == "" {
+		attributes.Status = "pending"
+	}
+
+	hb := &heartbeat.Heartbeat{ID: id, Type: "heartbeat", Attributes: attributes}
+	s.heartbeats[id] = hb
+	return hb
+}
+
+// helper function to store a new heartbeat group with a fresh ID
+func (s *Server) createGroup(attributes heartbeat.HeartbeatGroupAttributes) *heartbeat.HeartbeatGroup {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	attributes.CreatedAt, attributes.UpdatedAt = now(), now()
+
+	group := &heartbeat.HeartbeatGroup{ID: id, Type: "heartbeat_group", Attributes: attributes}
+	s.groups[id] = group
+	return group
+}
+
+// helper function to apply the fields set in an update request
+func applyHeartbeatRequest(attributes *heartbeat.HeartbeatAttributes, request heartbeat.HeartbeatRequest) {
+	if request.Name != nil {
+		attributes.Name = *request.Name
+	}
+	if request.Period != nil {
+		attributes.Period = *request.Period
+	}
+	if request.Grace != nil {
+		attributes.Grace = *request.Grace
+	}
+	if request.Call != nil {
+		attributes.Call = *request.Call
+	}
+	if request.SMS != nil {
+		attributes.SMS = *request.SMS
+	}
+	if request.Email != nil {
+		attributes.Email = *request.Email
+	}
+	if request.Push != nil {
+		attributes.Push = *request.Push
+	}
+	if request.TeamWait != nil {
+		attributes.TeamWait = *request.TeamWait
+	}
+	if request.HeartbeatGroupID != nil {
+		attributes.HeartbeatGroupID, _ = strconv.Atoi(*request.HeartbeatGroupID)
+	}
+	if request.Paused != nil {
+		attributes.PausedAt = ""
+		attributes.Status = "up"
+		if *request.Paused {
+			attributes.PausedAt = now()
+			attributes.Status = "paused"
+		}
+	}
+}
+
+// helper function to list the IDs of a map in numeric order
+func sortedIDs[T any](items map[string]T) []string {
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+// helper function to write a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// helper function to write an error response in the shape of the API
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"errors": message})
+}
+
+// helper function to format the current time as the API does
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// Function to describe the state of the server, for logs and golden files
+func (s *Server) String() string {
+	var b strings.Builder
+	for _, group := range s.HeartbeatGroups() {
+		fmt.Fprintf(&b, "group %s %q paused=%t\n", group.ID, group.Attributes.Name, group.Attributes.Paused)
+	}
+	for _, hb := range s.Heartbeats() {
+		fmt.Fprintf(&b, "heartbeat %s %q period=%d grace=%d group=%d status=%s\n",
+			hb.ID, hb.Attributes.Name, hb.Attributes.Period, hb.Attributes.Grace, hb.Attributes.HeartbeatGroupID, hb.Attributes.Status)
+	}
+	return b.String()
+}
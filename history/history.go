@@ -0,0 +1,167 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory keeping the runs of each job, defaults to ~/.beatify/history
+var Dir = ""
+
+// Retention of the runs of a job: the number of runs kept, and their age.
+// Zero keeps every run
+var (
+	KeepRuns = 50
+	KeepAge  time.Duration
+)
+
+// Run is one run of a job recorded by exec
+type Run struct {
+	ID       string        `json:"id"`
+	Job      string        `json:"job"`
+	User     string        `json:"user"`
+	Command  []string      `json:"command"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exit_code"`
+	// Last bytes printed by the run on stdout and stderr, in order
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Format of the run IDs, sorting in start order
+const runIDFormat = "20060102T150405.000000000Z"
+
+// Characters replaced in the directory names of a job
+var unsafeNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Function to check a job name can name a history directory: once its
+// unsafe characters are replaced, it must not be empty, "." or ".."
+func ValidateJobName(job string) error {
+	switch unsafeNameRegexp.ReplaceAllString(job, "_") {
+	case "", ".", "..":
+		return fmt.Errorf("invalid job name '%s'", job)
+	}
+	return nil
+}
+
+// Helper function to get the history directory of a job
+func jobDir(job string) (string, error) {
+	if err := ValidateJobName(job); err != nil {
+		return "", err
+	}
+	dir := Dir
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		dir = filepath.Join(homeDir, ".beatify", "history")
+	}
+	return filepath.Join(dir, unsafeNameRegexp.ReplaceAllString(job, "_")), nil
+}
+
+// Function to record a run, then remove the runs of its job beyond the
+// retention
+func Record(run *Run) error {
+	dir, err := jobDir(run.Job)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	run.ID = run.Start.UTC().Format(runIDFormat)
+	content, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run: %w", err)
+	}
+	// The output may hold secrets, only the user reads it
+	tmp, err := ioutil.TempFile(dir, ".run")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write run: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write run: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, run.ID+".json")); err != nil {
+		return fmt.Errorf("failed to write run: %w", err)
+	}
+
+	return prune(dir, run.End)
+}
+
+// helper function to remove the oldest runs beyond the number of runs kept
+// and the runs older than the age kept
+func prune(dir string, now time.Time) error {
+	paths, err := runFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for i, path := range paths {
+		// Runs are sorted oldest first
+		tooMany := KeepRuns > 0 && i < len(paths)-KeepRuns
+		tooOld := false
+		if KeepAge > 0 {
+			start, err := time.Parse(runIDFormat, strings.TrimSuffix(filepath.Base(path), ".json"))
+			tooOld = err == nil && now.Sub(start) > KeepAge
+		}
+		if tooMany || tooOld {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove old run: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Function to list the runs of a job, oldest first
+func Runs(job string) ([]Run, error) {
+	dir, err := jobDir(job)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := runFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []Run{}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		var run Run
+		if err := json.Unmarshal(content, &run); err != nil {
+			return nil, fmt.Errorf("failed to parse run %s: %w", path, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// helper function to list the run files of a job directory, oldest first
+func runFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,45 @@
+package history
+
+import "sync"
+
+// Tail keeps the last bytes written to it, for the stdout and stderr of a
+// run sharing one buffer
+type Tail struct {
+	Limit int
+
+	mu        sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+// Function to create a tail keeping the last limit bytes
+func NewTail(limit int) *Tail {
+	return &Tail{Limit: limit}
+}
+
+// Function to append bytes, dropping the oldest beyond the limit
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if t.Limit > 0 && len(t.buf) > t.Limit {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.Limit:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+// Function to get the bytes kept
+func (t *Tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// Function to tell bytes were dropped
+func (t *Tail) Truncated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.truncated
+}
@@ -0,0 +1,180 @@
+package kube
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"gopkg.in/yaml.v3"
+)
+
+// Annotation of the CronJob naming the heartbeat its job pings
+const HeartbeatAnnotation = "beatify/heartbeat-url"
+
+// Environment variable of the container holding the heartbeat URL
+const HeartbeatEnv = "BEATIFY_HEARTBEAT_URL"
+
+// Name of the container to instrument, the first container when empty
+var ContainerName = ""
+
+// Timezone of the CronJobs without spec.timeZone or a CRON_TZ= prefix, the
+// timezone of the kube-controller-manager, UTC on most clusters
+var DefaultTimeZone = "UTC"
+
+// Shell script wrapping the container command: it runs the command given
+// as its arguments, pings the heartbeat with the exit code and exits with
+// that code
+const wrapperScript = `"$@"; code=$?; url="$` + HeartbeatEnv + `"; ` +
+	`[ "$code" -eq 0 ] || url="$url/$code"; ` +
+	`curl -fs --retry 3 "$url" > /dev/null 2>&1; exit "$code"`
+
+// CronJob is a Kubernetes CronJob read from a manifest
+type CronJob struct {
+	Path      string `json:"path"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Schedule  string `json:"schedule"`
+	TimeZone  string `json:"time_zone,omitempty"`
+	Container string `json:"container"`
+
+	// Heartbeat named by the annotation of the CronJob, empty when not monitored
+	HeartbeatURL string `json:"heartbeat_url,omitempty"`
+
+	node *yaml.Node
+}
+
+// helper function to read the fields of a CronJob document
+func readCronJob(root *yaml.Node) (*CronJob, error) {
+	cronJob := &CronJob{
+		Namespace:    scalarValue(root, "metadata", "namespace"),
+		Name:         scalarValue(root, "metadata", "name"),
+		Schedule:     scalarValue(root, "spec", "schedule"),
+		TimeZone:     scalarValue(root, "spec", "timeZone"),
+		HeartbeatURL: scalarValue(root, "metadata", "annotations", HeartbeatAnnotation),
+		node:         root,
+	}
+	if cronJob.Name == "" {
+		return nil, fmt.Errorf("CronJob without metadata.name")
+	}
+	if cronJob.Namespace == "" {
+		cronJob.Namespace = "default"
+	}
+	if cronJob.Schedule == "" {
+		return nil, fmt.Errorf("CronJob %s without spec.schedule", cronJob.Name)
+	}
+	if container, err := cronJob.container(); err == nil {
+		cronJob.Container = scalarValue(container, "name")
+	}
+	return cronJob, nil
+}
+
+// Function to get the five-field cron schedule of a CronJob and the
+// timezone it runs in, from spec.timeZone or a CRON_TZ= prefix of the
+// schedule, DefaultTimeZone otherwise
+func (c *CronJob) Spec() (string, *time.Location, error) {
+	spec, location, err := crontab.SplitScheduleTimezone(c.Schedule)
+	if err != nil {
+		return "", nil, err
+	}
+	if location == time.Local {
+		// The cluster does not run in the timezone of this host
+		if location, err = time.LoadLocation(DefaultTimeZone); err != nil {
+			return "", nil, fmt.Errorf("unknown timezone '%s': %w", DefaultTimeZone, err)
+		}
+	}
+	spec = crontab.ExpandScheduleMacro(spec)
+	if c.TimeZone != "" {
+		if location, err = time.LoadLocation(c.TimeZone); err != nil {
+			return "", nil, fmt.Errorf("unknown timezone '%s': %w", c.TimeZone, err)
+		}
+	}
+	return spec, location, nil
+}
+
+// Function to check that a CronJob has a container to instrument
+func (c *CronJob) Validate() error {
+	_, err := c.container()
+	return err
+}
+
+// Function to compute the period and grace of the heartbeat of a CronJob in
+// the timezone it runs in, and its schedule for the heartbeat, with a
+// CRON_TZ= prefix naming that timezone
+func (c *CronJob) Period() (period int, grace int, spec string, err error) {
+	spec, location, err := c.Spec()
+	if err != nil {
+		return 0, 0, "", err
+	}
+	// Kubernetes skips the runs at a time a DST change skips, the period
+	// covers them
+	if period, err = heartbeat.LongestGap([]string{spec}, location, time.Now()); err != nil {
+		return 0, 0, "", err
+	}
+	return period, heartbeat.DefaultGrace(period), "CRON_TZ=" + location.String() + " " + spec, nil
+}
+
+// Function to make the job of a CronJob ping a heartbeat: the URL goes in
+// an annotation and in the environment of the container and, when wrap is
+// set and the container has a command, the command is wrapped in a shell
+// pinging the URL with its exit code. It returns whether the command was
+// wrapped; without it the image itself has to ping the URL
+func (c *CronJob) Instrument(heartbeatURL string, wrap bool) (bool, error) {
+	container, err := c.container()
+	if err != nil {
+		return false, err
+	}
+
+	annotations := ensureMapping(ensureMapping(c.node, "metadata"), "annotations")
+	setValue(annotations, HeartbeatAnnotation, scalarNode(heartbeatURL))
+	c.HeartbeatURL = heartbeatURL
+
+	setEnv(container, HeartbeatEnv, heartbeatURL)
+
+	command := sequenceValues(mappingValue(container, "command"))
+	if !wrap || len(command) == 0 {
+		return false, nil
+	}
+	args := append(command, sequenceValues(mappingValue(container, "args"))...)
+	setValue(container, "command", sequenceNode([]string{"/bin/sh", "-c", wrapperScript, "beatify"}))
+	setValue(container, "args", sequenceNode(args))
+	return true, nil
+}
+
+// helper function to find the container to instrument in the pod template
+// of the job
+func (c *CronJob) container() (*yaml.Node, error) {
+	containers := lookup(c.node, "spec", "jobTemplate", "spec", "template", "spec", "containers")
+	if containers == nil || containers.Kind != yaml.SequenceNode || len(containers.Content) == 0 {
+		return nil, fmt.Errorf("CronJob %s has no container", c.Name)
+	}
+	if ContainerName == "" {
+		return containers.Content[0], nil
+	}
+	for _, container := range containers.Content {
+		if scalarValue(container, "name") == ContainerName {
+			return container, nil
+		}
+	}
+	return nil, fmt.Errorf("CronJob %s has no container named %s", c.Name, ContainerName)
+}
+
+// helper function to set an environment variable of a container, replacing
+// its value when already set
+func setEnv(container *yaml.Node, name, value string) {
+	env := mappingValue(container, "env")
+	if env == nil || env.Kind != yaml.SequenceNode {
+		env = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		setValue(container, "env", env)
+	}
+	for _, variable := range env.Content {
+		if scalarValue(variable, "name") == name {
+			setValue(variable, "value", scalarNode(value))
+			return
+		}
+	}
+	variable := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	setValue(variable, "name", scalarNode(name))
+	setValue(variable, "value", scalarNode(value))
+	env.Content = append(env.Content, variable)
+}
@@ -0,0 +1,190 @@
+package kube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is a file of YAML documents, kept as nodes so the documents
+// beatify does not change are written back as they were read
+type Manifest struct {
+	Path      string
+	Documents []*yaml.Node
+}
+
+// Function to read the documents of a manifest, path naming it in errors
+// and reports
+func ReadManifest(path string, r io.Reader) (*Manifest, error) {
+	manifest := &Manifest{Path: path}
+	decoder := yaml.NewDecoder(r)
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		// A document holding nothing but a separator or comments has no content
+		if len(document.Content) == 0 {
+			continue
+		}
+		manifest.Documents = append(manifest.Documents, &document)
+	}
+	return manifest, nil
+}
+
+// Function to list the CronJobs of a manifest, in document order
+func (m *Manifest) CronJobs() ([]*CronJob, error) {
+	var cronJobs []*CronJob
+	for i, document := range m.Documents {
+		root := document.Content[0]
+		if scalarValue(root, "kind") != "CronJob" {
+			continue
+		}
+		cronJob, err := readCronJob(root)
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", m.Path, i+1, err)
+		}
+		cronJob.Path = m.Path
+		cronJobs = append(cronJobs, cronJob)
+	}
+	return cronJobs, nil
+}
+
+// Function to write the documents of a manifest, separated by ---
+func (m *Manifest) Encode(w io.Writer) error {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	for _, document := range m.Documents {
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", m.Path, err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", m.Path, err)
+	}
+	if _, err := w.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", m.Path, err)
+	}
+	return nil
+}
+
+// Function to write the documents of a manifest back to its file in one
+// step, keeping the file mode
+func (m *Manifest) WriteFile() error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(m.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(m.Path), "."+filepath.Base(m.Path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := m.Encode(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", m.Path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.Path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", m.Path, err)
+	}
+	return nil
+}
+
+// helper function to get the value node of a key of a mapping, nil when
+// the key is missing or the node is not a mapping
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// helper function to follow a path of keys through nested mappings
+func lookup(node *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		node = mappingValue(node, key)
+	}
+	return node
+}
+
+// helper function to get the scalar value of a key of a mapping, empty when
+// missing
+func scalarValue(node *yaml.Node, keys ...string) string {
+	value := lookup(node, keys...)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// helper function to set a key of a mapping, adding the key when missing
+func setValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, scalarNode(key), value)
+}
+
+// helper function to get the mapping of a key, adding an empty one when
+// missing
+func ensureMapping(node *yaml.Node, key string) *yaml.Node {
+	value := mappingValue(node, key)
+	if value == nil || value.Kind != yaml.MappingNode {
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setValue(node, key, value)
+	}
+	return value
+}
+
+// helper function to build a string node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// helper function to build a sequence of string nodes
+func sequenceNode(values []string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, value := range values {
+		node.Content = append(node.Content, scalarNode(value))
+	}
+	return node
+}
+
+// helper function to get the string values of a sequence node
+func sequenceValues(node *yaml.Node) []string {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	values := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		values = append(values, item.Value)
+	}
+	return values
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory keeping the state of each job, defaults to ~/.beatify/metrics
+var StateDir = ""
+
+// Run is one run of a cron job reported by exec or ping
+type Run struct {
+	Job      string
+	User     string
+	ExitCode int
+	// End of the run, and its duration when known
+	Time     time.Time
+	Duration time.Duration
+	// Expected period between two runs in seconds, 0 when unknown
+	Period int
+}
+
+// JobState holds the values exposed for a job, kept between runs
+type JobState struct {
+	Job          string    `json:"job"`
+	User         string    `json:"user"`
+	LastRun      time.Time `json:"last_run"`
+	LastSuccess  time.Time `json:"last_success"`
+	LastExitCode int       `json:"last_exit_code"`
+	Duration     float64   `json:"duration_seconds"`
+	Period       int       `json:"expected_period_seconds"`
+}
+
+// Characters replaced in the file names of a job
+var unsafeNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Helper function to get the state directory
+func stateDir() (string, error) {
+	if StateDir != "" {
+		return StateDir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".beatify", "metrics"), nil
+}
+
+// helper function to name the files of a job. The readable part replaces
+// unsafe characters, so a hash of the user and job keeps apart jobs such as
+// "backup db" and "backup/db"
+func fileName(user, job, extension string) string {
+	sum := sha256.Sum256([]byte(user + "\x00" + job))
+	return fmt.Sprintf("beatify_%s_%s_%s%s",
+		unsafeNameRegexp.ReplaceAllString(user, "_"),
+		unsafeNameRegexp.ReplaceAllString(job, "_"),
+		hex.EncodeToString(sum[:6]),
+		extension)
+}
+
+// Function to update the state of a job with a run
+func Record(run Run) (JobState, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return JobState{}, err
+	}
+	path := filepath.Join(dir, fileName(run.User, run.Job, ".json"))
+
+	state := JobState{Job: run.Job, User: run.User}
+	if content, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(content, &state)
+	}
+
+	state.LastRun = run.Time
+	state.LastExitCode = run.ExitCode
+	if run.ExitCode == 0 {
+		state.LastSuccess = run.Time
+	}
+	if run.Duration > 0 {
+		state.Duration = run.Duration.Seconds()
+	}
+	if run.Period > 0 {
+		state.Period = run.Period
+	}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return JobState{}, fmt.Errorf("failed to encode job state: %w", err)
+	}
+	if err := writeFile(path, content); err != nil {
+		return JobState{}, err
+	}
+	return state, nil
+}
+
+// Function to read the state of every job
+func Jobs() ([]JobState, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "beatify_*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	states := []JobState{}
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job state: %w", err)
+		}
+		var state JobState
+		if err := json.Unmarshal(content, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse job state %s: %w", path, err)
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].User != states[j].User {
+			return states[i].User < states[j].User
+		}
+		return states[i].Job < states[j].Job
+	})
+	return states, nil
+}
+
+// Metrics exposed for each job, in the Prometheus text format
+var metricFamilies = []struct {
+	Name  string
+	Help  string
+	Value func(JobState) (float64, bool)
+}{
+	{"cron_job_last_success_timestamp", "Unix time of the last successful run of the cron job.", func(s JobState) (float64, bool) {
+		return float64(s.LastSuccess.Unix()), !s.LastSuccess.IsZero()
+	}},
+	{"cron_job_last_exit_code", "Exit code of the last run of the cron job.", func(s JobState) (float64, bool) {
+		return float64(s.LastExitCode), !s.LastRun.IsZero()
+	}},
+	{"cron_job_duration_seconds", "Duration of the last run of the cron job.", func(s JobState) (float64, bool) {
+		return s.Duration, s.Duration > 0
+	}},
+	{"cron_job_expected_period_seconds", "Expected time between two runs of the cron job, from its schedule.", func(s JobState) (float64, bool) {
+		return float64(s.Period), s.Period > 0
+	}},
+}
+
+// Function to write the metrics of jobs in the Prometheus text format
+func Write(w io.Writer, states []JobState) error {
+	var b strings.Builder
+	for _, family := range metricFamilies {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", family.Name, family.Help, family.Name)
+		for _, state := range states {
+			if value, ok := family.Value(state); ok {
+				fmt.Fprintf(&b, "%s{job_name=%s,user=%s} %s\n", family.Name, quoteLabel(state.Job), quoteLabel(state.User), formatValue(value))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Function to write the metrics of a job to a file read by the textfile
+// collector of the node exporter
+func WriteTextfile(dir string, state JobState) error {
+	var b strings.Builder
+	if err := Write(&b, []JobState{state}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create textfile directory: %w", err)
+	}
+	return writeFile(filepath.Join(dir, fileName(state.User, state.Job, ".prom")), []byte(b.String()))
+}
+
+// Function to get a handler serving the metrics of every job
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		states, err := Jobs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w, states)
+	})
+}
+
+// helper function to quote a label value
+func quoteLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// helper function to format a sample value without exponent for integers
+func formatValue(value float64) string {
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// helper function to write a file in one step, so the collector never
+// reads half of it
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
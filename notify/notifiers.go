@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/config"
+)
+
+// Time given to a mail server, a webhook or a script to take an alert
+var Timeout = 30 * time.Second
+
+// SMTPNotifier mails the alerts
+type SMTPNotifier struct {
+	Server   string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+// Function to build an SMTP notifier, resolving its password reference
+func NewSMTPNotifier(settings config.NotifierConfig) (*SMTPNotifier, error) {
+	if settings.Server == "" || settings.From == "" || len(settings.To) == 0 {
+		return nil, fmt.Errorf("the smtp type needs a server, from and to")
+	}
+
+	notifier := &SMTPNotifier{
+		Server:   settings.Server,
+		From:     settings.From,
+		To:       settings.To,
+		Username: settings.Username,
+	}
+	if settings.Password != "" {
+		password, err := config.ResolveTokenReference(settings.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the smtp password: %w", err)
+		}
+		notifier.Password = password
+	}
+	return notifier, nil
+}
+
+func (n *SMTPNotifier) Notify(alert Alert) error {
+	host, _, err := net.SplitHostPort(n.Server)
+	if err != nil {
+		return fmt.Errorf("invalid smtp server '%s': %w", n.Server, err)
+	}
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&message, "Subject: [beatify] %s\r\n", alert.Message())
+	fmt.Fprintf(&message, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&message, "%s\r\n\r\n", alert.Message())
+	fmt.Fprintf(&message, "Heartbeat: %s (%s)\r\nEvent:     %s\r\nIncident:  %s, started %s\r\n",
+		alert.Check, alert.CheckID, alert.Event, alert.IncidentID, alert.StartedAt.Format(time.RFC3339))
+	if alert.ExitCode != 0 {
+		fmt.Fprintf(&message, "Exit code: %d\r\n", alert.ExitCode)
+	}
+
+	if err := n.send(host, auth, message.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// helper function to send a mail as smtp.SendMail does, within the timeout
+// so a mail server that hangs does not hold the alerts up
+func (n *SMTPNotifier) send(host string, auth smtp.Auth, message []byte) error {
+	conn, err := net.DialTimeout("tcp", n.Server, Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(Timeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("the smtp server does not support authentication")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// WebhookNotifier posts the alerts as JSON
+type WebhookNotifier struct {
+	URL string
+}
+
+func (n *WebhookNotifier) Notify(alert Alert) error {
+	body := struct {
+		Alert
+		Message string `json:"message"`
+	}{alert, alert.Message()}
+	return postJSON(n.URL, body)
+}
+
+// SlackNotifier posts the alerts to a Slack or Mattermost incoming webhook
+type SlackNotifier struct {
+	URL string
+}
+
+func (n *SlackNotifier) Notify(alert Alert) error {
+	icon := ":red_circle:"
+	if alert.Event == EventRecovered {
+		icon = ":large_green_circle:"
+	}
+	return postJSON(n.URL, map[string]string{"text": icon + " " + alert.Message()})
+}
+
+// ScriptNotifier runs a command with sh for each alert, with the alert as
+// JSON on its input and in BEATIFY_* environment variables
+type ScriptNotifier struct {
+	Command string
+}
+
+func (n *ScriptNotifier) Notify(alert Alert) error {
+	input, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"BEATIFY_EVENT="+alert.Event,
+		"BEATIFY_CHECK="+alert.Check,
+		"BEATIFY_CHECK_ID="+alert.CheckID,
+		"BEATIFY_INCIDENT="+alert.IncidentID,
+		"BEATIFY_EXIT_CODE="+strconv.Itoa(alert.ExitCode),
+		"BEATIFY_REMINDER="+strconv.FormatBool(alert.Reminder),
+		"BEATIFY_MESSAGE="+alert.Message(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("script failed: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// helper function to post a JSON body and expect a 2xx status
+func postJSON(url string, body interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("Error creating JSON request body: %w", err)
+	}
+
+	client := http.Client{Timeout: Timeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("Error sending HTTP request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/config"
+)
+
+// Events an alert reports
+const (
+	EventDown      = "down"
+	EventFailed    = "failed"
+	EventRecovered = "recovered"
+)
+
+// Alert is an incident of a heartbeat raised or resolved by the receiver
+type Alert struct {
+	Event      string    `json:"event"`
+	CheckID    string    `json:"check_id"`
+	Check      string    `json:"check"`
+	IncidentID string    `json:"incident_id"`
+	ExitCode   int       `json:"exit_code,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Time       time.Time `json:"time"`
+	// Set on the reminders of an incident that is still open
+	Reminder bool `json:"reminder,omitempty"`
+}
+
+// Function to describe an alert in one line
+func (a Alert) Message() string {
+	var message string
+	switch a.Event {
+	case EventDown:
+		message = fmt.Sprintf("%s is down: it missed its run", a.Check)
+	case EventFailed:
+		message = fmt.Sprintf("%s is down: its run failed with exit code %d", a.Check, a.ExitCode)
+	case EventRecovered:
+		message = fmt.Sprintf("%s recovered after %s", a.Check, a.Time.Sub(a.StartedAt).Round(time.Second))
+	default:
+		message = fmt.Sprintf("%s: %s", a.Check, a.Event)
+	}
+	if a.Reminder {
+		message = fmt.Sprintf("Reminder: %s, since %s", message, a.StartedAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (incident %s)", message, a.IncidentID)
+}
+
+// Notifier delivers alerts to one destination
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// Dispatcher routes the alerts to the notifiers, drops repeated alerts and
+// reminds of the incidents left open
+type Dispatcher struct {
+	Notifiers map[string]Notifier
+	Routes    []config.Route
+	// Interval between reminders of an open incident, none when zero
+	Reminder time.Duration
+	// Window in which an alert repeating an event of a heartbeat is dropped
+	Dedup time.Duration
+
+	mu   sync.Mutex
+	open map[string]*openIncident
+	sent map[string]time.Time
+}
+
+// Incident still open, with the time of its last alert
+type openIncident struct {
+	Alert    Alert
+	Notified time.Time
+}
+
+// Function to build the dispatcher of the alerting settings
+func NewDispatcher(alerting config.Alerting) (*Dispatcher, error) {
+	reminder, dedup, err := alerting.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers := map[string]Notifier{}
+	for name, settings := range alerting.Notifiers {
+		notifier, err := newNotifier(settings)
+		if err != nil {
+			return nil, fmt.Errorf("notifier '%s': %w", name, err)
+		}
+		notifiers[name] = notifier
+	}
+
+	return &Dispatcher{
+		Notifiers: notifiers,
+		Routes:    alerting.Routes,
+		Reminder:  reminder,
+		Dedup:     dedup,
+	}, nil
+}
+
+// helper function to build a notifier from its settings
+func newNotifier(settings config.NotifierConfig) (Notifier, error) {
+	switch settings.Type {
+	case "smtp":
+		return NewSMTPNotifier(settings)
+	case "webhook":
+		if settings.URL == "" {
+			return nil, fmt.Errorf("the webhook type needs a url")
+		}
+		return &WebhookNotifier{URL: settings.URL}, nil
+	case "slack":
+		if settings.URL == "" {
+			return nil, fmt.Errorf("the slack type needs a url")
+		}
+		return &SlackNotifier{URL: settings.URL}, nil
+	case "script":
+		if settings.Command == "" {
+			return nil, fmt.Errorf("the script type needs a command")
+		}
+		return &ScriptNotifier{Command: settings.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown type '%s'", settings.Type)
+}
+
+// Function to send an alert to the notifiers of its routes, returning the
+// errors of the notifiers that failed
+func (d *Dispatcher) Dispatch(alert Alert) []error {
+	d.mu.Lock()
+	if d.open == nil {
+		d.open = map[string]*openIncident{}
+		d.sent = map[string]time.Time{}
+	}
+
+	// A heartbeat flapping between two states alerts once per event and window
+	key := alert.CheckID + "/" + alert.Event
+	last, ok := d.sent[key]
+	duplicate := ok && alert.Time.Sub(last) < d.Dedup
+	if !duplicate {
+		d.sent[key] = alert.Time
+	}
+
+	if alert.Event == EventRecovered {
+		delete(d.open, alert.IncidentID)
+	} else {
+		d.open[alert.IncidentID] = &openIncident{Alert: alert, Notified: alert.Time}
+	}
+	d.mu.Unlock()
+
+	if duplicate {
+		return nil
+	}
+	return d.send(alert)
+}
+
+// Function to remind of an incident opened before the dispatcher started,
+// without alerting again
+func (d *Dispatcher) Track(alert Alert, notified time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.open == nil {
+		d.open = map[string]*openIncident{}
+		d.sent = map[string]time.Time{}
+	}
+	d.open[alert.IncidentID] = &openIncident{Alert: alert, Notified: notified}
+}
+
+// Function to stop reminding of an incident closed without an alert, such
+// as the incident of a deleted heartbeat
+func (d *Dispatcher) Forget(incidentID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.open, incidentID)
+}
+
+// Function to send a reminder of every incident open for longer than the
+// reminder interval since its last alert
+func (d *Dispatcher) Remind(now time.Time) []error {
+	if d.Reminder <= 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	var reminders []Alert
+	for _, incident := range d.open {
+		if now.Sub(incident.Notified) >= d.Reminder {
+			incident.Notified = now
+			reminder := incident.Alert
+			reminder.Reminder = true
+			reminder.Time = now
+			reminders = append(reminders, reminder)
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].StartedAt.Before(reminders[j].StartedAt) })
+	var errs []error
+	for _, reminder := range reminders {
+		errs = append(errs, d.send(reminder)...)
+	}
+	return errs
+}
+
+// helper function to send an alert to each notifier of its routes once
+func (d *Dispatcher) send(alert Alert) []error {
+	var errs []error
+	for _, name := range d.route(alert) {
+		if err := d.Notifiers[name].Notify(alert); err != nil {
+			errs = append(errs, fmt.Errorf("Error notifying %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// helper function to list the notifiers an alert goes to: those of every route
+// matching its heartbeat and event, or every notifier without routes
+func (d *Dispatcher) route(alert Alert) []string {
+	names := map[string]bool{}
+	if len(d.Routes) == 0 {
+		for name := range d.Notifiers {
+			names[name] = true
+		}
+	}
+	for _, route := range d.Routes {
+		if !config.MatchPattern(route.Match, alert.Check) {
+			continue
+		}
+		if len(route.Events) > 0 && !containsEvent(route.Events, alert.Event) {
+			continue
+		}
+		for _, name := range route.Notifiers {
+			names[name] = true
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// helper function to tell an event is listed, case aside
+func containsEvent(events []string, event string) bool {
+	for _, e := range events {
+		if strings.EqualFold(e, event) {
+			return true
+		}
+	}
+	return false
+}
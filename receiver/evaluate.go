@@ -0,0 +1,191 @@
+package receiver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+)
+
+// helper function to record a ping: /<token> reports a success, /<token>/fail
+// a failure and /<token>/<code> a run that exited with code
+func (r *Receiver) handlePing(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost && req.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	token, suffix, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, PingPath), "/")
+	exitCode := 0
+	switch {
+	case suffix == "":
+	case suffix == "fail":
+		exitCode = 1
+	default:
+		code, err := strconv.Atoi(suffix)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		exitCode = code
+	}
+
+	check := r.state.checkByToken(token)
+	if check == nil {
+		writeError(w, http.StatusNotFound, "Heartbeat not found")
+		return
+	}
+
+	// The body of a ping is the output of the run, kept on its incident
+	body, _ := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, MaxPingBody))
+	r.recordPing(check, exitCode, string(body), r.Now())
+	r.save()
+	w.WriteHeader(http.StatusOK)
+}
+
+// helper function to update a check with a run and raise or resolve its
+// incident
+func (r *Receiver) recordPing(check *Check, exitCode int, output string, now time.Time) {
+	check.LastPingAt = now
+	check.LastExitCode = exitCode
+	if r.paused(check) {
+		return
+	}
+
+	incident := r.state.openIncident(check.ID)
+	if exitCode != 0 {
+		check.Status = StatusDown
+		if incident == nil {
+			r.raise(check, CauseFailed, exitCode, output, now)
+		}
+		return
+	}
+
+	check.Status = StatusUp
+	if incident != nil {
+		incident.ResolvedAt = now
+		r.events = append(r.events, Event{Type: EventRecovered, Check: *check, Incident: *incident, Time: now})
+	}
+}
+
+// Function to raise an incident for every check whose run is overdue, and
+// return the number of open incidents
+func (r *Receiver) Evaluate() int {
+	defer r.dispatch()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.Now()
+	changed := false
+	open := 0
+	for _, id := range sortedIDs(r.state.Checks) {
+		check := r.state.Checks[id]
+		if r.state.openIncident(check.ID) != nil {
+			open++
+			continue
+		}
+		if r.paused(check) {
+			continue
+		}
+
+		deadline, err := r.deadline(check)
+		if err != nil {
+			fmt.Printf("Error evaluating heartbeat %s: %v\n", check.Name, err)
+			continue
+		}
+		if now.After(deadline) {
+			check.Status = StatusDown
+			r.raise(check, CauseMissed, 0, "", now)
+			changed = true
+			open++
+		}
+	}
+
+	if changed {
+		r.save()
+	}
+	return open
+}
+
+// helper function to compute when a check is overdue: the next run of its
+// cron schedule after the last ping, or a period after it, plus the grace
+func (r *Receiver) deadline(check *Check) (time.Time, error) {
+	since := check.WatchedSince
+	if check.LastPingAt.After(since) {
+		since = check.LastPingAt
+	}
+	grace := time.Duration(check.Grace) * time.Second
+
+	if check.Schedule != "" {
+		next, err := nextRun(check.Schedule, since)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return next.Add(grace), nil
+	}
+	return since.Add(time.Duration(check.Period)*time.Second + grace), nil
+}
+
+// helper function to compute the next run of a cron schedule, prefixed with
+// CRON_TZ= when it does not run in the local timezone
+func nextRun(schedule string, from time.Time) (time.Time, error) {
+	spec, location, err := crontab.SplitScheduleTimezone(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	runs, err := crontab.NextRuns(spec, location, from, 1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return runs[0], nil
+}
+
+// helper function to tell a check, or its group, is paused
+func (r *Receiver) paused(check *Check) bool {
+	if check.Paused {
+		return true
+	}
+	group, ok := r.state.Groups[strconv.Itoa(check.GroupID)]
+	return ok && group.Paused
+}
+
+// helper function to open an incident and queue its event
+func (r *Receiver) raise(check *Check, cause string, exitCode int, output string, now time.Time) {
+	r.state.NextID++
+	incident := &Incident{
+		ID:        strconv.Itoa(r.state.NextID),
+		CheckID:   check.ID,
+		CheckName: check.Name,
+		Cause:     cause,
+		ExitCode:  exitCode,
+		Output:    output,
+		StartedAt: now,
+	}
+	r.state.Incidents = append(r.state.Incidents, incident)
+
+	eventType := EventDown
+	if cause == CauseFailed {
+		eventType = EventFailed
+	}
+	r.events = append(r.events, Event{Type: eventType, Check: *check, Incident: *incident, Time: now})
+}
+
+// helper function to hand the queued events to OnEvent, outside of the lock
+// so a slow handler does not hold the pings up
+func (r *Receiver) dispatch() {
+	r.mu.Lock()
+	events := r.events
+	r.events = nil
+	r.mu.Unlock()
+
+	if r.OnEvent == nil {
+		return
+	}
+	for _, event := range events {
+		r.OnEvent(event)
+	}
+}
@@ -0,0 +1,486 @@
+package receiver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+)
+
+// Path prefixes of the API and of the pings, the same as Better Stack's so
+// the heartbeat package and the curl commands work unchanged
+const (
+	APIPath  = "/api/v2"
+	PingPath = "/api/v1/heartbeat/"
+)
+
+// Largest ping body kept as the output of a run
+const MaxPingBody = 10000
+
+// Resolved incidents kept in the state file: the most recent ones, for at
+// most the retention
+const (
+	MaxResolvedIncidents = 500
+	IncidentRetention    = 30 * 24 * time.Hour
+)
+
+// Types of the events raised by the receiver
+const (
+	EventDown      = "down"
+	EventFailed    = "failed"
+	EventRecovered = "recovered"
+	// The heartbeat of an open incident was deleted, nothing is alerted
+	EventDeleted = "deleted"
+)
+
+// Event is an incident raised or resolved by the receiver
+type Event struct {
+	Type     string
+	Check    Check
+	Incident Incident
+	Time     time.Time
+}
+
+// Receiver is a self-hosted stand-in for the Better Stack heartbeat API: it
+// serves the heartbeat and group endpoints used by beatify, records the
+// pings of the cron tasks and raises an incident when a run is missed or
+// fails
+type Receiver struct {
+	// Token expected as "Authorization: Bearer <Token>" by the API
+	Token string
+	// Base of the ping URLs handed out, e.g. http://monitor.lan:8470
+	PublicURL string
+	// File keeping the state between restarts
+	StateFile string
+	// Called with every incident raised or resolved
+	OnEvent func(Event)
+	// Clock of the receiver
+	Now func() time.Time
+
+	mu     sync.Mutex
+	state  *State
+	events []Event
+}
+
+// Function to create a receiver from its state file
+func New(stateFile, token, publicURL string) (*Receiver, error) {
+	state, err := LoadState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	// Incidents of heartbeats deleted by an earlier version stay open forever
+	for _, incident := range state.Incidents {
+		if _, ok := state.Checks[incident.CheckID]; !ok && incident.Open() {
+			incident.ResolvedAt = time.Now()
+		}
+	}
+	return &Receiver{
+		Token:     token,
+		PublicURL: strings.TrimSuffix(publicURL, "/"),
+		StateFile: stateFile,
+		Now:       time.Now,
+		state:     state,
+	}, nil
+}
+
+// Function to get the HTTP handler serving the API and the pings
+func (r *Receiver) Handler() http.Handler {
+	return http.HandlerFunc(r.handle)
+}
+
+// Function to get a copy of the checks, ordered by ID
+func (r *Receiver) Checks() []Check {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	checks := []Check{}
+	for _, id := range sortedIDs(r.state.Checks) {
+		checks = append(checks, *r.state.Checks[id])
+	}
+	return checks
+}
+
+// Function to get a copy of the incidents, oldest first
+func (r *Receiver) Incidents() []Incident {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	incidents := []Incident{}
+	for _, incident := range r.state.Incidents {
+		incidents = append(incidents, *incident)
+	}
+	return incidents
+}
+
+// helper function to serve every request
+func (r *Receiver) handle(w http.ResponseWriter, req *http.Request) {
+	// The events are dispatched once the lock is released
+	defer r.dispatch()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strings.HasPrefix(req.URL.Path, PingPath) {
+		r.handlePing(w, req)
+		return
+	}
+	if !strings.HasPrefix(req.URL.Path, APIPath+"/") {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+r.Token {
+		writeError(w, http.StatusUnauthorized, "Invalid API token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, APIPath), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "heartbeats":
+		r.handleChecks(w, req)
+	case len(parts) == 2 && parts[0] == "heartbeats":
+		r.handleCheck(w, req, parts[1])
+	case len(parts) == 1 && parts[0] == "heartbeat-groups":
+		r.handleGroups(w, req)
+	case len(parts) == 2 && parts[0] == "heartbeat-groups":
+		r.handleGroup(w, req, parts[1])
+	case len(parts) == 1 && parts[0] == "incidents" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": r.state.Incidents})
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// Request body of a heartbeat creation or update, the group ID is accepted
+// as a number or a string
+type checkRequest struct {
+	Name             *string         `json:"name"`
+	Period           *int            `json:"period"`
+	Grace            *int            `json:"grace"`
+	Schedule         *string         `json:"schedule"`
+	HeartbeatGroupID json.RawMessage `json:"heartbeat_group_id"`
+	Paused           *bool           `json:"paused"`
+}
+
+// helper function to serve the heartbeat list and creation
+func (r *Receiver) handleChecks(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		list := []heartbeat.Heartbeat{}
+		for _, id := range sortedIDs(r.state.Checks) {
+			list = append(list, r.heartbeat(r.state.Checks[id]))
+		}
+		start, end, pagination := r.paginate(req, len(list))
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatsResponse{Data: list[start:end], Pagination: pagination})
+	case http.MethodPost:
+		var request checkRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if request.Name == nil || *request.Name == "" || request.Period == nil || *request.Period <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, "name and period are required")
+			return
+		}
+
+		now := r.Now()
+		r.state.NextID++
+		check := &Check{
+			ID:           strconv.Itoa(r.state.NextID),
+			Token:        newToken(),
+			Status:       StatusPending,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			WatchedSince: now,
+		}
+		if err := r.applyRequest(check, request); err != nil {
+			r.state.NextID--
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		r.state.Checks[check.ID] = check
+		r.save()
+		writeJSON(w, http.StatusCreated, heartbeat.HeartbeatResponse{Data: r.heartbeat(check)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to serve a single heartbeat
+func (r *Receiver) handleCheck(w http.ResponseWriter, req *http.Request, id string) {
+	check, ok := r.state.Checks[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Heartbeat not found")
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatResponse{Data: r.heartbeat(check)})
+	case http.MethodPatch:
+		var request checkRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updated := *check
+		if err := r.applyRequest(&updated, request); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		updated.UpdatedAt = r.Now()
+		*check = updated
+		r.save()
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatResponse{Data: r.heartbeat(check)})
+	case http.MethodDelete:
+		r.deleteCheck(check)
+		r.save()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to apply the fields set in a request to a check
+func (r *Receiver) applyRequest(check *Check, request checkRequest) error {
+	if request.Name != nil {
+		check.Name = *request.Name
+	}
+	if request.Period != nil {
+		check.Period = *request.Period
+	}
+	if request.Grace != nil {
+		check.Grace = *request.Grace
+	}
+	if request.Schedule != nil {
+		if _, err := nextRun(*request.Schedule, time.Now()); err != nil {
+			return err
+		}
+		check.Schedule = *request.Schedule
+	}
+	if len(request.HeartbeatGroupID) > 0 && string(request.HeartbeatGroupID) != "null" {
+		id := strings.Trim(string(request.HeartbeatGroupID), `"`)
+		if _, ok := r.state.Groups[id]; !ok {
+			return fmt.Errorf("unknown heartbeat group '%s'", id)
+		}
+		check.GroupID, _ = strconv.Atoi(id)
+	}
+	if request.Paused != nil && *request.Paused != check.Paused {
+		check.Paused = *request.Paused
+		check.Status = StatusPaused
+		if !check.Paused {
+			// A resumed check waits for its next run from now on
+			check.Status = StatusPending
+			check.WatchedSince = r.Now()
+		}
+	}
+	return nil
+}
+
+// helper function to serve the heartbeat group list and creation
+func (r *Receiver) handleGroups(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		list := []heartbeat.HeartbeatGroup{}
+		for _, id := range sortedIDs(r.state.Groups) {
+			list = append(list, heartbeatGroup(r.state.Groups[id]))
+		}
+		start, end, pagination := r.paginate(req, len(list))
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupsResponse{Data: list[start:end], Pagination: pagination})
+	case http.MethodPost:
+		var request heartbeat.HeartbeatGroupRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if request.Name == nil || *request.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "name is required")
+			return
+		}
+
+		now := r.Now()
+		r.state.NextID++
+		group := &Group{ID: strconv.Itoa(r.state.NextID), Name: *request.Name, CreatedAt: now, UpdatedAt: now}
+		r.state.Groups[group.ID] = group
+		r.save()
+		writeJSON(w, http.StatusCreated, heartbeat.HeartbeatGroupResponse{Data: heartbeatGroup(group)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to serve a single heartbeat group
+func (r *Receiver) handleGroup(w http.ResponseWriter, req *http.Request, id string) {
+	group, ok := r.state.Groups[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Heartbeat group not found")
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupResponse{Data: heartbeatGroup(group)})
+	case http.MethodPatch:
+		var request heartbeat.HeartbeatGroupRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if request.Name != nil {
+			group.Name = *request.Name
+		}
+		if request.Paused != nil {
+			group.Paused = *request.Paused
+		}
+		group.UpdatedAt = r.Now()
+		r.save()
+		writeJSON(w, http.StatusOK, heartbeat.HeartbeatGroupResponse{Data: heartbeatGroup(group)})
+	case http.MethodDelete:
+		// The heartbeats of a deleted group go with it
+		groupID, _ := strconv.Atoi(id)
+		for _, checkID := range sortedIDs(r.state.Checks) {
+			if check := r.state.Checks[checkID]; check.GroupID == groupID {
+				r.deleteCheck(check)
+			}
+		}
+		delete(r.state.Groups, id)
+		r.save()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// helper function to delete a check, closing its open incident as nothing
+// could resolve it anymore
+func (r *Receiver) deleteCheck(check *Check) {
+	delete(r.state.Checks, check.ID)
+	if incident := r.state.openIncident(check.ID); incident != nil {
+		now := r.Now()
+		incident.ResolvedAt = now
+		r.events = append(r.events, Event{Type: EventDeleted, Check: *check, Incident: *incident, Time: now})
+	}
+}
+
+// helper function to select the page of a list request, returning the start
+// and end indexes of the page and the pagination links
+func (r *Receiver) paginate(req *http.Request, total int) (int, int, heartbeat.Pagination) {
+	perPage := 50
+	if value, err := strconv.Atoi(req.URL.Query().Get("per_page")); err == nil && value > 0 {
+		perPage = value
+	}
+	page := 1
+	if value, err := strconv.Atoi(req.URL.Query().Get("page")); err == nil && value > 0 {
+		page = value
+	}
+	pages := (total + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+
+	link := func(n int) string {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(n))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return r.PublicURL + req.URL.Path + "?" + query.Encode()
+	}
+	pagination := heartbeat.Pagination{First: link(1), Last: link(pages)}
+	if page > 1 {
+		pagination.Prev = link(page - 1)
+	}
+	if page < pages {
+		pagination.Next = link(page + 1)
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end, pagination
+}
+
+// helper function to describe a check as the API does
+func (r *Receiver) heartbeat(check *Check) heartbeat.Heartbeat {
+	attributes := heartbeat.HeartbeatAttributes{
+		URL:              r.PublicURL + PingPath + check.Token,
+		Name:             check.Name,
+		Period:           check.Period,
+		Grace:            check.Grace,
+		HeartbeatGroupID: check.GroupID,
+		CreatedAt:        check.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:        check.UpdatedAt.UTC().Format(time.RFC3339),
+		Status:           check.Status,
+	}
+	if check.Paused {
+		attributes.PausedAt = check.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return heartbeat.Heartbeat{ID: check.ID, Type: "heartbeat", Attributes: attributes}
+}
+
+// helper function to describe a group as the API does
+func heartbeatGroup(group *Group) heartbeat.HeartbeatGroup {
+	return heartbeat.HeartbeatGroup{
+		ID:   group.ID,
+		Type: "heartbeat_group",
+		Attributes: heartbeat.HeartbeatGroupAttributes{
+			Name:      group.Name,
+			Paused:    group.Paused,
+			CreatedAt: group.CreatedAt.UTC().Format(time.RFC3339),
+			UpdatedAt: group.UpdatedAt.UTC().Format(time.RFC3339),
+		},
+	}
+}
+
+// helper function to write the state file, the API keeps serving from
+// memory when it cannot be written
+func (r *Receiver) save() {
+	r.state.pruneIncidents(r.Now(), MaxResolvedIncidents, IncidentRetention)
+	if r.StateFile == "" {
+		return
+	}
+	if err := r.state.Save(r.StateFile); err != nil {
+		fmt.Println("Error saving receiver state:", err)
+	}
+}
+
+// helper function to generate the secret part of a ping URL
+func newToken() string {
+	token := make([]byte, 12)
+	rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// helper function to list the IDs of a map in numeric order
+func sortedIDs[T any](items map[string]T) []string {
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+// helper function to write a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// helper function to write an error response in the shape of the API
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"errors": message})
+}
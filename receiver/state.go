@@ -0,0 +1,172 @@
+package receiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Statuses of a check
+const (
+	StatusPending = "pending"
+	StatusUp      = "up"
+	StatusDown    = "down"
+	StatusPaused  = "paused"
+)
+
+// Causes of an incident
+const (
+	CauseMissed = "missed"
+	CauseFailed = "failed"
+)
+
+// Check is a heartbeat kept by the receiver
+type Check struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Token    string `json:"token"`
+	Period   int    `json:"period"`
+	Grace    int    `json:"grace"`
+	Schedule string `json:"schedule,omitempty"`
+	GroupID  int    `json:"group_id,omitempty"`
+	Paused   bool   `json:"paused"`
+	Status   string `json:"status"`
+
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	LastPingAt   time.Time `json:"last_ping_at"`
+	LastExitCode int       `json:"last_exit_code"`
+	// Time from which a run is awaited when no ping came since
+	WatchedSince time.Time `json:"watched_since"`
+}
+
+// Group is a heartbeat group kept by the receiver
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Incident is a missed or failed run of a check, resolved by the next
+// successful ping
+type Incident struct {
+	ID         string    `json:"id"`
+	CheckID    string    `json:"check_id"`
+	CheckName  string    `json:"check_name"`
+	Cause      string    `json:"cause"`
+	ExitCode   int       `json:"exit_code,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// Function to tell an incident is still open
+func (i Incident) Open() bool {
+	return i.ResolvedAt.IsZero()
+}
+
+// State is everything the receiver keeps between restarts
+type State struct {
+	NextID    int               `json:"next_id"`
+	Checks    map[string]*Check `json:"checks"`
+	Groups    map[string]*Group `json:"groups"`
+	Incidents []*Incident       `json:"incidents"`
+}
+
+// Function to read the state file, an empty state when it does not exist yet
+func LoadState(path string) (*State, error) {
+	state := &State{Checks: map[string]*Check{}, Groups: map[string]*Group{}}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.Checks == nil {
+		state.Checks = map[string]*Check{}
+	}
+	if state.Groups == nil {
+		state.Groups = map[string]*Group{}
+	}
+	return state, nil
+}
+
+// Function to write the state file, replacing it in one step
+func (s *State) Save(path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".beatify")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// helper function to find the open incident of a check
+func (s *State) openIncident(checkID string) *Incident {
+	for i := len(s.Incidents) - 1; i >= 0; i-- {
+		if s.Incidents[i].CheckID == checkID && s.Incidents[i].Open() {
+			return s.Incidents[i]
+		}
+	}
+	return nil
+}
+
+// helper function to drop the resolved incidents older than the retention,
+// and the oldest ones beyond the number kept
+func (s *State) pruneIncidents(now time.Time, keep int, retention time.Duration) {
+	resolved := 0
+	for _, incident := range s.Incidents {
+		if !incident.Open() {
+			resolved++
+		}
+	}
+
+	kept := s.Incidents[:0]
+	for _, incident := range s.Incidents {
+		if !incident.Open() && (resolved > keep || now.Sub(incident.ResolvedAt) > retention) {
+			resolved--
+			continue
+		}
+		kept = append(kept, incident)
+	}
+	s.Incidents = kept
+}
+
+// helper function to find a check by its ping token
+func (s *State) checkByToken(token string) *Check {
+	for _, check := range s.Checks {
+		if check.Token == token {
+			return check
+		}
+	}
+	return nil
+}
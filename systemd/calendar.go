@@ -0,0 +1,250 @@
+package systemd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar shorthands of systemd and the cron schedule they stand for
+var calendarShorthands = map[string]string{
+	"minutely":     "* * * * *",
+	"hourly":       "0 * * * *",
+	"daily":        "0 0 * * *",
+	"weekly":       "0 0 * * 1",
+	"monthly":      "0 0 1 * *",
+	"quarterly":    "0 0 1 1,4,7,10 *",
+	"semiannually": "0 0 1 1,7 *",
+	"yearly":       "0 0 1 1 *",
+	"annually":     "0 0 1 1 *",
+}
+
+// Weekday names of systemd, by cron day number
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Function to translate an OnCalendar expression into a five-field cron
+// schedule, the seconds being dropped and a trailing timezone becoming a
+// CRON_TZ= prefix
+//
+//	daily                     -> 0 0 * * *
+//	Mon..Fri *-*-* 08:30:00   -> 30 8 * * 1,2,3,4,5
+//	*:0/15                    -> 0/15 * * * *
+//	*-*-01 04:00 Europe/Paris -> CRON_TZ=Europe/Paris 0 4 1 * *
+func CalendarToCron(expression string) (string, error) {
+	fields := strings.Fields(expression)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty calendar expression")
+	}
+
+	prefix := ""
+	if len(fields) > 1 && !strings.ContainsAny(fields[len(fields)-1], ":*") {
+		if _, err := time.LoadLocation(fields[len(fields)-1]); err == nil {
+			prefix = "CRON_TZ=" + fields[len(fields)-1] + " "
+			fields = fields[:len(fields)-1]
+		}
+	}
+
+	if len(fields) == 1 {
+		if spec, ok := calendarShorthands[strings.ToLower(fields[0])]; ok {
+			return prefix + spec, nil
+		}
+	}
+
+	weekdays, month, day, hour, minute := "*", "*", "*", "0", "0"
+	dateSet, timeSet := false, false
+	for i, field := range fields {
+		switch {
+		case i == 0 && strings.ContainsAny(strings.ToLower(field), "abcdefghijklmnopqrstuvwxyz"):
+			days, err := convertWeekdays(field)
+			if err != nil {
+				return "", fmt.Errorf("invalid calendar expression '%s': %w", expression, err)
+			}
+			weekdays = days
+		case !dateSet && !timeSet && strings.Contains(field, "-") && !strings.Contains(field, ":"):
+			parts := strings.Split(field, "-")
+			if len(parts) == 3 {
+				// Any year runs at the same times
+				parts = parts[1:]
+			}
+			if len(parts) != 2 {
+				return "", fmt.Errorf("invalid date '%s' in calendar expression '%s'", field, expression)
+			}
+			var err error
+			if month, err = convertField(parts[0]); err != nil {
+				return "", fmt.Errorf("invalid calendar expression '%s': %w", expression, err)
+			}
+			if day, err = convertField(parts[1]); err != nil {
+				return "", fmt.Errorf("invalid calendar expression '%s': %w", expression, err)
+			}
+			dateSet = true
+		case !timeSet && strings.Contains(field, ":"):
+			parts := strings.Split(field, ":")
+			if len(parts) != 2 && len(parts) != 3 {
+				return "", fmt.Errorf("invalid time '%s' in calendar expression '%s'", field, expression)
+			}
+			var err error
+			if hour, err = convertField(parts[0]); err != nil {
+				return "", fmt.Errorf("invalid calendar expression '%s': %w", expression, err)
+			}
+			if minute, err = convertField(parts[1]); err != nil {
+				return "", fmt.Errorf("invalid calendar expression '%s': %w", expression, err)
+			}
+			timeSet = true
+		default:
+			return "", fmt.Errorf("unsupported calendar expression '%s'", expression)
+		}
+	}
+
+	// systemd runs on the days matching both the weekday and the day of
+	// month, cron on the days matching either
+	if weekdays != "*" && day != "*" {
+		return "", fmt.Errorf("calendar expression '%s' restricts both the weekday and the day of month, which a cron schedule cannot express", expression)
+	}
+
+	return prefix + strings.Join([]string{minute, hour, day, month, weekdays}, " "), nil
+}
+
+// helper function to convert a date or time component: lists, a..b ranges
+// and /step repetitions
+func convertField(field string) (string, error) {
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		base, step, repeated := strings.Cut(item, "/")
+		var converted string
+		switch {
+		case base == "*":
+			converted = "*"
+		case strings.Contains(base, ".."):
+			from, to, _ := strings.Cut(base, "..")
+			first, err := strconv.Atoi(from)
+			if err != nil {
+				return "", fmt.Errorf("invalid value '%s'", item)
+			}
+			last, err := strconv.Atoi(to)
+			if err != nil {
+				return "", fmt.Errorf("invalid value '%s'", item)
+			}
+			converted = fmt.Sprintf("%d-%d", first, last)
+		default:
+			value, err := strconv.Atoi(base)
+			if err != nil {
+				return "", fmt.Errorf("invalid value '%s'", item)
+			}
+			converted = strconv.Itoa(value)
+		}
+		if repeated {
+			every, err := strconv.Atoi(step)
+			if err != nil || every <= 0 {
+				return "", fmt.Errorf("invalid repetition '%s'", item)
+			}
+			converted = fmt.Sprintf("%s/%d", converted, every)
+		}
+		items = append(items, converted)
+	}
+	return strings.Join(items, ","), nil
+}
+
+// Separators of the weekday ranges of systemd
+var weekdayRangeRegexp = regexp.MustCompile(`\.\.|-`)
+
+// helper function to convert weekdays such as Mon..Fri,Sun into cron day
+// numbers, ranges wrapping around the week
+func convertWeekdays(field string) (string, error) {
+	var days []string
+	for _, item := range strings.Split(field, ",") {
+		bounds := weekdayRangeRegexp.Split(item, 2)
+		first, err := weekdayNumber(bounds[0])
+		if err != nil {
+			return "", err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = weekdayNumber(bounds[1]); err != nil {
+				return "", err
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, strconv.Itoa(day))
+			if day == last {
+				break
+			}
+		}
+	}
+	return strings.Join(days, ","), nil
+}
+
+// helper function to get the cron number of a weekday name, full or short
+func weekdayNumber(name string) (int, error) {
+	lowered := strings.ToLower(name)
+	for i, weekday := range weekdayNames {
+		if len(lowered) >= 3 && strings.HasPrefix(lowered, weekday) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday '%s'", name)
+}
+
+// Units of the time spans of systemd
+var timespanUnits = map[string]time.Duration{
+	"":        time.Second,
+	"us":      time.Microsecond,
+	"usec":    time.Microsecond,
+	"ms":      time.Millisecond,
+	"msec":    time.Millisecond,
+	"s":       time.Second,
+	"sec":     time.Second,
+	"second":  time.Second,
+	"seconds": time.Second,
+	"m":       time.Minute,
+	"min":     time.Minute,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"h":       time.Hour,
+	"hr":      time.Hour,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"d":       24 * time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"w":       7 * 24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+	"M":       2629800 * time.Second,
+	"month":   2629800 * time.Second,
+	"months":  2629800 * time.Second,
+	"y":       31557600 * time.Second,
+	"year":    31557600 * time.Second,
+	"years":   31557600 * time.Second,
+}
+
+// Parts of a time span, such as "1h 30min"
+var timespanRegexp = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]*)`)
+
+// Function to parse a systemd time span such as 90, 15min or 1h 30min
+func ParseTimespan(value string) (time.Duration, error) {
+	rest := strings.TrimSpace(value)
+	if rest == "" {
+		return 0, fmt.Errorf("empty time span")
+	}
+
+	var total time.Duration
+	for strings.TrimSpace(rest) != "" {
+		match := timespanRegexp.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, fmt.Errorf("invalid time span '%s'", value)
+		}
+		unit, ok := timespanUnits[match[2]]
+		if !ok {
+			unit, ok = timespanUnits[strings.ToLower(match[2])]
+		}
+		if !ok {
+			return 0, fmt.Errorf("invalid time span '%s': unknown unit '%s'", value, match[2])
+		}
+		number, _ := strconv.ParseFloat(match[1], 64)
+		total += time.Duration(number * float64(unit))
+		rest = rest[len(match[0]):]
+	}
+	return total, nil
+}
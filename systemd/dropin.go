@@ -0,0 +1,108 @@
+package systemd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Directory the drop-ins of beatify are written to
+var DropInDir = "/etc/systemd/system"
+
+// Path of the systemctl binary, run to reload the units once changed
+var Systemctl = "systemctl"
+
+// Name of the drop-in beatify adds to the service of a timer
+const dropInName = "beatify.conf"
+
+// First line of the files written by beatify, naming the heartbeat
+const headerPrefix = "# Written by beatify, pings heartbeat "
+
+// Command pinging a heartbeat once the service stopped, with the exit
+// status of a failed run or /fail when it did not exit by itself; the
+// leading dash keeps a failed ping from failing the service
+const pingCommandFormat = `-/bin/sh -c 'url=%s; case "$$SERVICE_RESULT:$$EXIT_CODE" in success:*) ;; exit-code:exited) url="$$url/$$EXIT_STATUS" ;; *) url="$$url/fail" ;; esac; exec curl -fs --retry 3 "$$url"'`
+
+// Function to make the service of a timer ping a heartbeat: a drop-in adds
+// an ExecStopPost= command pinging it once each run is over, whatever the
+// type of the service, with the result systemd gives the command
+func Instrument(timer Timer, heartbeatURL string) error {
+	if strings.ContainsAny(heartbeatURL, "'\"$%`\\ \n") {
+		return fmt.Errorf("invalid heartbeat URL '%s'", heartbeatURL)
+	}
+
+	dropIn := headerPrefix + heartbeatURL + "\n" + fmt.Sprintf(`[Service]
+ExecStopPost=%s
+`, fmt.Sprintf(pingCommandFormat, strings.TrimSuffix(heartbeatURL, "/")))
+
+	return writeUnitFile(dropInPath(timer.Service), dropIn)
+}
+
+// Function to remove the drop-in of a timer
+func Uninstrument(timer Timer) error {
+	path := dropInPath(timer.Service)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	// The drop-in directory is only removed when beatify left it empty
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// Function to reload the unit files so the drop-ins take effect
+func DaemonReload() error {
+	output, err := exec.Command(Systemctl, "daemon-reload").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// helper function to get the path of the drop-in of a service
+func dropInPath(service string) string {
+	return filepath.Join(DropInDir, service+".d", dropInName)
+}
+
+// helper function to read the heartbeat URL of the drop-in of a service,
+// empty when there is none
+func readDropInURL(service string) string {
+	content, err := ioutil.ReadFile(dropInPath(service))
+	if err != nil {
+		return ""
+	}
+	firstLine, _, _ := strings.Cut(string(content), "\n")
+	if !strings.HasPrefix(firstLine, headerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(firstLine, headerPrefix))
+}
+
+// helper function to write a unit file in one step
+func writeUnitFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create unit directory: %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
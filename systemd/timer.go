@@ -0,0 +1,259 @@
+package systemd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+	"github.com/IT-JONCTION/beatify/heartbeat"
+)
+
+// Directories holding unit files, the first one holding a unit wins
+var UnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// Default accuracy of a timer, added to the grace of its heartbeat
+const defaultAccuracy = time.Minute
+
+// Timer is a .timer unit with the service it starts
+type Timer struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Service     string `json:"service"`
+	Description string `json:"description,omitempty"`
+	User        string `json:"user"`
+
+	OnCalendar         []string      `json:"on_calendar,omitempty"`
+	OnUnitActiveSec    time.Duration `json:"-"`
+	OnUnitInactiveSec  time.Duration `json:"-"`
+	AccuracySec        time.Duration `json:"-"`
+	RandomizedDelaySec time.Duration `json:"-"`
+
+	// Heartbeat pinged by the drop-in of the service, empty when not monitored
+	HeartbeatURL string `json:"heartbeat_url,omitempty"`
+
+	// Error reading the timer, its drop-ins or its service, the timer being
+	// listed with what could be read
+	ReadError string `json:"-"`
+}
+
+// Function to list the timers of the unit directories, skipping templates
+// and masked units. A timer that cannot be read is listed with its error
+func DiscoverTimers() ([]Timer, error) {
+	paths := map[string]string{}
+	for _, dir := range UnitDirs {
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unit directory: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasSuffix(name, ".timer") || strings.Contains(name, "@") || entry.IsDir() {
+				continue
+			}
+			if _, ok := paths[name]; ok {
+				continue
+			}
+			paths[name] = filepath.Join(dir, name)
+		}
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timers := []Timer{}
+	for _, name := range names {
+		if target, err := filepath.EvalSymlinks(paths[name]); err == nil && target == os.DevNull {
+			continue
+		}
+		timer, err := readTimer(name, paths[name])
+		if err != nil {
+			// One broken timer does not hide the others
+			timer.Name, timer.Path, timer.ReadError = name, paths[name], err.Error()
+		}
+		timers = append(timers, timer)
+	}
+	return timers, nil
+}
+
+// helper function to read a timer, its drop-ins and its service
+func readTimer(name, path string) (Timer, error) {
+	unit, err := readUnit(name, path)
+	if err != nil {
+		return Timer{}, err
+	}
+
+	timer := Timer{
+		Name:        name,
+		Path:        path,
+		Service:     unit.Get("Timer", "Unit"),
+		Description: unit.Get("Unit", "Description"),
+		OnCalendar:  unit.Values("Timer", "OnCalendar"),
+		AccuracySec: defaultAccuracy,
+		User:        "root",
+	}
+	if timer.Service == "" {
+		timer.Service = strings.TrimSuffix(name, ".timer") + ".service"
+	}
+
+	spans := []struct {
+		Key   string
+		Value *time.Duration
+	}{
+		{"OnUnitActiveSec", &timer.OnUnitActiveSec},
+		{"OnUnitInactiveSec", &timer.OnUnitInactiveSec},
+		{"AccuracySec", &timer.AccuracySec},
+		{"RandomizedDelaySec", &timer.RandomizedDelaySec},
+	}
+	for _, span := range spans {
+		if value := unit.Get("Timer", span.Key); value != "" {
+			if *span.Value, err = ParseTimespan(value); err != nil {
+				return timer, fmt.Errorf("%s: %s: %w", path, span.Key, err)
+			}
+		}
+	}
+
+	// The service tells who runs the job, and holds the drop-in of beatify
+	if servicePath := findUnit(timer.Service); servicePath != "" {
+		service, err := readUnit(timer.Service, servicePath)
+		if err != nil {
+			return timer, err
+		}
+		if user := service.Get("Service", "User"); user != "" {
+			timer.User = user
+		}
+	}
+	timer.HeartbeatURL = readDropInURL(timer.Service)
+
+	return timer, nil
+}
+
+// helper function to read a unit file and its drop-ins from every unit
+// directory, in the order systemd applies them
+func readUnit(name, path string) (Unit, error) {
+	unit := Unit{}
+	if err := unit.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	dropIns := map[string]string{}
+	for i := len(UnitDirs) - 1; i >= 0; i-- {
+		matches, _ := filepath.Glob(filepath.Join(UnitDirs[i], name+".d", "*.conf"))
+		for _, match := range matches {
+			dropIns[filepath.Base(match)] = match
+		}
+	}
+	files := make([]string, 0, len(dropIns))
+	for file := range dropIns {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := unit.ReadFile(dropIns[file]); err != nil {
+			return nil, err
+		}
+	}
+	return unit, nil
+}
+
+// helper function to find the file of a unit in the unit directories
+func findUnit(name string) string {
+	for _, dir := range UnitDirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Function to describe when a timer runs
+func (t Timer) Schedule() string {
+	switch {
+	case len(t.OnCalendar) > 0:
+		return strings.Join(t.OnCalendar, "; ")
+	case t.OnUnitActiveSec > 0:
+		return "every " + formatSpan(t.OnUnitActiveSec) + " after the last start"
+	case t.OnUnitInactiveSec > 0:
+		return "every " + formatSpan(t.OnUnitInactiveSec) + " after the last run"
+	}
+	return "not recurring"
+}
+
+// helper function to write a time span without its zero minutes and seconds,
+// such as 15m or 1h
+func formatSpan(span time.Duration) string {
+	text := span.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// Function to compute the period and grace of the heartbeat of a timer, and
+// the cron schedule of a single OnCalendar expression, in its timezone. The
+// period is the longest time between two runs of the OnCalendar
+// expressions, and the grace also covers the accuracy and the randomized
+// delay of the timer
+func (t Timer) Period() (period int, grace int, spec string, err error) {
+	switch {
+	case len(t.OnCalendar) > 0:
+		if period, err = longestGap(t.OnCalendar); err != nil {
+			return 0, 0, "", err
+		}
+		grace = heartbeat.DefaultGrace(period)
+		if len(t.OnCalendar) == 1 {
+			// Already translated by longestGap
+			spec, _ = CalendarToCron(t.OnCalendar[0])
+		}
+	case t.OnUnitActiveSec > 0 || t.OnUnitInactiveSec > 0:
+		span := t.OnUnitActiveSec
+		if span == 0 {
+			span = t.OnUnitInactiveSec
+		}
+		period = int(span.Seconds())
+		grace = heartbeat.DefaultGrace(period)
+	default:
+		return 0, 0, "", fmt.Errorf("no OnCalendar=, OnUnitActiveSec= or OnUnitInactiveSec= setting")
+	}
+
+	grace += int((t.AccuracySec + t.RandomizedDelaySec).Seconds())
+	return period, grace, spec, nil
+}
+
+// helper function to find the longest time between two runs of one or more
+// calendar expressions. systemd runs a timer at a time skipped by a DST
+// change all the same, so the gaps are computed without DST changes
+func longestGap(expressions []string) (int, error) {
+	specs := make([]string, 0, len(expressions))
+	for _, expression := range expressions {
+		spec, err := CalendarToCron(expression)
+		if err != nil {
+			return 0, err
+		}
+		if spec, _, err = crontab.SplitScheduleTimezone(spec); err != nil {
+			return 0, err
+		}
+		specs = append(specs, spec)
+	}
+	return heartbeat.LongestGap(specs, time.UTC, time.Now())
+}
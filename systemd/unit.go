@@ -0,0 +1,78 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Unit holds the settings of a unit file and its drop-ins, by section and
+// key, in the order they were read
+type Unit map[string]map[string][]string
+
+// Function to get the last value of a setting, empty when unset
+func (u Unit) Get(section, key string) string {
+	values := u[section][key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Function to get every value of a setting, such as the OnCalendar lines
+// of a timer
+func (u Unit) Values(section, key string) []string {
+	return u[section][key]
+}
+
+// Function to read a unit file and add its settings to the unit
+func (u Unit) ReadFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read unit file: %w", err)
+	}
+	u.Parse(content)
+	return nil
+}
+
+// Function to add the settings of unit file content to the unit; an empty
+// assignment resets the list of a setting as systemd does
+func (u Unit) Parse(content []byte) {
+	section := ""
+	var continued string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// A trailing backslash continues the line
+		if strings.HasSuffix(line, `\`) {
+			continued += strings.TrimSpace(strings.TrimSuffix(line, `\`)) + " "
+			continue
+		}
+		line, continued = continued+line, ""
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == "" {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if u[section] == nil {
+			u[section] = map[string][]string{}
+		}
+		if value == "" {
+			u[section][key] = nil
+			continue
+		}
+		u[section][key] = append(u[section][key], value)
+	}
+}
@@ -0,0 +1,98 @@
+package crontab_test
+
+import (
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+)
+
+// helper function to point the package at a store and a fresh lock directory
+func useStore(t *testing.T, store crontab.CrontabStore) {
+	t.Helper()
+	previousStore, previousDir := crontab.Store, crontab.LockDir
+	crontab.Store = store
+	crontab.LockDir = filepath.Join(t.TempDir(), "locks")
+	t.Cleanup(func() {
+		crontab.Store, crontab.LockDir = previousStore, previousDir
+	})
+}
+
+// helper function to get the name of the user running the tests
+func currentUser(t *testing.T) string {
+	t.Helper()
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return current.Username
+}
+
+// Function to check the lock follows the crontab file edited rather than
+// the user it is edited for
+func TestLockCrontabFile(t *testing.T) {
+	dir := t.TempDir()
+	useStore(t, crontab.NewFileStore(filepath.Join(dir, "web")))
+	// As with --store file, the names need no account
+	crontab.RequireSystemUser = false
+	defer func() { crontab.RequireSystemUser = true }()
+
+	lock, err := crontab.LockCrontab("ci")
+	if err != nil {
+		t.Fatalf("Error locking crontab: %v", err)
+	}
+	defer lock.Unlock()
+
+	// The same file under another name is taken
+	if _, err := crontab.LockCrontab("deploy"); err == nil || !strings.Contains(err.Error(), "being edited by another beatify run") {
+		t.Errorf("locking the same file for another user returned %v, expected it held", err)
+	}
+
+	// Another file of the same user is free
+	crontab.Store = crontab.NewFileStore(filepath.Join(dir, "db"))
+	other, err := crontab.LockCrontab("ci")
+	if err != nil {
+		t.Errorf("locking another file of the same user returned %v, expected it free", err)
+	}
+	other.Unlock()
+
+	// Released, the file can be locked again
+	crontab.Store = crontab.NewFileStore(filepath.Join(dir, "web"))
+	lock.Unlock()
+	again, err := crontab.LockCrontab("deploy")
+	if err != nil {
+		t.Errorf("locking a released file returned %v", err)
+	}
+	again.Unlock()
+}
+
+// Function to check the spool store locks the crontab of each user apart
+// and the stream store takes no lock
+func TestLockCrontabSpool(t *testing.T) {
+	crontabUser := currentUser(t)
+	useStore(t, crontab.NewSpoolStore(t.TempDir()))
+
+	lock, err := crontab.LockCrontab(crontabUser)
+	if err != nil {
+		t.Fatalf("Error locking crontab: %v", err)
+	}
+	defer lock.Unlock()
+	if _, err := crontab.LockCrontab(crontabUser); err == nil {
+		t.Errorf("locking the crontab twice succeeded")
+	}
+
+	// The same user in another spool directory is another crontab
+	crontab.Store = crontab.NewSpoolStore(t.TempDir())
+	other, err := crontab.LockCrontab(crontabUser)
+	if err != nil {
+		t.Errorf("locking the crontab of another spool directory returned %v", err)
+	}
+	other.Unlock()
+
+	crontab.Store = crontab.NewStreamStore(strings.NewReader(""), &strings.Builder{})
+	if stream, err := crontab.LockCrontab(crontabUser); err != nil || stream != nil {
+		t.Errorf("locking a stream returned %v, %v, expected no lock", stream, err)
+	}
+}
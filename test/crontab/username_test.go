@@ -0,0 +1,105 @@
+package crontab_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+)
+
+// Accounts known to the fake getent only, as LDAP or SSSD accounts are
+// unknown to os/user without cgo
+const getentScript = `#!/bin/sh
+case "$2" in
+ci) echo "ci:x:1501:1501:CI runner:/home/ci:/bin/sh" ;;
+john.doe) echo "john.doe:x:1502:1502:John Doe:/home/john.doe:/bin/bash" ;;
+'machine$') echo 'machine$:x:1503:1503::/var/empty:/usr/sbin/nologin' ;;
+renamed) echo "other:x:1504:1504::/home/other:/bin/sh" ;;
+short) echo "short:x:1505" ;;
+*) exit 2 ;;
+esac
+`
+
+// helper function to put the fake getent first in the PATH
+func useFakeGetent(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "getent"), []byte(getentScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Function to check which names are accepted as crontab users, with and
+// without an account behind them
+func TestIsValidUsername(t *testing.T) {
+	useFakeGetent(t)
+	defer func() { crontab.RequireSystemUser = true }()
+
+	cases := []struct {
+		Name    string
+		Syntax  bool // accepted without an account
+		Account bool // accepted when an account is required
+	}{
+		{"ci", true, true},
+		{"john.doe", true, true},
+		{"machine$", true, true},
+		{currentUser(t), true, true},
+		{"nobody-here", true, false},
+		{"renamed", true, false},
+		{"short", true, false},
+		{"", false, false},
+		{".", false, false},
+		{"..", false, false},
+		{"-x", false, false},
+		{"-u", false, false},
+		{"a/b", false, false},
+		{"../etc", false, false},
+		{"john doe", false, false},
+		{"ci\n", false, false},
+		{"ci\x00", false, false},
+		{"ci\x1b[0m", false, false},
+		{"tab\tuser", false, false},
+	}
+	for _, c := range cases {
+		crontab.RequireSystemUser = false
+		if err := crontab.IsValidUsername(c.Name); (err == nil) != c.Syntax {
+			t.Errorf("IsValidUsername(%q) without accounts returned %v, expected accepted %t", c.Name, err, c.Syntax)
+		}
+		crontab.RequireSystemUser = true
+		if err := crontab.IsValidUsername(c.Name); (err == nil) != c.Account {
+			t.Errorf("IsValidUsername(%q) with accounts returned %v, expected accepted %t", c.Name, err, c.Account)
+		}
+	}
+}
+
+// Function to check the accounts unknown to os/user are read from getent
+func TestLookupUserGetent(t *testing.T) {
+	useFakeGetent(t)
+
+	account, err := crontab.LookupUser("john.doe")
+	if err != nil {
+		t.Fatalf("Error looking up john.doe: %v", err)
+	}
+	if account.Username != "john.doe" || account.Uid != "1502" || account.Gid != "1502" || account.Name != "John Doe" || account.HomeDir != "/home/john.doe" {
+		t.Errorf("got account %+v, expected the fields of getent", account)
+	}
+
+	// A line of another account or a truncated line is not taken
+	for _, name := range []string{"renamed", "short", "nobody-here"} {
+		if account, err := crontab.LookupUser(name); err == nil {
+			t.Errorf("LookupUser(%q) returned %+v, expected an unknown user", name, account)
+		}
+	}
+
+	// Without getent the unknown user error of os/user is kept
+	t.Setenv("PATH", t.TempDir())
+	if _, err := crontab.LookupUser("john.doe"); err == nil {
+		t.Errorf("LookupUser without getent found john.doe")
+	}
+	if account, err := crontab.LookupUser(currentUser(t)); err != nil || account.Username != currentUser(t) {
+		t.Errorf("LookupUser of the current user returned %v, %v", account, err)
+	}
+}
@@ -0,0 +1,660 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat_mock"
+)
+
+// Token accepted by the fake API
+const apiToken = "e2e-token"
+
+// Time a beatify command of a scenario may run before it is killed
+const commandTimeout = 10 * time.Second
+
+// Environment variables read by the fake crontab binary
+const (
+	spoolEnv       = "BEATIFY_E2E_SPOOL"
+	installFailEnv = "BEATIFY_E2E_INSTALL_FAIL"
+)
+
+// Scenario of the harness, read from a directory holding:
+//
+//	crontab    the crontab of the current user before the first command
+//	setup      optional lines setting up the fake API and crontab binary
+//	commands   the beatify commands to run, "quoted text" making one
+//	           argument, each followed by its answers as "< answer" lines,
+//	           and "! shell command" lines run between them with $CRONTAB
+//	           naming the crontab file, their output going to the transcript
+//	files      optional directory copied to the directory of the scenario,
+//	           such as unit files
+//	golden.txt the expected transcript
+type scenario struct {
+	Name     string
+	Dir      string
+	Crontab  []byte
+	Setup    []string
+	Commands []command
+}
+
+// Command of a scenario with the lines fed to its standard input, or a
+// shell command changing the scenario between two beatify commands
+type command struct {
+	Args  []string
+	Input []string
+	Shell string
+}
+
+// Output lines that change from one run to the next, replaced by a
+// placeholder before comparing transcripts
+var volatile = []struct {
+	Regexp      *regexp.Regexp
+	Placeholder string
+}{
+	{regexp.MustCompile(`http://127\.0\.0\.1:\d+`), "{api}"},
+	{regexp.MustCompile(`\w{3} \d{4}-\d{2}-\d{2} \d{2}:\d{2}`), "{time}"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2})?(Z|[+-]\d{2}:\d{2})?`), "{time}"},
+	{regexp.MustCompile(`\d{8}T?\d{6}(\.\d+)?`), "{timestamp}"},
+	{regexp.MustCompile(`pid \d+`), "pid {pid}"},
+	// Hash of the crontab path in a lock file name
+	{regexp.MustCompile(`-[0-9a-f]{12}\.lock\b`), "-{hash}.lock"},
+	// Random suffix of a temp file name
+	{regexp.MustCompile(`(/\.[A-Za-z][\w.-]*[A-Za-z])\d{6,}\b`), "${1}{random}"},
+	// A duration in a table column, the column width changing with it
+	{regexp.MustCompile(`(\b\d+(\.\d+)?(ms|µs|ns)\b|\b\d+\.\d+s\b|\b0s\b) {2,}`), "{duration}  "},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ms|µs|ns)\b|\b\d+\.\d+s\b|\b0s\b`), "{duration}"},
+	// Unix time of a metric sample, and the duration of a run measured by exec
+	{regexp.MustCompile(`\b1\d{9}\b`), "{unix}"},
+	{regexp.MustCompile(`(_duration_seconds\{.*\}) \d+\.\d+(e-\d+)?`), "${1} {seconds}"},
+}
+
+// Flags of the harness, given after -args or directly to go test
+var (
+	update = flag.Bool("update", false, "Write the transcripts to the golden files instead of comparing them")
+	binary = flag.String("beatify", "", "beatify binary to run, built from the module when empty")
+	keep   = flag.Bool("keep", false, "Keep the temporary directories of the scenarios")
+)
+
+func TestMain(m *testing.M) {
+	// The test binary links itself as the crontab binary of the scenarios
+	if filepath.Base(os.Args[0]) == "crontab" {
+		os.Exit(fakeCrontab(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// Function to run every scenario, go test -run TestScenarios/NAME picking
+// some of them
+func TestScenarios(t *testing.T) {
+	work, err := ioutil.TempDir("", "beatify-e2e")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %v", err)
+	}
+	if *keep {
+		t.Logf("Scenario directories kept in %s", work)
+	} else {
+		defer os.RemoveAll(work)
+	}
+
+	beatify := *binary
+	if beatify == "" {
+		beatify = filepath.Join(work, "beatify")
+		build := exec.Command("go", "build", "-o", beatify, ".")
+		build.Dir = filepath.Join("..", "..")
+		if output, err := build.CombinedOutput(); err != nil {
+			t.Fatalf("Error building beatify: %v\n%s", err, output)
+		}
+	}
+
+	// The scenarios run in their own directory
+	if beatify, err = filepath.Abs(beatify); err != nil {
+		t.Fatalf("Error locating beatify: %v", err)
+	}
+
+	list, err := readScenarios("scenarios")
+	if err != nil {
+		t.Fatalf("Error reading scenarios: %v", err)
+	}
+
+	for _, s := range list {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			if s.needsRoot() && os.Geteuid() != 0 {
+				t.Skip("scenario needs root")
+			}
+			transcript, err := s.run(beatify, filepath.Join(work, s.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join(s.Dir, "golden.txt")
+			if *update {
+				if err := ioutil.WriteFile(golden, transcript, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := firstDifference(expected, transcript); diff != "" {
+				t.Errorf("transcript differs from %s\n%s", golden, diff)
+			}
+		})
+	}
+}
+
+// Function to run a scenario in a fresh directory and return its transcript
+func (s *scenario) run(binary, dir string) ([]byte, error) {
+	spool := filepath.Join(dir, "spool")
+	bin := filepath.Join(dir, "bin")
+	home := filepath.Join(dir, "home")
+	for _, d := range []string{spool, bin, home} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := currentUser()
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(spool, root), s.Crontab, 0600); err != nil {
+		return nil, err
+	}
+	if err := copyDir(filepath.Join(s.Dir, "files"), filepath.Join(dir, "files")); err != nil {
+		return nil, err
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	crontabBinary := filepath.Join(bin, "crontab")
+	if err := os.Symlink(self, crontabBinary); err != nil {
+		return nil, err
+	}
+
+	server := heartbeat_mock.NewServer(apiToken)
+	defer server.Close()
+
+	token := apiToken
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"TMPDIR=" + dir,
+		"TZ=UTC",
+		spoolEnv + "=" + spool,
+	}
+	if err := s.applySetup(server, &token, &env, dir, filepath.Join(spool, root)); err != nil {
+		return nil, err
+	}
+
+	config := fmt.Sprintf("profiles:\n  e2e:\n    api_base_url: %s\n    token: %s\n", server.APIBaseURL(), token)
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte(config), 0600); err != nil {
+		return nil, err
+	}
+
+	globalArgs := []string{
+		"--config", configFile,
+		"--profile", "e2e",
+		"--store", "debian",
+		"--spool-dir", spool,
+		"--crontab-bin", crontabBinary,
+		"--backup-dir", filepath.Join(dir, "backups"),
+		"--lock-dir", filepath.Join(dir, "locks"),
+	}
+
+	var transcript bytes.Buffer
+	for _, c := range s.Commands {
+		if c.Shell != "" {
+			shell := exec.Command("sh", "-c", c.Shell)
+			shell.Env = append(env, "CRONTAB="+filepath.Join(spool, root))
+			shell.Dir = dir
+			output, err := shell.CombinedOutput()
+			if err != nil {
+				return nil, fmt.Errorf("running %s: %w\n%s", c.Shell, err, output)
+			}
+			fmt.Fprintf(&transcript, "! %s\n", c.Shell)
+			transcript.WriteString(ensureNewline(string(output)))
+			transcript.WriteString("\n")
+			continue
+		}
+
+		var output bytes.Buffer
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		cmd := exec.CommandContext(ctx, binary, commandArgs(c.Args, globalArgs)...)
+		// Children left in the background by a command keep its output open
+		cmd.WaitDelay = time.Second
+		cmd.Env = env
+		cmd.Dir = dir
+		// Without a terminal, as from cron, whatever runs the tests
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		cmd.Stdin = strings.NewReader(strings.Join(c.Input, "\n") + "\n")
+		// A shared writer keeps stdout and stderr in the order they were written
+		cmd.Stdout, cmd.Stderr = &output, &output
+
+		exitCode := 0
+		err := cmd.Run()
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("running %s: no exit after %s\n%s", strings.Join(c.Args, " "), commandTimeout, output.String())
+		}
+		if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, fmt.Errorf("running %s: %w", strings.Join(c.Args, " "), err)
+			}
+			exitCode = exitErr.ExitCode()
+		}
+
+		fmt.Fprintf(&transcript, "$ beatify %s\n", joinArgs(c.Args))
+		transcript.WriteString(ensureNewline(output.String()))
+		fmt.Fprintf(&transcript, "exit %d\n\n", exitCode)
+	}
+
+	installs, err := ioutil.ReadFile(filepath.Join(spool, "installs.log"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	transcript.WriteString("--- installed crontabs\n")
+	transcript.Write(installs)
+
+	transcript.WriteString("--- api requests\n")
+	for _, request := range server.Requests() {
+		fmt.Fprintf(&transcript, "%s %s\n", request.Method, request.Path)
+	}
+	transcript.WriteString("--- api state\n")
+	transcript.WriteString(server.String())
+
+	return normalize(transcript.String(), dir, root), nil
+}
+
+// helper function to add the global options to the arguments of a command,
+// before the "--" ending the options of exec; a command giving its own
+// --store keeps it
+func commandArgs(args, globalArgs []string) []string {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--store" || strings.HasPrefix(arg, "--store=") {
+			var kept []string
+			for i := 0; i < len(globalArgs); i++ {
+				if globalArgs[i] == "--store" {
+					i++
+					continue
+				}
+				kept = append(kept, globalArgs[i])
+			}
+			globalArgs = kept
+			break
+		}
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			return append(append(append([]string{}, args[:i]...), globalArgs...), args[i:]...)
+		}
+	}
+	return append(append([]string{}, args...), globalArgs...)
+}
+
+// helper function to apply the setup lines of a scenario:
+//
+//	group NAME                          create a heartbeat group
+//	fail METHOD PATH STATUS [TIMES]     make API requests fail
+//	rate N                              limit the API to N requests per second
+//	per-page N                          paginate the API lists by N items
+//	token VALUE                         give beatify another token
+//	env NAME=VALUE                      set an environment variable of beatify
+//	root                                run the scenario only as root
+//	install-fail                        make the crontab binary refuse installs
+//	edit METHOD PATH SHELL              run a shell command, with $CRONTAB set,
+//	                                    before the first matching API request
+func (s *scenario) applySetup(server *heartbeat_mock.Server, token *string, env *[]string, dir, crontabFile string) error {
+	for _, line := range s.Setup {
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "group" && len(fields) > 1:
+			server.AddHeartbeatGroup(strings.Join(fields[1:], " "))
+		case fields[0] == "fail" && (len(fields) == 4 || len(fields) == 5):
+			failure := heartbeat_mock.Failure{Method: fields[1], Path: fields[2]}
+			var err error
+			if failure.Status, err = strconv.Atoi(fields[3]); err != nil {
+				return fmt.Errorf("setup %q: %w", line, err)
+			}
+			if len(fields) == 5 {
+				if failure.Times, err = strconv.Atoi(fields[4]); err != nil {
+					return fmt.Errorf("setup %q: %w", line, err)
+				}
+			}
+			server.Fail(failure)
+		case fields[0] == "rate" && len(fields) == 2:
+			perSecond, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return fmt.Errorf("setup %q: %w", line, err)
+			}
+			server.SetRateLimit(perSecond)
+		case fields[0] == "per-page" && len(fields) == 2:
+			perPage, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return fmt.Errorf("setup %q: %w", line, err)
+			}
+			server.PerPage = perPage
+		case fields[0] == "token" && len(fields) == 2:
+			*token = fields[1]
+		case fields[0] == "env" && len(fields) == 2 && strings.Contains(fields[1], "="):
+			*env = append(*env, fields[1])
+		case fields[0] == "root" && len(fields) == 1:
+			// Checked before the scenario runs
+		case fields[0] == "install-fail" && len(fields) == 1:
+			*env = append(*env, installFailEnv+"=1")
+		case fields[0] == "edit" && len(fields) > 3:
+			command := strings.Join(fields[3:], " ")
+			server.OnRequest(heartbeat_mock.Hook{Method: fields[1], Path: fields[2], Run: func() {
+				shell := exec.Command("sh", "-c", command)
+				shell.Env = append(os.Environ(), "CRONTAB="+crontabFile)
+				shell.Dir = dir
+				shell.Run()
+			}})
+		default:
+			return fmt.Errorf("unknown setup line %q", line)
+		}
+	}
+	return nil
+}
+
+// helper function to tell a scenario needs root, such as to give a file
+// to another user
+func (s *scenario) needsRoot() bool {
+	for _, line := range s.Setup {
+		if strings.TrimSpace(line) == "root" {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to read the scenarios of a directory, in name order
+func readScenarios(dir string) ([]*scenario, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*scenario
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		s, err := readScenario(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// helper function to read the files of a scenario
+func readScenario(dir string) (*scenario, error) {
+	s := &scenario{Name: filepath.Base(dir), Dir: dir}
+
+	var err error
+	if s.Crontab, err = ioutil.ReadFile(filepath.Join(dir, "crontab")); err != nil {
+		return nil, err
+	}
+
+	if s.Setup, err = readLines(filepath.Join(dir, "setup")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	lines, err := readLines(filepath.Join(dir, "commands"))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if answer, ok := cutAnswer(line); ok {
+			if len(s.Commands) == 0 {
+				return nil, fmt.Errorf("answer %q before the first command", answer)
+			}
+			last := &s.Commands[len(s.Commands)-1]
+			if last.Shell != "" {
+				return nil, fmt.Errorf("answer %q after a shell command", answer)
+			}
+			last.Input = append(last.Input, answer)
+			continue
+		}
+		if shell, ok := strings.CutPrefix(line, "! "); ok {
+			s.Commands = append(s.Commands, command{Shell: shell})
+			continue
+		}
+		s.Commands = append(s.Commands, command{Args: splitArgs(line)})
+	}
+	if len(s.Commands) == 0 {
+		return nil, fmt.Errorf("no command")
+	}
+	return s, nil
+}
+
+// helper function to split a command line into arguments, text between
+// double quotes making one argument
+func splitArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	quoted, started := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted, started = !quoted, true
+		case r == ' ' && !quoted:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// helper function to write arguments back as a command line
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.Contains(arg, " ") {
+			quoted[i] = `"` + arg + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// helper function to read an answer line, "<" alone answers with an empty line
+func cutAnswer(line string) (string, bool) {
+	if line == "<" {
+		return "", true
+	}
+	return strings.CutPrefix(line, "< ")
+}
+
+// helper function to read the lines of a file, leaving out blank lines and
+// comments
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Function acting as the crontab binary: lists and installs the crontabs of
+// the spool directory, and records every install as the cron daemon reload
+func fakeCrontab(args []string) int {
+	spool := os.Getenv(spoolEnv)
+	crontabUser, err := currentUser()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "crontab:", err)
+		return 1
+	}
+
+	if len(args) > 1 && args[0] == "-u" {
+		crontabUser, args = args[1], args[2:]
+	}
+	if len(args) != 1 || (args[0] != "-l" && args[0] != "-") {
+		fmt.Fprintln(os.Stderr, "usage: crontab [-u user] -l | -")
+		return 1
+	}
+	path := filepath.Join(spool, crontabUser)
+
+	if args[0] == "-l" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "no crontab for %s\n", crontabUser)
+			return 1
+		}
+		os.Stdout.Write(content)
+		return 0
+	}
+
+	content, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "crontab:", err)
+		return 1
+	}
+	if os.Getenv(installFailEnv) != "" {
+		fmt.Fprintln(os.Stderr, "crontab: installation failed")
+		return 1
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, "crontab:", err)
+		return 1
+	}
+
+	log, err := os.OpenFile(filepath.Join(spool, "installs.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "crontab:", err)
+		return 1
+	}
+	defer log.Close()
+	fmt.Fprintf(log, "# %s\n%s", crontabUser, ensureNewline(string(content)))
+	return 0
+}
+
+// helper function to copy the files of a scenario, nothing being copied
+// when the scenario has none
+func copyDir(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == from {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, content, info.Mode().Perm())
+	})
+}
+
+// helper function to get the name of the user running the harness
+func currentUser() (string, error) {
+	current, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return current.Username, nil
+}
+
+// helper function to replace what changes from one run to the next,
+// including the user running the harness
+func normalize(transcript, dir, crontabUser string) []byte {
+	transcript = strings.ReplaceAll(transcript, dir, "{dir}")
+	transcript = regexp.MustCompile(`\b`+regexp.QuoteMeta(crontabUser)+`\b`).ReplaceAllString(transcript, "{user}")
+	for _, v := range volatile {
+		transcript = v.Regexp.ReplaceAllString(transcript, v.Placeholder)
+	}
+	return []byte(transcript)
+}
+
+// helper function to end a non-empty text with a newline
+func ensureNewline(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
+
+// helper function to describe the first line where two transcripts differ
+func firstDifference(expected, actual []byte) string {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want != got || i >= len(expectedLines) || i >= len(actualLines) {
+			return fmt.Sprintf("     line %d\n     want: %s\n     got:  %s\n", i+1, want, got)
+		}
+	}
+	return ""
+}
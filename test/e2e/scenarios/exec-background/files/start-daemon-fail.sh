@@ -0,0 +1,4 @@
+#!/bin/sh
+sleep 30 &
+echo "daemon started, but the job failed" >&2
+exit 3
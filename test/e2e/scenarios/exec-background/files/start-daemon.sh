@@ -0,0 +1,5 @@
+#!/bin/sh
+# Starts a daemon left running once the script exits, like a job
+# restarting a service would
+sleep 30 &
+echo "daemon started"
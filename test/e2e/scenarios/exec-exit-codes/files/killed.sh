@@ -0,0 +1,4 @@
+#!/bin/sh
+# Dies from SIGKILL, like a job killed by the OOM killer
+echo "about to be killed"
+kill -9 $$
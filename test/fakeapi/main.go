@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/IT-JONCTION/beatify/heartbeat_mock"
+)
+
+func main() {
+	token := flag.String("token", "test-token", "Team API token accepted by the fake API")
+	perPage := flag.Int("per-page", 50, "Number of items per page of the list endpoints")
+	rateLimit := flag.Int("rate", 0, "Requests per second before answering 429, 0 for no limit")
+	groups := flag.String("group", "", "Heartbeat group to create at start")
+	var failures []heartbeat_mock.Failure
+	flag.Func("fail", "Failure to inject as METHOD:PATH:STATUS[:TIMES], e.g. POST:/heartbeats:500:1", func(value string) error {
+		failure, err := parseFailure(value)
+		failures = append(failures, failure)
+		return err
+	})
+	flag.Parse()
+
+	server := heartbeat_mock.NewServer(*token)
+	defer server.Close()
+	server.PerPage = *perPage
+	server.SetRateLimit(*rateLimit)
+	for _, failure := range failures {
+		server.Fail(failure)
+	}
+	if *groups != "" {
+		server.AddHeartbeatGroup(*groups)
+	}
+
+	// The base URL goes in the api_base_url of a profile
+	fmt.Println(server.APIBaseURL())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	// Leave the state of the fake API behind for inspection
+	fmt.Print(server)
+}
+
+// helper function to read a failure given as METHOD:PATH:STATUS[:TIMES]
+func parseFailure(value string) (heartbeat_mock.Failure, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return heartbeat_mock.Failure{}, fmt.Errorf("expected METHOD:PATH:STATUS[:TIMES]")
+	}
+
+	failure := heartbeat_mock.Failure{Method: strings.ToUpper(parts[0]), Path: parts[1]}
+	var err error
+	if failure.Status, err = strconv.Atoi(parts[2]); err != nil {
+		return failure, fmt.Errorf("invalid status '%s'", parts[2])
+	}
+	if len(parts) == 4 {
+		if failure.Times, err = strconv.Atoi(parts[3]); err != nil {
+			return failure, fmt.Errorf("invalid count '%s'", parts[3])
+		}
+	}
+	return failure, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/heartbeat_mock"
+)
+
+// Function to check the failures given with -fail
+func TestParseFailure(t *testing.T) {
+	cases := []struct {
+		Value    string
+		Expected heartbeat_mock.Failure
+		Error    bool
+	}{
+		{"POST:/heartbeats:500", heartbeat_mock.Failure{Method: "POST", Path: "/heartbeats", Status: 500}, false},
+		{"get:/heartbeat-groups:429:2", heartbeat_mock.Failure{Method: "GET", Path: "/heartbeat-groups", Status: 429, Times: 2}, false},
+		{"POST:/heartbeats", heartbeat_mock.Failure{}, true},
+		{"POST:/heartbeats:oops", heartbeat_mock.Failure{}, true},
+		{"POST:/heartbeats:500:twice", heartbeat_mock.Failure{}, true},
+	}
+
+	for _, c := range cases {
+		failure, err := parseFailure(c.Value)
+		if c.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", c.Value, failure)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.Value, err)
+			continue
+		}
+		if failure != c.Expected {
+			t.Errorf("%s: got %+v, want %+v", c.Value, failure, c.Expected)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/heartbeat_mock"
+)
+
+// Token of the fake API under test
+const token = "test-token"
+
+// helper function to send a request to the fake API with a token, decoding
+// the response into result when given, and return the status
+func request(t *testing.T, server *heartbeat_mock.Server, method, path, authToken, body string, result interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.APIBaseURL()+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("%s %s: invalid response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Function to check requests without the token are refused and recorded
+func TestServerAuth(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+
+	if code := request(t, server, http.MethodGet, "/heartbeats", "wrong", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("request with a wrong token returned %d, expected 401", code)
+	}
+	if code := request(t, server, http.MethodGet, "/heartbeats", token, "", nil); code != http.StatusOK {
+		t.Errorf("request with the token returned %d, expected 200", code)
+	}
+	if requests := server.Requests(); len(requests) != 2 || requests[0].Path != "/heartbeats" {
+		t.Errorf("got requests %v, expected both recorded", requests)
+	}
+}
+
+// Function to check the lists are paginated and the client follows the pages
+func TestServerPagination(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	defer server.Use()()
+	server.PerPage = 2
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: name, Period: 60})
+	}
+
+	var page heartbeat.HeartbeatsResponse
+	request(t, server, http.MethodGet, "/heartbeats?page=2", token, "", &page)
+	if len(page.Data) != 2 || page.Data[0].Attributes.Name != "c" {
+		t.Errorf("page 2 holds %v, expected c and d", page.Data)
+	}
+	if page.Pagination.Prev == "" || page.Pagination.Next == "" || !strings.Contains(page.Pagination.Last, "page=3") {
+		t.Errorf("page 2 has links %+v, expected prev, next and last on page 3", page.Pagination)
+	}
+	request(t, server, http.MethodGet, "/heartbeats?page=3", token, "", &page)
+	if len(page.Data) != 1 || page.Pagination.Next != "" {
+		t.Errorf("last page holds %d heartbeats and next %q, expected 1 and none", len(page.Data), page.Pagination.Next)
+	}
+
+	list, err := heartbeat.ListHeartbeats(token)
+	if err != nil {
+		t.Fatalf("Error listing heartbeats: %v", err)
+	}
+	if len(list) != 5 {
+		t.Errorf("listed %d heartbeats across the pages, expected 5", len(list))
+	}
+}
+
+// Function to check injected failures hit the matching requests, as many
+// times as asked
+func TestServerFailures(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	server.Fail(heartbeat_mock.Failure{Method: http.MethodPost, Path: "/heartbeats", Status: http.StatusInternalServerError, Times: 1})
+	server.Fail(heartbeat_mock.Failure{Path: "/heartbeat-groups", Status: http.StatusBadGateway})
+
+	body := `{"name":"backup","period":60}`
+	if code := request(t, server, http.MethodPost, "/heartbeats", token, body, nil); code != http.StatusInternalServerError {
+		t.Errorf("first creation returned %d, expected 500", code)
+	}
+	if code := request(t, server, http.MethodPost, "/heartbeats", token, body, nil); code != http.StatusCreated {
+		t.Errorf("second creation returned %d, expected 201", code)
+	}
+	if code := request(t, server, http.MethodGet, "/heartbeats", token, "", nil); code != http.StatusOK {
+		t.Errorf("list with a failure on POST returned %d, expected 200", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := request(t, server, http.MethodGet, "/heartbeat-groups", token, "", nil); code != http.StatusBadGateway {
+			t.Errorf("group list %d returned %d, expected every one to fail with 502", i, code)
+		}
+	}
+	if heartbeats := server.Heartbeats(); len(heartbeats) != 1 {
+		t.Errorf("%d heartbeats held, expected the one created", len(heartbeats))
+	}
+}
+
+// Function to check the rate limit answers 429 beyond its burst and can be
+// lifted
+func TestServerRateLimit(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	server.SetRateLimit(2)
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		codes = append(codes, request(t, server, http.MethodGet, "/heartbeats", token, "", nil))
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("got statuses %v, expected 200 200 429", codes)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	if code := request(t, server, http.MethodGet, "/heartbeats", token, "", nil); code != http.StatusOK {
+		t.Errorf("request once the limit refilled returned %d, expected 200", code)
+	}
+
+	server.SetRateLimit(0)
+	for i := 0; i < 10; i++ {
+		if code := request(t, server, http.MethodGet, "/heartbeats", token, "", nil); code != http.StatusOK {
+			t.Fatalf("request %d without limit returned %d", i, code)
+		}
+	}
+}
+
+// Function to check a hook runs once, before its request is answered, and
+// may look at the server
+func TestServerHooks(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: "backup", Period: 60})
+
+	runs := 0
+	seen := -1
+	server.OnRequest(heartbeat_mock.Hook{Method: http.MethodPost, Path: "/heartbeats", Run: func() {
+		runs++
+		seen = len(server.Heartbeats())
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		request(t, server, http.MethodGet, "/heartbeats", token, "", nil)
+		request(t, server, http.MethodPost, "/heartbeats", token, `{"name":"report","period":60}`, nil)
+		request(t, server, http.MethodPost, "/heartbeats", token, `{"name":"cleanup","period":60}`, nil)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a hook reading the server blocked its request")
+	}
+
+	if runs != 1 {
+		t.Errorf("hook ran %d times, expected once", runs)
+	}
+	if seen != 1 {
+		t.Errorf("hook saw %d heartbeats, expected the one created before its request", seen)
+	}
+}
+
+// Function to check the pings set the status of their heartbeat
+func TestServerPings(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	hb := server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: "backup", Period: 60})
+
+	if err := heartbeat.Ping(hb.Attributes.URL, 0); err != nil {
+		t.Fatalf("Error pinging: %v", err)
+	}
+	if status := server.Heartbeats()[0].Attributes.Status; status != "up" {
+		t.Errorf("heartbeat is %s after a successful ping, expected up", status)
+	}
+	if err := heartbeat.Ping(hb.Attributes.URL, 3); err != nil {
+		t.Fatalf("Error pinging: %v", err)
+	}
+	if status := server.Heartbeats()[0].Attributes.Status; status != "down" {
+		t.Errorf("heartbeat is %s after a failed ping, expected down", status)
+	}
+	pings := server.Pings()
+	if len(pings) != 2 || pings[1].HeartbeatID != hb.ID || pings[1].ExitCode != 3 {
+		t.Errorf("got pings %+v, expected a success then exit code 3", pings)
+	}
+}
@@ -0,0 +1,126 @@
+package heartbeat_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/heartbeat_mock"
+)
+
+// Token of the fake API under test
+const token = "secret"
+
+// Function to check the heartbeat calls of the API client against the fake
+// API: get, update, pause, resume and delete
+func TestHeartbeatCRUD(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	defer server.Use()()
+
+	created := server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: "backup", Period: 86400, Grace: 600})
+
+	got, err := heartbeat.GetHeartbeat(token, created.ID)
+	if err != nil {
+		t.Fatalf("Error getting heartbeat: %v", err)
+	}
+	if got.Attributes.Name != "backup" || got.Attributes.URL != created.Attributes.URL {
+		t.Errorf("got heartbeat %+v, expected %+v", got.Attributes, created.Attributes)
+	}
+
+	name, period := "nightly backup", 3600
+	updated, err := heartbeat.UpdateHeartbeat(token, created.ID, heartbeat.HeartbeatRequest{Name: &name, Period: &period})
+	if err != nil {
+		t.Fatalf("Error updating heartbeat: %v", err)
+	}
+	if updated.Attributes.Name != name || updated.Attributes.Period != period || updated.Attributes.Grace != 600 {
+		t.Errorf("updated heartbeat is %+v, expected the name and period changed only", updated.Attributes)
+	}
+
+	paused, err := heartbeat.PauseHeartbeat(token, created.ID)
+	if err != nil {
+		t.Fatalf("Error pausing heartbeat: %v", err)
+	}
+	if paused.Attributes.Status != "paused" || paused.Attributes.PausedAt == "" {
+		t.Errorf("paused heartbeat has status %q and paused_at %q", paused.Attributes.Status, paused.Attributes.PausedAt)
+	}
+	resumed, err := heartbeat.ResumeHeartbeat(token, created.ID)
+	if err != nil {
+		t.Fatalf("Error resuming heartbeat: %v", err)
+	}
+	if resumed.Attributes.Status == "paused" || resumed.Attributes.PausedAt != "" {
+		t.Errorf("resumed heartbeat has status %q and paused_at %q", resumed.Attributes.Status, resumed.Attributes.PausedAt)
+	}
+
+	if err := heartbeat.DeleteHeartbeat(token, created.ID); err != nil {
+		t.Fatalf("Error deleting heartbeat: %v", err)
+	}
+	if _, err := heartbeat.GetHeartbeat(token, created.ID); !errors.Is(err, heartbeat.ErrNotFound) {
+		t.Errorf("getting a deleted heartbeat returned %v, expected not found", err)
+	}
+	if err := heartbeat.DeleteHeartbeat(token, created.ID); !errors.Is(err, heartbeat.ErrNotFound) {
+		t.Errorf("deleting a deleted heartbeat returned %v, expected not found", err)
+	}
+	if _, err := heartbeat.GetHeartbeat("wrong", "1"); !errors.Is(err, heartbeat.ErrUnauthorized) {
+		t.Errorf("getting a heartbeat with a wrong token returned %v, expected unauthorized", err)
+	}
+}
+
+// Function to check the heartbeat group calls of the API client against the
+// fake API: get, update, pause, resume and delete with its heartbeats
+func TestHeartbeatGroupCRUD(t *testing.T) {
+	server := heartbeat_mock.NewServer(token)
+	defer server.Close()
+	defer server.Use()()
+
+	group := server.AddHeartbeatGroup("backups")
+	groupID, _ := strconv.Atoi(group.ID)
+	grouped := server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: "backup", Period: 86400, HeartbeatGroupID: groupID})
+	other := server.AddHeartbeat(heartbeat.HeartbeatAttributes{Name: "report", Period: 3600})
+
+	got, err := heartbeat.GetHeartbeatGroup(token, group.ID)
+	if err != nil {
+		t.Fatalf("Error getting heartbeat group: %v", err)
+	}
+	if got.Attributes.Name != "backups" {
+		t.Errorf("got heartbeat group %q, expected backups", got.Attributes.Name)
+	}
+
+	name := "nightly"
+	updated, err := heartbeat.UpdateHeartbeatGroup(token, group.ID, heartbeat.HeartbeatGroupRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("Error updating heartbeat group: %v", err)
+	}
+	if updated.Attributes.Name != name || updated.Attributes.Paused {
+		t.Errorf("updated heartbeat group is %+v, expected the name changed only", updated.Attributes)
+	}
+
+	paused, err := heartbeat.PauseHeartbeatGroup(token, group.ID)
+	if err != nil {
+		t.Fatalf("Error pausing heartbeat group: %v", err)
+	}
+	if !paused.Attributes.Paused {
+		t.Errorf("paused heartbeat group is not paused")
+	}
+	resumed, err := heartbeat.ResumeHeartbeatGroup(token, group.ID)
+	if err != nil {
+		t.Fatalf("Error resuming heartbeat group: %v", err)
+	}
+	if resumed.Attributes.Paused {
+		t.Errorf("resumed heartbeat group is still paused")
+	}
+
+	if err := heartbeat.DeleteHeartbeatGroup(token, group.ID); err != nil {
+		t.Fatalf("Error deleting heartbeat group: %v", err)
+	}
+	if _, err := heartbeat.GetHeartbeatGroup(token, group.ID); !errors.Is(err, heartbeat.ErrNotFound) {
+		t.Errorf("getting a deleted heartbeat group returned %v, expected not found", err)
+	}
+	if _, err := heartbeat.GetHeartbeat(token, grouped.ID); !errors.Is(err, heartbeat.ErrNotFound) {
+		t.Errorf("heartbeat of the deleted group is still there: %v", err)
+	}
+	if _, err := heartbeat.GetHeartbeat(token, other.ID); err != nil {
+		t.Errorf("heartbeat outside the deleted group is gone: %v", err)
+	}
+}
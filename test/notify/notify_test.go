@@ -0,0 +1,297 @@
+package notify_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/config"
+	"github.com/IT-JONCTION/beatify/notify"
+)
+
+// Messages received by the stand-ins, by notifier name
+type inbox struct {
+	mu       sync.Mutex
+	messages map[string][]string
+}
+
+func (i *inbox) add(name, message string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.messages[name] = append(i.messages[name], message)
+}
+
+// Function to take the messages received since the last call
+func (i *inbox) take() map[string][]string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	messages := i.messages
+	i.messages = map[string][]string{}
+	return messages
+}
+
+// Function to check the deliveries, routes, dedup and reminders of the
+// notifiers against local stand-ins
+func TestNotifiers(t *testing.T) {
+	received := &inbox{messages: map[string][]string{}}
+
+	smtpAddr, err := startSMTPServer(func(message string) { received.add("mail", message) })
+	if err != nil {
+		t.Fatalf("Error starting SMTP server: %v", err)
+	}
+	webhook := httptest.NewServer(recordBody(received, "webhook"))
+	defer webhook.Close()
+	slack := httptest.NewServer(recordBody(received, "slack"))
+	defer slack.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	dir := t.TempDir()
+	scriptLog := filepath.Join(dir, "script.log")
+
+	dispatcher, err := notify.NewDispatcher(config.Alerting{
+		Reminder: "1h",
+		Dedup:    "10m",
+		Notifiers: map[string]config.NotifierConfig{
+			"mail":    {Type: "smtp", Server: smtpAddr, From: "beatify@example.test", To: []string{"ops@example.test"}},
+			"webhook": {Type: "webhook", URL: webhook.URL},
+			"slack":   {Type: "slack", URL: slack.URL},
+			"script":  {Type: "script", Command: `echo "$BEATIFY_EVENT $BEATIFY_CHECK $BEATIFY_EXIT_CODE $BEATIFY_REMINDER" >> ` + scriptLog},
+			"broken":  {Type: "webhook", URL: broken.URL},
+		},
+		Routes: []config.Route{
+			{Match: "backup*", Notifiers: []string{"mail", "script"}},
+			{Events: []string{"down", "failed"}, Notifiers: []string{"slack"}},
+			{Notifiers: []string{"webhook"}},
+			{Match: "broken", Notifiers: []string{"broken"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error building dispatcher: %v", err)
+	}
+
+	start := time.Date(2026, 1, 5, 3, 0, 0, 0, time.UTC)
+	backupFailed := notify.Alert{Event: notify.EventFailed, CheckID: "1", Check: "backup db", IncidentID: "10", ExitCode: 3, StartedAt: start, Time: start}
+	backupRecovered := backupFailed
+	backupRecovered.Event, backupRecovered.ExitCode, backupRecovered.Time = notify.EventRecovered, 0, start.Add(5*time.Minute)
+	cleanupDown := notify.Alert{Event: notify.EventDown, CheckID: "2", Check: "cleanup", IncidentID: "11", StartedAt: start, Time: start}
+	cleanupRecovered := cleanupDown
+	cleanupRecovered.Event, cleanupRecovered.Time = notify.EventRecovered, start.Add(time.Minute)
+	cleanupFlap := cleanupDown
+	cleanupFlap.IncidentID, cleanupFlap.Time = "12", start.Add(2*time.Minute)
+
+	check := func(title string, errs []error, expected map[string]int, contains map[string]string) {
+		// The script and SMTP deliveries are synchronous, give the
+		// stand-ins a moment all the same
+		time.Sleep(50 * time.Millisecond)
+		messages := received.take()
+
+		var problems []string
+		for _, err := range errs {
+			problems = append(problems, err.Error())
+		}
+		if content, err := ioutil.ReadFile(scriptLog); err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				messages["script"] = append(messages["script"], line)
+			}
+			os.Remove(scriptLog)
+		}
+		for _, name := range []string{"mail", "webhook", "slack", "script", "broken"} {
+			if len(messages[name]) != expected[name] {
+				problems = append(problems, fmt.Sprintf("%s received %d alerts, expected %d", name, len(messages[name]), expected[name]))
+			}
+			if want, ok := contains[name]; ok && (len(messages[name]) == 0 || !strings.Contains(messages[name][0], want)) {
+				problems = append(problems, fmt.Sprintf("%s alert does not contain %q: %q", name, want, messages[name]))
+			}
+		}
+
+		if len(problems) > 0 {
+			t.Errorf("%s\n%s", title, strings.Join(problems, "\n"))
+		}
+	}
+
+	check("failed run is routed by name and event",
+		dispatcher.Dispatch(backupFailed),
+		map[string]int{"mail": 1, "script": 1, "slack": 1, "webhook": 1},
+		map[string]string{
+			"mail":    "Subject: [beatify] backup db is down: its run failed with exit code 3 (incident 10)",
+			"webhook": `"event":"failed"`,
+			"slack":   `"text":":red_circle: backup db is down`,
+			"script":  "failed backup db 3 false",
+		})
+	backupSlash := notify.Alert{Event: notify.EventFailed, CheckID: "4", Check: "backup/db", IncidentID: "14", ExitCode: 1, StartedAt: start, Time: start}
+	check("route pattern matches across slashes",
+		dispatcher.Dispatch(backupSlash),
+		map[string]int{"mail": 1, "script": 1, "slack": 1, "webhook": 1},
+		map[string]string{"script": "failed backup/db 1 false"})
+	backupSlashRecovered := backupSlash
+	backupSlashRecovered.Event, backupSlashRecovered.ExitCode, backupSlashRecovered.Time = notify.EventRecovered, 0, start.Add(time.Minute)
+	check("recovery of the heartbeat with a slash",
+		dispatcher.Dispatch(backupSlashRecovered),
+		map[string]int{"mail": 1, "script": 1, "webhook": 1},
+		nil)
+	check("recovery skips the routes limited to down and failed",
+		dispatcher.Dispatch(backupRecovered),
+		map[string]int{"mail": 1, "script": 1, "webhook": 1},
+		map[string]string{"mail": "backup db recovered after 5m0s", "script": "recovered backup db 0 false"})
+	check("missed run of another heartbeat",
+		dispatcher.Dispatch(cleanupDown),
+		map[string]int{"slack": 1, "webhook": 1},
+		map[string]string{"webhook": "cleanup is down: it missed its run"})
+	check("recovery goes to the routes without event filter",
+		dispatcher.Dispatch(cleanupRecovered),
+		map[string]int{"webhook": 1},
+		nil)
+	check("repeated event within the dedup window is dropped",
+		dispatcher.Dispatch(cleanupFlap),
+		map[string]int{},
+		nil)
+	check("open incidents are reminded of after the interval",
+		dispatcher.Remind(start.Add(63*time.Minute)),
+		map[string]int{"slack": 1, "webhook": 1},
+		map[string]string{"webhook": `"reminder":true`})
+	check("no second reminder before the interval",
+		dispatcher.Remind(start.Add(90*time.Minute)),
+		map[string]int{},
+		nil)
+	dispatcher.Forget(cleanupFlap.IncidentID)
+	check("no reminder of a forgotten incident",
+		dispatcher.Remind(start.Add(3*time.Hour)),
+		map[string]int{},
+		nil)
+	brokenDown := notify.Alert{Event: notify.EventDown, CheckID: "3", Check: "broken", IncidentID: "13", StartedAt: start, Time: start}
+	errs := dispatcher.Dispatch(brokenDown)
+	if len(errs) == 1 && strings.Contains(errs[0].Error(), "502") {
+		errs = nil
+	} else {
+		errs = append(errs, fmt.Errorf("expected one 502 error"))
+	}
+	check("failing notifier reports an error without stopping the others",
+		errs,
+		map[string]int{"slack": 1, "webhook": 1},
+		nil)
+}
+
+// helper function to build a handler recording the request bodies
+func recordBody(received *inbox, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !json.Valid(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received.add(name, string(body))
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Function to start an SMTP server on a local port accepting every mail,
+// returning its address
+func startSMTPServer(deliver func(message string)) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, deliver)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// helper function to hold an SMTP session, just enough for net/smtp
+func serveSMTP(conn net.Conn, deliver func(message string)) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(strings.TrimSpace(line) + " x")[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			deliver(message.String())
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Function to check a mail server that accepts the connection but never
+// answers fails the alert within the timeout
+func TestSMTPTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Hold the connection open without a greeting
+			defer conn.Close()
+		}
+	}()
+
+	timeout := notify.Timeout
+	notify.Timeout = 200 * time.Millisecond
+	defer func() { notify.Timeout = timeout }()
+
+	notifier, err := notify.NewSMTPNotifier(config.NotifierConfig{Server: listener.Addr().String(), From: "beatify@example.com", To: []string{"ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = notifier.Notify(notify.Alert{Event: notify.EventDown, CheckID: "1", Check: "backup", IncidentID: "2", StartedAt: start, Time: start})
+	if err == nil {
+		t.Fatal("sending to a mail server that never answers succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("sending gave up after %s, expected about %s", elapsed, notify.Timeout)
+	}
+}
@@ -0,0 +1,291 @@
+package receiver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IT-JONCTION/beatify/heartbeat"
+	"github.com/IT-JONCTION/beatify/receiver"
+)
+
+// Token of the API of the receivers under test
+const token = "secret"
+
+// Harness drives a receiver through its handler on a clock it sets
+type harness struct {
+	t        *testing.T
+	receiver *receiver.Receiver
+	now      time.Time
+	events   []string
+}
+
+// helper function to start a receiver on a state file
+func startReceiver(t *testing.T, stateFile string, now time.Time) *harness {
+	r, err := receiver.New(stateFile, token, "http://monitor.test")
+	if err != nil {
+		t.Fatalf("Error starting receiver: %v", err)
+	}
+	h := &harness{t: t, receiver: r, now: now}
+	r.Now = func() time.Time { return h.now }
+	r.OnEvent = func(event receiver.Event) {
+		h.events = append(h.events, fmt.Sprintf("%s %s %s %d %s", event.Type, event.Check.Name, event.Incident.Cause, event.Incident.ExitCode, event.Incident.Output))
+	}
+	return h
+}
+
+// helper function to send a request to the handler, decoding the response
+// into result when given
+func (h *harness) request(method, path, body string, result interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if strings.HasPrefix(path, receiver.APIPath) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	h.receiver.Handler().ServeHTTP(recorder, req)
+	if result != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			h.t.Fatalf("%s %s: invalid response %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+// helper function to ping a heartbeat URL with an optional suffix
+func (h *harness) ping(hb heartbeat.Heartbeat, suffix, output string) int {
+	path := strings.TrimPrefix(hb.Attributes.URL, "http://monitor.test")
+	if suffix != "" {
+		path += "/" + suffix
+	}
+	return h.request(http.MethodPost, path, output, nil)
+}
+
+// helper function to check the events raised since the last check
+func (h *harness) expectEvents(title string, expected ...string) {
+	h.t.Helper()
+	if got, want := strings.Join(h.events, "\n"), strings.Join(expected, "\n"); got != want {
+		h.t.Errorf("%s: got events:\n%s\nexpected:\n%s", title, got, want)
+	}
+	h.events = nil
+}
+
+// helper function to check the status of a heartbeat
+func (h *harness) expectStatus(title string, id, expected string) {
+	h.t.Helper()
+	var response heartbeat.HeartbeatResponse
+	if code := h.request(http.MethodGet, receiver.APIPath+"/heartbeats/"+id, "", &response); code != http.StatusOK {
+		h.t.Fatalf("%s: getting heartbeat %s returned %d", title, id, code)
+	}
+	if response.Data.Attributes.Status != expected {
+		h.t.Errorf("%s: heartbeat %s is %s, expected %s", title, id, response.Data.Attributes.Status, expected)
+	}
+}
+
+// Function to check the API, the pings, the incidents and the state file of
+// the receiver served by beatify serve
+func TestReceiver(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	h := startReceiver(t, stateFile, start)
+
+	if code := h.request(http.MethodGet, receiver.APIPath+"/heartbeats", "", nil); code != http.StatusOK {
+		t.Errorf("listing heartbeats returned %d", code)
+	}
+	req := httptest.NewRequest(http.MethodGet, receiver.APIPath+"/heartbeats", nil)
+	recorder := httptest.NewRecorder()
+	h.receiver.Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("request without token returned %d, expected 401", recorder.Code)
+	}
+
+	var group heartbeat.HeartbeatGroupResponse
+	if code := h.request(http.MethodPost, receiver.APIPath+"/heartbeat-groups", `{"name":"nightly"}`, &group); code != http.StatusCreated {
+		t.Fatalf("creating group returned %d", code)
+	}
+
+	// A heartbeat with a schedule awaits its next run, one without a
+	// period after the last ping
+	var backup, report heartbeat.HeartbeatResponse
+	body := fmt.Sprintf(`{"name":"backup","period":86400,"grace":600,"schedule":"CRON_TZ=UTC 0 2 * * *","heartbeat_group_id":%q}`, group.Data.ID)
+	if code := h.request(http.MethodPost, receiver.APIPath+"/heartbeats", body, &backup); code != http.StatusCreated {
+		t.Fatalf("creating heartbeat with schedule returned %d", code)
+	}
+	if code := h.request(http.MethodPost, receiver.APIPath+"/heartbeats", `{"name":"report","period":3600,"grace":60}`, &report); code != http.StatusCreated {
+		t.Fatalf("creating heartbeat returned %d", code)
+	}
+	if code := h.request(http.MethodPost, receiver.APIPath+"/heartbeats", `{"name":"broken","period":60,"schedule":"61 * * * *"}`, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("creating heartbeat with invalid schedule returned %d, expected 422", code)
+	}
+	h.expectStatus("created", backup.Data.ID, receiver.StatusPending)
+
+	h.now = start.Add(time.Hour)
+	if open := h.receiver.Evaluate(); open != 0 {
+		t.Errorf("%d incidents open before any deadline", open)
+	}
+	h.expectEvents("before any deadline")
+
+	h.now = start.Add(90 * time.Minute)
+	if open := h.receiver.Evaluate(); open != 1 {
+		t.Errorf("%d incidents open after the missed run, expected 1", open)
+	}
+	h.expectEvents("missed run", "down report missed 0 ")
+	h.expectStatus("missed run", report.Data.ID, receiver.StatusDown)
+
+	h.now = start.Add(105 * time.Minute)
+	h.ping(report.Data, "", "")
+	h.expectEvents("recovery", "recovered report missed 0 ")
+	h.expectStatus("recovery", report.Data.ID, receiver.StatusUp)
+
+	h.now = start.Add(125 * time.Minute)
+	if code := h.ping(backup.Data, "fail", "disk full"); code != http.StatusOK {
+		t.Errorf("fail ping returned %d", code)
+	}
+	h.expectEvents("fail ping", "failed backup failed 1 disk full")
+	h.now = start.Add(126 * time.Minute)
+	h.ping(backup.Data, "3", "")
+	h.expectEvents("exit code ping with an open incident")
+	if checks := h.receiver.Checks(); checks[0].LastExitCode != 3 {
+		t.Errorf("last exit code is %d, expected 3", checks[0].LastExitCode)
+	}
+	h.now = start.Add(150 * time.Minute)
+	h.ping(backup.Data, "0", "")
+	h.expectEvents("exit code 0 ping", "recovered backup failed 1 disk full")
+	h.expectStatus("exit code 0 ping", backup.Data.ID, receiver.StatusUp)
+
+	if code := h.ping(backup.Data, "later", ""); code != http.StatusNotFound {
+		t.Errorf("ping with an invalid suffix returned %d, expected 404", code)
+	}
+	if code := h.request(http.MethodGet, receiver.PingPath+"unknown", "", nil); code != http.StatusNotFound {
+		t.Errorf("ping of an unknown heartbeat returned %d, expected 404", code)
+	}
+
+	// A paused heartbeat raises nothing
+	if code := h.request(http.MethodPatch, receiver.APIPath+"/heartbeats/"+report.Data.ID, `{"paused":true}`, nil); code != http.StatusOK {
+		t.Fatalf("pausing heartbeat returned %d", code)
+	}
+	h.expectStatus("pause", report.Data.ID, receiver.StatusPaused)
+
+	// The next backup is due at 02:00 the next day, with 10 minutes of grace
+	h.now = start.Add(24*time.Hour + 129*time.Minute)
+	if open := h.receiver.Evaluate(); open != 0 {
+		t.Errorf("%d incidents open within the grace of the next run", open)
+	}
+	h.now = start.Add(24*time.Hour + 131*time.Minute)
+	if open := h.receiver.Evaluate(); open != 1 {
+		t.Errorf("%d incidents open after the missed backup, expected 1", open)
+	}
+	h.expectEvents("missed scheduled run", "down backup missed 0 ")
+
+	// A restarted receiver keeps its heartbeats and open incidents
+	checks, incidents := h.receiver.Checks(), h.receiver.Incidents()
+	h = startReceiver(t, stateFile, h.now.Add(time.Minute))
+	if got, want := fmt.Sprint(h.receiver.Checks()), fmt.Sprint(checks); got != want {
+		t.Errorf("restart: got checks\n%s\nexpected\n%s", got, want)
+	}
+	if got, want := fmt.Sprint(h.receiver.Incidents()), fmt.Sprint(incidents); got != want {
+		t.Errorf("restart: got incidents\n%s\nexpected\n%s", got, want)
+	}
+	h.ping(backup.Data, "", "")
+	h.expectEvents("recovery after restart", "recovered backup missed 0 ")
+	var other heartbeat.HeartbeatGroupResponse
+	h.request(http.MethodPost, receiver.APIPath+"/heartbeat-groups", `{"name":"other"}`, &other)
+	if other.Data.ID == group.Data.ID || other.Data.ID == backup.Data.ID || other.Data.ID == report.Data.ID {
+		t.Errorf("restart: new group reuses ID %s", other.Data.ID)
+	}
+
+	// A paused group pauses its heartbeats
+	if code := h.request(http.MethodPatch, receiver.APIPath+"/heartbeat-groups/"+group.Data.ID, `{"paused":true}`, nil); code != http.StatusOK {
+		t.Fatalf("pausing group returned %d", code)
+	}
+	h.now = start.Add(4 * 24 * time.Hour)
+	if open := h.receiver.Evaluate(); open != 0 {
+		t.Errorf("%d incidents open with the group paused", open)
+	}
+	h.expectEvents("paused group")
+
+	// Deleting a group deletes its heartbeats
+	if code := h.request(http.MethodDelete, receiver.APIPath+"/heartbeat-groups/"+group.Data.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("deleting group returned %d", code)
+	}
+	var list heartbeat.HeartbeatsResponse
+	h.request(http.MethodGet, receiver.APIPath+"/heartbeats", "", &list)
+	if len(list.Data) != 1 || list.Data[0].ID != report.Data.ID {
+		t.Errorf("heartbeats left after deleting the group: %v", list.Data)
+	}
+	if code := h.ping(backup.Data, "", ""); code != http.StatusNotFound {
+		t.Errorf("ping of a deleted heartbeat returned %d, expected 404", code)
+	}
+
+	h = startReceiver(t, stateFile, h.now)
+	if checks := h.receiver.Checks(); len(checks) != 1 || checks[0].Name != "report" {
+		t.Errorf("restart after delete: got checks %v", checks)
+	}
+}
+
+// Function to check deleting a heartbeat closes its incident, and that the
+// resolved incidents are aged out and capped
+func TestIncidentRetention(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	h := startReceiver(t, stateFile, start)
+
+	var group heartbeat.HeartbeatGroupResponse
+	h.request(http.MethodPost, receiver.APIPath+"/heartbeat-groups", `{"name":"nightly"}`, &group)
+	var backup, report heartbeat.HeartbeatResponse
+	h.request(http.MethodPost, receiver.APIPath+"/heartbeats", `{"name":"backup","period":3600}`, &backup)
+	body := fmt.Sprintf(`{"name":"report","period":3600,"heartbeat_group_id":%q}`, group.Data.ID)
+	h.request(http.MethodPost, receiver.APIPath+"/heartbeats", body, &report)
+
+	h.now = start.Add(2 * time.Hour)
+	if open := h.receiver.Evaluate(); open != 2 {
+		t.Fatalf("%d incidents open after the missed runs, expected 2", open)
+	}
+	h.expectEvents("missed runs", "down backup missed 0 ", "down report missed 0 ")
+
+	// Nothing could resolve the incident of a deleted heartbeat
+	if code := h.request(http.MethodDelete, receiver.APIPath+"/heartbeats/"+backup.Data.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("deleting heartbeat returned %d", code)
+	}
+	h.expectEvents("deleted heartbeat", "deleted backup missed 0 ")
+	if code := h.request(http.MethodDelete, receiver.APIPath+"/heartbeat-groups/"+group.Data.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("deleting group returned %d", code)
+	}
+	h.expectEvents("deleted group", "deleted report missed 0 ")
+	h = startReceiver(t, stateFile, h.now)
+	for _, incident := range h.receiver.Incidents() {
+		if incident.Open() {
+			t.Errorf("incident %s of deleted heartbeat %s is still open after restart", incident.ID, incident.CheckName)
+		}
+	}
+	if open := h.receiver.Evaluate(); open != 0 {
+		t.Errorf("%d incidents open once every heartbeat is deleted", open)
+	}
+
+	// Resolved incidents are kept for the retention, and no more than the cap
+	var poll heartbeat.HeartbeatResponse
+	h.request(http.MethodPost, receiver.APIPath+"/heartbeats", `{"name":"poll","period":60}`, &poll)
+	h.now = h.now.Add(receiver.IncidentRetention + time.Hour)
+	h.ping(poll.Data, "", "")
+	if incidents := h.receiver.Incidents(); len(incidents) != 0 {
+		t.Errorf("%d incidents kept past the retention, expected none", len(incidents))
+	}
+	for i := 0; i < receiver.MaxResolvedIncidents+10; i++ {
+		h.now = h.now.Add(time.Minute)
+		h.ping(poll.Data, "fail", "")
+		h.ping(poll.Data, "", "")
+	}
+	h.events = nil
+	incidents := h.receiver.Incidents()
+	if len(incidents) != receiver.MaxResolvedIncidents {
+		t.Errorf("%d incidents kept, expected %d", len(incidents), receiver.MaxResolvedIncidents)
+	}
+	h = startReceiver(t, stateFile, h.now)
+	if got := h.receiver.Incidents(); len(got) != len(incidents) || got[len(got)-1].ID != incidents[len(incidents)-1].ID {
+		t.Errorf("restart: got %d incidents, expected the %d latest", len(got), len(incidents))
+	}
+}
@@ -0,0 +1,107 @@
+package rewrite
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/crontab"
+)
+
+// Heartbeat URL used by every case of the matrix
+const heartbeatURL = "https://uptime.betterstack.com/api/v1/heartbeat/matrix"
+
+// Case of the rewrite matrix
+type rewriteCase struct {
+	Title    string
+	System   bool
+	Original string
+	Expected string
+	Error    string
+}
+
+// Function to check every case of the rewrite matrix
+func TestRewriteMatrix(t *testing.T) {
+	cases, err := readMatrix("matrix.txt")
+	if err != nil {
+		t.Fatalf("Error reading matrix: %v", err)
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Title, func(t *testing.T) {
+			if err := check(c); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Function to check one case of the matrix
+func check(c rewriteCase) error {
+	crontab.SystemCrontab = c.System
+
+	got, err := crontab.InstrumentLine(c.Original, heartbeatURL)
+	if c.Error != "" {
+		if err == nil {
+			return fmt.Errorf("expected an error containing %q, got %q", c.Error, got)
+		}
+		if !strings.Contains(err.Error(), c.Error) {
+			return fmt.Errorf("expected an error containing %q, got %q", c.Error, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unexpected error: %v", err)
+	}
+	if got != c.Expected {
+		return fmt.Errorf("instrumented line\n     got:  %q\n     want: %q", got, c.Expected)
+	}
+
+	// The ping must be removable, giving the original line back
+	original, url, ok := crontab.StripLine(got)
+	if !ok {
+		return fmt.Errorf("the instrumented line is not recognised by StripLine")
+	}
+	if original != c.Original || url != heartbeatURL {
+		return fmt.Errorf("stripped line\n     got:  %q (%s)\n     want: %q", original, url, c.Original)
+	}
+	return nil
+}
+
+// Function to read the cases of a matrix file
+func readMatrix(path string) ([]rewriteCase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ping := fmt.Sprintf("curl -fs --retry 3 %s > /dev/null 2>&1", heartbeatURL)
+	var cases []rewriteCase
+	var current rewriteCase
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# "):
+			current.Title = strings.TrimPrefix(line, "# ")
+		case line == ": system":
+			current.System = true
+		case strings.HasPrefix(line, "< "):
+			current.Original = strings.ReplaceAll(strings.TrimPrefix(line, "< "), "{ping}", ping)
+		case strings.HasPrefix(line, "> "):
+			current.Expected = strings.ReplaceAll(strings.TrimPrefix(line, "> "), "{ping}", ping)
+			cases = append(cases, current)
+			current = rewriteCase{}
+		case strings.HasPrefix(line, "! "):
+			current.Error = strings.TrimPrefix(line, "! ")
+			cases = append(cases, current)
+			current = rewriteCase{}
+		}
+	}
+
+	return cases, scanner.Err()
+}
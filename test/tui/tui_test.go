@@ -0,0 +1,153 @@
+package tui_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/IT-JONCTION/beatify/tui"
+)
+
+// Keys erasing any value a field is edited with
+var erase = strings.Repeat(tui.KeyBackspace, 40)
+
+// helper function to build the items of a crontab with two tasks that can be
+// monitored and one that cannot
+func testItems() []tui.Item {
+	return []tui.Item{
+		{
+			Line: "0 2 * * * /usr/local/bin/backup", Spec: "0 2 * * *", Task: "/usr/local/bin/backup",
+			Schedule: "at 02:00", State: "unmanaged", Selectable: true,
+			Name: "root: /usr/local/bin/backup", Period: 86400, Grace: 17280,
+		},
+		{
+			Line: "*/15 * * * * /usr/local/bin/report", Spec: "*/15 * * * *", Task: "/usr/local/bin/report",
+			Schedule: "every 15 minutes", State: "unmanaged", Selectable: true,
+			Name: "root: /usr/local/bin/report", Period: 900, Grace: 180,
+		},
+		{Line: "61 * * * * /usr/local/bin/broken", State: "invalid schedule"},
+	}
+}
+
+// helper function to describe the selected items as name|group|grace|chosen
+func describe(items []tui.Item) []string {
+	var described []string
+	for _, item := range items {
+		described = append(described, fmt.Sprintf("%s|%s|%d|%t", item.Name, item.Group, item.Grace, item.GraceChosen))
+	}
+	return described
+}
+
+// Function to replay selection sessions on a virtual terminal and check the
+// selected items and the last screen drawn
+func TestSelector(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		script   string
+		selected []string
+		screen   string
+		aborted  bool
+	}{
+		{
+			name:     "select and apply",
+			script:   tui.KeySpace + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{"root: /usr/local/bin/backup||17280|false"},
+			screen:   `1 heartbeat(s) will be created:`,
+		},
+		{
+			name:   "select all",
+			script: "a" + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{
+				"root: /usr/local/bin/backup||17280|false",
+				"root: /usr/local/bin/report||180|false",
+			},
+			screen: `"root: /usr/local/bin/report" in group -, period 15m, grace 3m`,
+		},
+		{
+			name:     "edit name",
+			script:   "n" + erase + "db backup" + tui.KeyEnter + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{"db backup||17280|false"},
+			screen:   `"db backup" in group -, period 24h, grace 4h48m`,
+		},
+		{
+			name:     "edit group",
+			script:   tui.KeyDown + "g" + "nightly" + tui.KeyEnter + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{"root: /usr/local/bin/report|nightly|180|false"},
+			screen:   `"root: /usr/local/bin/report" in group nightly`,
+		},
+		{
+			name:     "edit grace",
+			script:   "r" + erase + "1h" + tui.KeyEnter + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{"root: /usr/local/bin/backup||3600|true"},
+			screen:   `period 24h, grace 1h`,
+		},
+		{
+			name:     "edit grace to zero",
+			script:   "r" + erase + "0" + tui.KeyEnter + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{"root: /usr/local/bin/backup||0|true"},
+			screen:   `period 24h, grace 0s`,
+		},
+		{
+			name:    "invalid grace",
+			script:  "r" + erase + "soon" + tui.KeyEnter,
+			screen:  "invalid grace 'soon': expected seconds or a duration\nGrace: soon_",
+			aborted: true,
+		},
+		{
+			name:   "escape cancels an edit",
+			script: "n" + "x" + tui.KeyEscape + tui.KeyEnter + tui.KeyEnter,
+			screen: "No cron task selected, no heartbeat will be created.",
+		},
+		{
+			name:    "task that cannot be monitored",
+			script:  tui.KeyDown + tui.KeyDown + tui.KeySpace,
+			screen:  "This cron task cannot be monitored: invalid schedule",
+			aborted: true,
+		},
+		{
+			name:   "back from review",
+			script: tui.KeySpace + tui.KeyEnter + "b" + tui.KeyDown + tui.KeySpace + tui.KeyEnter + tui.KeyEnter,
+			selected: []string{
+				"root: /usr/local/bin/backup||17280|false",
+				"root: /usr/local/bin/report||180|false",
+			},
+			screen: "2 heartbeat(s) will be created:",
+		},
+		{
+			name:    "quit",
+			script:  tui.KeySpace + "q",
+			screen:  "> [x] 0 2 * * * /usr/local/bin/backup",
+			aborted: true,
+		},
+		{
+			name:    "interrupt on review",
+			script:  tui.KeySpace + tui.KeyEnter + tui.KeyCtrlC,
+			screen:  "enter apply  b back  q quit",
+			aborted: true,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			terminal := tui.NewVirtualTerminal(scenario.script, 80, 24)
+			selector := &tui.Selector{Title: "Select the cron tasks of root to monitor", Items: testItems()}
+
+			selected, err := selector.Run(&terminal.Terminal)
+			if scenario.aborted != errors.Is(err, tui.ErrAborted) {
+				t.Fatalf("got error %v, aborted %t expected", err, scenario.aborted)
+			}
+			if !scenario.aborted && err != nil {
+				t.Fatalf("Error running the selector: %v", err)
+			}
+
+			got, want := strings.Join(describe(selected), "\n"), strings.Join(scenario.selected, "\n")
+			if got != want {
+				t.Errorf("got selected items:\n%s\nexpected:\n%s", got, want)
+			}
+			if screen := terminal.Screen(); !strings.Contains(screen, scenario.screen) {
+				t.Errorf("last screen does not show %q:\n%s", scenario.screen, screen)
+			}
+		})
+	}
+}
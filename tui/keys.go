@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"bufio"
+)
+
+// Names of the special keys returned by readKey
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyInterrupt = "ctrl-c"
+)
+
+// helper function to read one key press, returning the key name for special
+// keys and the character itself otherwise
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+
+	switch c {
+	case '\r', '\n':
+		return keyEnter, nil
+	case 0x7f, 0x08:
+		return keyBackspace, nil
+	case 0x03:
+		return keyInterrupt, nil
+	case 0x1b:
+		// Arrow keys arrive as one escape sequence, a lone escape is the key itself
+		if r.Buffered() == 0 {
+			return keyEscape, nil
+		}
+		next, err := r.Peek(1)
+		if err != nil || (next[0] != '[' && next[0] != 'O') {
+			return keyEscape, nil
+		}
+		r.ReadByte()
+		final, err := r.ReadByte()
+		if err != nil {
+			return keyEscape, nil
+		}
+		switch final {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		}
+		// Other sequences are ignored
+		return "", nil
+	}
+
+	return string(c), nil
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Escape sequences used to draw the screens
+const (
+	clearScreen     = "\x1b[H\x1b[2J"
+	enterAltScreen  = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen  = "\x1b[?25h\x1b[?1049l"
+	defaultWidth    = 80
+	defaultHeight   = 24
+	minScreenHeight = 10
+)
+
+// Terminal is where the selector reads keys from and draws its screens
+type Terminal struct {
+	In     io.Reader
+	Out    io.Writer
+	Width  int
+	Height int
+}
+
+// Function to prepare a terminal on the given input and output. A real
+// terminal is switched to raw mode and to its alternate screen until the
+// returned function is called; any other input is read as a key script
+func OpenTerminal(in io.Reader, out io.Writer) (*Terminal, func(), error) {
+	t := &Terminal{In: in, Out: out, Width: defaultWidth, Height: defaultHeight}
+
+	file, ok := in.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return t, func() {}, nil
+	}
+
+	state, err := term.MakeRaw(int(file.Fd()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error switching the terminal to raw mode: %w", err)
+	}
+	if width, height, err := term.GetSize(int(file.Fd())); err == nil {
+		t.Width, t.Height = width, height
+	}
+	fmt.Fprint(out, enterAltScreen)
+
+	restore := func() {
+		fmt.Fprint(out, leaveAltScreen)
+		term.Restore(int(file.Fd()), state)
+	}
+	return t, restore, nil
+}
+
+// helper function to draw a screen, in raw mode lines need a carriage return
+func (t *Terminal) draw(screen string) error {
+	_, err := io.WriteString(t.Out, clearScreen+strings.ReplaceAll(screen, "\n", "\r\n"))
+	return err
+}
+
+// VirtualTerminal plays a key script and records every screen drawn, so a
+// selection session can be replayed without a terminal
+type VirtualTerminal struct {
+	Terminal
+	output bytes.Buffer
+}
+
+// Keys to build scripts for a VirtualTerminal
+const (
+	KeyUp        = "\x1b[A"
+	KeyDown      = "\x1b[B"
+	KeyEnter     = "\r"
+	KeyEscape    = "\x1b"
+	KeyBackspace = "\x7f"
+	KeySpace     = " "
+	KeyCtrlC     = "\x03"
+)
+
+// Function to create a virtual terminal of the given size playing a key script
+func NewVirtualTerminal(script string, width, height int) *VirtualTerminal {
+	v := &VirtualTerminal{}
+	v.Terminal = Terminal{In: strings.NewReader(script), Out: &v.output, Width: width, Height: height}
+	return v
+}
+
+// Function to get every screen drawn so far, oldest first
+func (v *VirtualTerminal) Screens() []string {
+	var screens []string
+	for _, screen := range strings.Split(v.output.String(), clearScreen) {
+		if screen != "" {
+			screens = append(screens, strings.ReplaceAll(screen, "\r\n", "\n"))
+		}
+	}
+	return screens
+}
+
+// Function to get the last screen drawn
+func (v *VirtualTerminal) Screen() string {
+	screens := v.Screens()
+	if len(screens) == 0 {
+		return ""
+	}
+	return screens[len(screens)-1]
+}
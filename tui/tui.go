@@ -0,0 +1,402 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrAborted is returned when the selection is left without applying it
+var ErrAborted = errors.New("selection aborted")
+
+// Item is a cron task shown by the selector
+type Item struct {
+	Line     string
+	Spec     string
+	Task     string
+	Schedule string
+	State    string
+
+	// Only selectable items can be selected and edited
+	Selectable bool
+	Selected   bool
+
+	// Heartbeat settings, editable inline
+	Name   string
+	Group  string
+	Period int
+	Grace  int
+
+	// Set once the grace was edited, the grace being the default of the
+	// schedule until then
+	GraceChosen bool
+}
+
+// Screens of the selector
+const (
+	listScreen = iota
+	reviewScreen
+)
+
+// Fields of an item that can be edited
+const (
+	editName  = "Name"
+	editGroup = "Group"
+	editGrace = "Grace"
+)
+
+// Lines used by every item of the list screen
+const itemHeight = 3
+
+// Selector is a multi-select list of cron tasks followed by a review screen.
+// It draws plain text screens and reads key presses, so a session can be
+// replayed with a VirtualTerminal and a key script
+type Selector struct {
+	Title string
+	Items []Item
+
+	// ParseGrace turns the grace typed for an item into seconds
+	ParseGrace func(value string, period int) (int, error)
+
+	screen  int
+	cursor  int
+	offset  int
+	editing string
+	input   string
+	message string
+	done    bool
+	aborted bool
+}
+
+// Function to run the selector on a terminal until the selection is applied
+// or aborted, returning the selected items
+func (s *Selector) Run(t *Terminal) ([]Item, error) {
+	r := bufio.NewReader(t.In)
+	for {
+		if err := t.draw(s.View(t.Width, t.Height)); err != nil {
+			return nil, fmt.Errorf("Error drawing the screen: %w", err)
+		}
+
+		key, err := readKey(r)
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: the terminal input was closed", ErrAborted)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the terminal: %w", err)
+		}
+
+		s.Update(key)
+		if s.aborted {
+			return nil, ErrAborted
+		}
+		if s.done {
+			return s.Selected(), nil
+		}
+	}
+}
+
+// Function to get the selected items
+func (s *Selector) Selected() []Item {
+	var selected []Item
+	for _, item := range s.Items {
+		if item.Selected {
+			selected = append(selected, item)
+		}
+	}
+	return selected
+}
+
+// Function to apply a key press to the selector
+func (s *Selector) Update(key string) {
+	if key == keyInterrupt {
+		s.aborted = true
+		return
+	}
+	s.message = ""
+
+	if s.editing != "" {
+		s.updateEdit(key)
+		return
+	}
+
+	if s.screen == reviewScreen {
+		switch key {
+		case keyEnter, "y":
+			s.done = true
+		case keyEscape, "b":
+			s.screen = listScreen
+		case "q":
+			s.aborted = true
+		}
+		return
+	}
+
+	switch key {
+	case keyUp, "k":
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case keyDown, "j":
+		if s.cursor < len(s.Items)-1 {
+			s.cursor++
+		}
+	case " ", "x":
+		if item := s.current(); item != nil {
+			item.Selected = !item.Selected
+		}
+	case "a":
+		s.toggleAll()
+	case "n":
+		s.startEdit(editName)
+	case "g":
+		s.startEdit(editGroup)
+	case "r":
+		s.startEdit(editGrace)
+	case keyEnter:
+		s.screen = reviewScreen
+	case keyEscape, "q":
+		s.aborted = true
+	}
+}
+
+// helper function to get the selectable item under the cursor
+func (s *Selector) current() *Item {
+	if s.cursor >= len(s.Items) || !s.Items[s.cursor].Selectable {
+		if s.cursor < len(s.Items) {
+			s.message = "This cron task cannot be monitored: " + s.Items[s.cursor].State
+		}
+		return nil
+	}
+	return &s.Items[s.cursor]
+}
+
+// helper function to select every selectable item, or none if all are selected
+func (s *Selector) toggleAll() {
+	all := true
+	for _, item := range s.Items {
+		if item.Selectable && !item.Selected {
+			all = false
+		}
+	}
+	for i := range s.Items {
+		if s.Items[i].Selectable {
+			s.Items[i].Selected = !all
+		}
+	}
+}
+
+// helper function to start editing a field of the item under the cursor
+func (s *Selector) startEdit(field string) {
+	item := s.current()
+	if item == nil {
+		return
+	}
+	s.editing = field
+	switch field {
+	case editName:
+		s.input = item.Name
+	case editGroup:
+		s.input = item.Group
+	case editGrace:
+		s.input = FormatSeconds(item.Grace)
+	}
+}
+
+// helper function to apply a key press to the field being edited
+func (s *Selector) updateEdit(key string) {
+	switch key {
+	case keyEscape:
+		s.editing = ""
+	case keyBackspace:
+		if s.input != "" {
+			_, size := utf8.DecodeLastRuneInString(s.input)
+			s.input = s.input[:len(s.input)-size]
+		}
+	case keyEnter:
+		s.commitEdit()
+	default:
+		r, size := utf8.DecodeRuneInString(key)
+		if size == len(key) && unicode.IsPrint(r) {
+			s.input += key
+		}
+	}
+}
+
+// helper function to store the edited value in the item under the cursor
+func (s *Selector) commitEdit() {
+	item := &s.Items[s.cursor]
+	value := strings.TrimSpace(s.input)
+	switch s.editing {
+	case editName:
+		if value == "" {
+			s.message = "The heartbeat name cannot be empty"
+			return
+		}
+		item.Name = value
+	case editGroup:
+		item.Group = value
+	case editGrace:
+		grace, err := s.parseGrace(value, item.Period)
+		if err != nil {
+			s.message = err.Error()
+			return
+		}
+		item.Grace = grace
+		item.GraceChosen = true
+	}
+
+	// Editing an item means it is wanted
+	item.Selected = true
+	s.editing = ""
+}
+
+// helper function to parse a grace with ParseGrace, or as seconds or a duration
+func (s *Selector) parseGrace(value string, period int) (int, error) {
+	if s.ParseGrace != nil {
+		return s.ParseGrace(value, period)
+	}
+	if grace, err := strconv.Atoi(value); err == nil && grace >= 0 {
+		return grace, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid grace '%s': expected seconds or a duration", value)
+	}
+	return int(duration.Seconds()), nil
+}
+
+// Function to render the current screen for a terminal of the given size
+func (s *Selector) View(width, height int) string {
+	if height < minScreenHeight {
+		height = minScreenHeight
+	}
+
+	var b strings.Builder
+	if s.screen == reviewScreen {
+		s.viewReview(&b, height)
+	} else {
+		s.viewList(&b, height)
+	}
+
+	// Cut every line to the width of the terminal
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// helper function to render the list of items
+func (s *Selector) viewList(b *strings.Builder, height int) {
+	fmt.Fprintf(b, "%s\n\n", s.Title)
+
+	// Scroll to keep the cursor on the screen
+	visible := (height - 6) / itemHeight
+	if visible < 1 {
+		visible = 1
+	}
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.cursor >= s.offset+visible {
+		s.offset = s.cursor - visible + 1
+	}
+
+	if len(s.Items) == 0 {
+		b.WriteString("  No cron task found.\n")
+	}
+	for i := s.offset; i < len(s.Items) && i < s.offset+visible; i++ {
+		item := s.Items[i]
+		pointer := " "
+		if i == s.cursor {
+			pointer = ">"
+		}
+		box := "[-]"
+		if item.Selectable {
+			box = "[ ]"
+			if item.Selected {
+				box = "[x]"
+			}
+		}
+		fmt.Fprintf(b, "%s %s %s\n", pointer, box, item.Line)
+		if !item.Selectable {
+			fmt.Fprintf(b, "      %s\n\n", item.State)
+			continue
+		}
+		fmt.Fprintf(b, "      %s, period %s, grace %s, %s\n", item.Schedule, FormatSeconds(item.Period), FormatSeconds(item.Grace), item.State)
+		fmt.Fprintf(b, "      name %q, group %s\n", item.Name, valueOrNone(item.Group))
+	}
+
+	b.WriteString("\n")
+	s.viewFooter(b, "space select  a all  n name  g group  r grace  enter review  q quit")
+}
+
+// helper function to render the review of the selected items
+func (s *Selector) viewReview(b *strings.Builder, height int) {
+	selected := s.Selected()
+	fmt.Fprintf(b, "%s\n\n", s.Title)
+	if len(selected) == 0 {
+		b.WriteString("No cron task selected, no heartbeat will be created.\n")
+	} else {
+		fmt.Fprintf(b, "%d heartbeat(s) will be created:\n", len(selected))
+	}
+
+	// Two lines per item, keeping room for the header and the footer
+	visible := (height - 6) / 2
+	for i, item := range selected {
+		if i == visible {
+			fmt.Fprintf(b, "  ... and %d more\n", len(selected)-visible)
+			break
+		}
+		fmt.Fprintf(b, "  %q in group %s, period %s, grace %s\n", item.Name, valueOrNone(item.Group), FormatSeconds(item.Period), FormatSeconds(item.Grace))
+		fmt.Fprintf(b, "      %s %s\n", item.Spec, item.Task)
+	}
+
+	b.WriteString("\n")
+	s.viewFooter(b, "enter apply  b back  q quit")
+}
+
+// helper function to render the message and the key help or the field being edited
+func (s *Selector) viewFooter(b *strings.Builder, help string) {
+	fmt.Fprintf(b, "%s\n", s.message)
+	if s.editing != "" {
+		fmt.Fprintf(b, "%s: %s_\n", s.editing, s.input)
+		return
+	}
+	fmt.Fprintf(b, "%s\n", help)
+}
+
+// Function to format a number of seconds as a short duration such as "1h30m"
+func FormatSeconds(seconds int) string {
+	text := (time.Duration(seconds) * time.Second).String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// helper function to show a dash for empty values
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// helper function to cut a line to a number of characters
+func truncate(line string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:width-1]) + "…"
+}